@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// dialAgent connects to the local SSH agent named by SSH_AUTH_SOCK.
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("Agent forwarding requested but no SSH agent is available: SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("Agent forwarding requested but the SSH agent at %s is not available: %s", socket, err)
+	}
+
+	return conn, nil
+}
+
+// localKeyring connects to the local agent when forwarding is requested.
+// The returned close function is always safe to call.
+func localKeyring(forward bool) (agent.Agent, func(), error) {
+	if !forward {
+		return nil, func() {}, nil
+	}
+
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return agent.NewClient(conn), func() { conn.Close() }, nil
+}
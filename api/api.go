@@ -0,0 +1,89 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+)
+
+// RequestTimeout bounds each request to the Cloud Controller or UAA.
+const RequestTimeout = 30 * time.Second
+
+// NewHTTPClient returns a client for the API endpoint the CLI targets. It
+// skips certificate validation only when the target was set with
+// cf api --skip-ssl-validation.
+func NewHTTPClient(skipSSLValidation bool) *http.Client {
+	return &http.Client{
+		Timeout: RequestTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLValidation},
+		},
+	}
+}
+
+type cliConnection struct {
+	endpoint    string
+	credentials credential.CredentialFactory
+	httpClient  *http.Client
+}
+
+// NewCliConnection answers cf curl requests by calling endpoint directly
+// with tokens from credentials, so API calls work without a cf login
+// session. Requests are anonymous when credentials is nil. Commands other
+// than curl require a login session and fail.
+func NewCliConnection(endpoint string, credentials credential.CredentialFactory, httpClient *http.Client) plugin.CliConnection {
+	return &cliConnection{
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		credentials: credentials,
+		httpClient:  httpClient,
+	}
+}
+
+func (c *cliConnection) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	if len(args) != 2 || args[0] != "curl" {
+		return nil, fmt.Errorf("cf %s requires a cf login session", strings.Join(args, " "))
+	}
+	return c.get(args[1])
+}
+
+func (c *cliConnection) CliCommand(args ...string) ([]string, error) {
+	return c.CliCommandWithoutTerminalOutput(args...)
+}
+
+// get returns the response body whatever the status, as cf curl does, so
+// callers can inspect API errors.
+func (c *cliConnection) get(path string) ([]string, error) {
+	req, err := http.NewRequest("GET", c.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.credentials != nil {
+		cred, err := c.credentials.Get()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", cred.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(body)}, nil
+}
@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/api"
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+	"github.com/sykesm/cf-ssh-plugin/models/credential/credential_fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Api", func() {
+	Describe("NewHTTPClient", func() {
+		It("bounds each request", func() {
+			client := api.NewHTTPClient(false)
+			Expect(client.Timeout).To(Equal(30 * time.Second))
+		})
+
+		It("skips certificate validation only when asked to", func() {
+			transport := api.NewHTTPClient(false).Transport.(*http.Transport)
+			Expect(transport.TLSClientConfig.InsecureSkipVerify).To(BeFalse())
+
+			transport = api.NewHTTPClient(true).Transport.(*http.Transport)
+			Expect(transport.TLSClientConfig.InsecureSkipVerify).To(BeTrue())
+		})
+	})
+
+	Describe("CliConnection", func() {
+		var (
+			server          *httptest.Server
+			authorization   string
+			path            string
+			fakeCredFactory *credential_fakes.FakeCredentialFactory
+		)
+
+		BeforeEach(func() {
+			authorization, path = "", ""
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				path = r.URL.RequestURI()
+				if r.URL.Path == "/v3/apps/missing" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+			}))
+
+			fakeCredFactory = &credential_fakes.FakeCredentialFactory{}
+			fakeCredFactory.GetReturns(credential.Credential{Token: "bearer abc"}, nil)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("answers cf curl with the supplied token", func() {
+			cli := api.NewCliConnection(server.URL+"/", fakeCredFactory, api.NewHTTPClient(false))
+
+			output, err := cli.CliCommandWithoutTerminalOutput("curl", "/v2/spaces?q=name%3Adev")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]string{`{"path":"/v2/spaces"}`}))
+			Expect(path).To(Equal("/v2/spaces?q=name%3Adev"))
+			Expect(authorization).To(Equal("bearer abc"))
+		})
+
+		It("returns the body of a failed request, as cf curl does", func() {
+			cli := api.NewCliConnection(server.URL, fakeCredFactory, api.NewHTTPClient(false))
+
+			output, err := cli.CliCommandWithoutTerminalOutput("curl", "/v3/apps/missing")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]string{`{"path":"/v3/apps/missing"}`}))
+		})
+
+		It("makes anonymous requests without credentials", func() {
+			cli := api.NewCliConnection(server.URL, nil, api.NewHTTPClient(false))
+
+			_, err := cli.CliCommandWithoutTerminalOutput("curl", "/v2/info")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(authorization).To(BeEmpty())
+		})
+
+		It("fails when no token can be acquired", func() {
+			fakeCredFactory.GetReturns(credential.Credential{}, errors.New("no token"))
+			cli := api.NewCliConnection(server.URL, fakeCredFactory, api.NewHTTPClient(false))
+
+			_, err := cli.CliCommandWithoutTerminalOutput("curl", "/v2/info")
+			Expect(err).To(MatchError("no token"))
+			Expect(path).To(BeEmpty())
+		})
+
+		It("rejects commands that need a login session", func() {
+			cli := api.NewCliConnection(server.URL, fakeCredFactory, api.NewHTTPClient(false))
+
+			_, err := cli.CliCommandWithoutTerminalOutput("ssh-code")
+			Expect(err).To(MatchError("cf ssh-code requires a cf login session"))
+		})
+	})
+})
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sykesm/cf-ssh-plugin/homedir"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the content of the plugin configuration file. Global defaults
+// apply to every session while app sections apply only to the app they name
+// and, when present, the API endpoint, org, and space it lives in.
+type Config struct {
+	Defaults    Defaults          `yaml:"defaults,omitempty"`
+	Apps        []AppConfig       `yaml:"apps,omitempty"`
+	Tunnels     map[string]Tunnel `yaml:"tunnels,omitempty"`
+	Credentials Credentials       `yaml:"credentials,omitempty"`
+}
+
+// Credentials selects where access tokens come from when there is no
+// interactive cf login session, as in CI pipelines. Source is one of cli,
+// env, file, or client_credentials. Tokens from any source but cli are used
+// for the API requests as well as SSH, against the endpoint set with cf api.
+type Credentials struct {
+	Source       string `yaml:"source,omitempty"`
+	TokenFile    string `yaml:"token_file,omitempty"`
+	ClientID     string `yaml:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret,omitempty"`
+}
+
+type Defaults struct {
+	Instance          *int              `yaml:"instance,omitempty"`
+	Forwards          []string          `yaml:"forward,omitempty"`
+	ConnectTimeout    string            `yaml:"connect_timeout,omitempty"`
+	HandshakeTimeout  string            `yaml:"handshake_timeout,omitempty"`
+	ConnectAttempts   *int              `yaml:"connect_attempts,omitempty"`
+	Wait              *bool             `yaml:"wait,omitempty"`
+	KeepAliveInterval string            `yaml:"keepalive_interval,omitempty"`
+	KeepAliveCountMax *int              `yaml:"keepalive_count,omitempty"`
+	IdleTimeout       string            `yaml:"idle_timeout,omitempty"`
+	Pty               string            `yaml:"pty,omitempty"`
+	EscapeChar        string            `yaml:"escape_char,omitempty"`
+	Reconnect         *bool             `yaml:"reconnect,omitempty"`
+	ForwardAgent      *bool             `yaml:"forward_agent,omitempty"`
+	SSHEndpoint       string            `yaml:"ssh_endpoint,omitempty"`
+	SSHFingerprint    string            `yaml:"ssh_fingerprint,omitempty"`
+	Proxy             string            `yaml:"proxy,omitempty"`
+	JumpHost          string            `yaml:"jump_host,omitempty"`
+	Env               map[string]string `yaml:"env,omitempty"`
+	SendEnv           []string          `yaml:"send_env,omitempty"`
+}
+
+type AppConfig struct {
+	API   string `yaml:"api,omitempty"`
+	Org   string `yaml:"org,omitempty"`
+	Space string `yaml:"space,omitempty"`
+	App   string `yaml:"app"`
+
+	Defaults `yaml:",inline"`
+}
+
+// Tunnel is a named set of port forwards against an app instance.
+type Tunnel struct {
+	App      string   `yaml:"app"`
+	Org      string   `yaml:"org,omitempty"`
+	Space    string   `yaml:"space,omitempty"`
+	Guid     bool     `yaml:"guid,omitempty"`
+	Process  string   `yaml:"process,omitempty"`
+	Instance int      `yaml:"instance,omitempty"`
+	Forwards []string `yaml:"forward"`
+}
+
+// DefaultPath returns the location of the configuration file. CF_SSH_CONFIG
+// names the file explicitly; otherwise it lives with the other CLI plugins.
+func DefaultPath() string {
+	if path := os.Getenv("CF_SSH_CONFIG"); path != "" {
+		return path
+	}
+
+	return filepath.Join(PluginDir(), "ssh.yml")
+}
+
+// PluginDir returns the directory the CLI installs plugins into, honoring
+// CF_PLUGIN_HOME.
+func PluginDir() string {
+	home := os.Getenv("CF_PLUGIN_HOME")
+	if home == "" {
+		home = HomeDir()
+	}
+
+	return filepath.Join(home, ".cf", "plugins")
+}
+
+// Load reads the configuration file at path. A missing file is not an error
+// and results in an empty configuration.
+func Load(path string) (*Config, error) {
+	config := &Config{}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read configuration file %s: %s", path, err)
+	}
+
+	err = yaml.Unmarshal(contents, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse configuration file %s: %s", path, err)
+	}
+
+	return config, nil
+}
+
+// SaveTunnels writes the tunnel profiles to the configuration file at path,
+// creating the file and its parent directory when necessary. Only the
+// top-level tunnels section is rewritten; every other line of a hand-written
+// file, comments and keys this version does not know included, is kept.
+func (c *Config) SaveTunnels(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to read configuration file %s: %s", path, err)
+	}
+
+	section := []byte{}
+	if len(c.Tunnels) > 0 {
+		section, err = yaml.Marshal(Config{Tunnels: c.Tunnels})
+		if err != nil {
+			return err
+		}
+	}
+	contents = replaceSection(contents, "tunnels", section)
+
+	err = yaml.Unmarshal(contents, &Config{})
+	if err != nil {
+		return fmt.Errorf("Failed to update configuration file %s: %s", path, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("Failed to write configuration file %s: %s", path, err)
+	}
+
+	err = ioutil.WriteFile(path, contents, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write configuration file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// replaceSection replaces the block of a top-level key in a YAML document
+// with section, appending section when the key is absent. The block runs
+// from the key to its last indented line; comments and blank lines after it
+// belong to whatever follows.
+func replaceSection(contents []byte, key string, section []byte) []byte {
+	lines := strings.SplitAfter(string(contents), "\n")
+
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		if start < 0 {
+			if strings.HasPrefix(line, key+":") {
+				start, end = i, i+1
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			break
+		}
+		end = i + 1
+	}
+
+	if start < 0 {
+		text := strings.Join(lines, "")
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return []byte(text + string(section))
+	}
+
+	return []byte(strings.Join(lines[:start], "") + string(section) + strings.Join(lines[end:], ""))
+}
+
+// DefaultsFor merges the global defaults with every app section that matches
+// the target and app name. Sections are applied in file order so later ones
+// win.
+func (c *Config) DefaultsFor(t target.Target, appName string) Defaults {
+	defaults := Defaults{}
+	defaults.merge(c.Defaults)
+
+	for _, app := range c.Apps {
+		if app.matches(t, appName) {
+			defaults.merge(app.Defaults)
+		}
+	}
+
+	return defaults
+}
+
+func (app *AppConfig) matches(t target.Target, appName string) bool {
+	if app.App != appName {
+		return false
+	}
+	if app.API != "" && normalizeAPI(app.API) != normalizeAPI(t.API) {
+		return false
+	}
+	if app.Org != "" && app.Org != t.Org {
+		return false
+	}
+	if app.Space != "" && app.Space != t.Space {
+		return false
+	}
+	return true
+}
+
+func (d *Defaults) merge(other Defaults) {
+	if other.Instance != nil {
+		instance := *other.Instance
+		d.Instance = &instance
+	}
+	if len(other.Forwards) > 0 {
+		d.Forwards = append([]string{}, other.Forwards...)
+	}
+	if other.ConnectTimeout != "" {
+		d.ConnectTimeout = other.ConnectTimeout
+	}
+	if other.HandshakeTimeout != "" {
+		d.HandshakeTimeout = other.HandshakeTimeout
+	}
+	if other.ConnectAttempts != nil {
+		attempts := *other.ConnectAttempts
+		d.ConnectAttempts = &attempts
+	}
+	if other.Wait != nil {
+		wait := *other.Wait
+		d.Wait = &wait
+	}
+	if other.KeepAliveInterval != "" {
+		d.KeepAliveInterval = other.KeepAliveInterval
+	}
+	if other.KeepAliveCountMax != nil {
+		count := *other.KeepAliveCountMax
+		d.KeepAliveCountMax = &count
+	}
+	if other.IdleTimeout != "" {
+		d.IdleTimeout = other.IdleTimeout
+	}
+	if other.Pty != "" {
+		d.Pty = other.Pty
+	}
+	if other.EscapeChar != "" {
+		d.EscapeChar = other.EscapeChar
+	}
+	if other.Reconnect != nil {
+		reconnect := *other.Reconnect
+		d.Reconnect = &reconnect
+	}
+	if other.SSHEndpoint != "" {
+		d.SSHEndpoint = other.SSHEndpoint
+	}
+	if other.SSHFingerprint != "" {
+		d.SSHFingerprint = other.SSHFingerprint
+	}
+	if other.Proxy != "" {
+		d.Proxy = other.Proxy
+	}
+	if other.JumpHost != "" {
+		d.JumpHost = other.JumpHost
+	}
+	if other.ForwardAgent != nil {
+		forwardAgent := *other.ForwardAgent
+		d.ForwardAgent = &forwardAgent
+	}
+	for k, v := range other.Env {
+		if d.Env == nil {
+			d.Env = map[string]string{}
+		}
+		d.Env[k] = v
+	}
+	if len(other.SendEnv) > 0 {
+		d.SendEnv = append([]string{}, other.SendEnv...)
+	}
+}
+
+func normalizeAPI(api string) string {
+	api = strings.ToLower(strings.TrimRight(api, "/"))
+	api = strings.TrimPrefix(api, "https://")
+	return strings.TrimPrefix(api, "http://")
+}
+
+// HomeDir returns the current user's home directory.
+func HomeDir() string {
+	return homedir.Dir()
+}
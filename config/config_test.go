@@ -0,0 +1,262 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "ssh-config")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	Describe("DefaultPath", func() {
+		var savedConfig, savedPluginHome string
+
+		BeforeEach(func() {
+			savedConfig = os.Getenv("CF_SSH_CONFIG")
+			savedPluginHome = os.Getenv("CF_PLUGIN_HOME")
+		})
+
+		AfterEach(func() {
+			os.Setenv("CF_SSH_CONFIG", savedConfig)
+			os.Setenv("CF_PLUGIN_HOME", savedPluginHome)
+		})
+
+		It("honors CF_SSH_CONFIG", func() {
+			os.Setenv("CF_SSH_CONFIG", "/some/where/ssh.yml")
+			Expect(config.DefaultPath()).To(Equal("/some/where/ssh.yml"))
+		})
+
+		It("lives in the plugin directory under CF_PLUGIN_HOME", func() {
+			os.Setenv("CF_SSH_CONFIG", "")
+			os.Setenv("CF_PLUGIN_HOME", tempDir)
+			Expect(config.DefaultPath()).To(Equal(filepath.Join(tempDir, ".cf", "plugins", "ssh.yml")))
+		})
+	})
+
+	Describe("Load", func() {
+		var (
+			path string
+			cfg  *config.Config
+			err  error
+		)
+
+		BeforeEach(func() {
+			path = filepath.Join(tempDir, "ssh.yml")
+		})
+
+		JustBeforeEach(func() {
+			cfg, err = config.Load(path)
+		})
+
+		Context("when the file does not exist", func() {
+			It("returns an empty configuration", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg).To(Equal(&config.Config{}))
+			})
+		})
+
+		Context("when the file is valid", func() {
+			BeforeEach(func() {
+				contents := `
+defaults:
+  instance: 1
+  connect_timeout: 10s
+  env:
+    DEBUG: "false"
+    LANG: C
+  send_env:
+  - LC_*
+apps:
+- app: app1
+  forward:
+  - 8080:localhost:8080
+  env:
+    DEBUG: "true"
+- api: https://api.example.com/
+  org: org1
+  space: space1
+  app: app1
+  instance: 3
+  pty: force
+`
+				Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+			})
+
+			It("parses the global defaults", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*cfg.Defaults.Instance).To(Equal(1))
+				Expect(cfg.Defaults.ConnectTimeout).To(Equal("10s"))
+				Expect(cfg.Apps).To(HaveLen(2))
+			})
+
+			Describe("DefaultsFor", func() {
+				It("returns the global defaults for other apps", func() {
+					defaults := cfg.DefaultsFor(target.Target{}, "app2")
+					Expect(*defaults.Instance).To(Equal(1))
+					Expect(defaults.Forwards).To(BeEmpty())
+					Expect(defaults.Env).To(Equal(map[string]string{"DEBUG": "false", "LANG": "C"}))
+					Expect(defaults.SendEnv).To(ConsistOf("LC_*"))
+				})
+
+				It("layers matching app sections over the global defaults", func() {
+					defaults := cfg.DefaultsFor(target.Target{API: "https://api.other.com"}, "app1")
+					Expect(*defaults.Instance).To(Equal(1))
+					Expect(defaults.Forwards).To(ConsistOf("8080:localhost:8080"))
+					Expect(defaults.Env).To(Equal(map[string]string{"DEBUG": "true", "LANG": "C"}))
+					Expect(defaults.Pty).To(BeEmpty())
+				})
+
+				It("applies sections scoped to the targeted API, org, and space", func() {
+					defaults := cfg.DefaultsFor(target.Target{
+						API:   "https://API.example.com",
+						Org:   "org1",
+						Space: "space1",
+					}, "app1")
+					Expect(*defaults.Instance).To(Equal(3))
+					Expect(defaults.Forwards).To(ConsistOf("8080:localhost:8080"))
+					Expect(defaults.Pty).To(Equal("force"))
+					Expect(defaults.ConnectTimeout).To(Equal("10s"))
+				})
+
+				It("does not modify the loaded configuration", func() {
+					cfg.DefaultsFor(target.Target{}, "app1")
+					Expect(cfg.Defaults.Env).To(Equal(map[string]string{"DEBUG": "false", "LANG": "C"}))
+				})
+			})
+		})
+
+		Context("when the file is not valid yaml", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(path, []byte("defaults: [this is: bad"), 0600)).To(Succeed())
+			})
+
+			It("fails with an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Failed to parse configuration file"))
+			})
+		})
+	})
+	Describe("SaveTunnels", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(tempDir, "plugins", "ssh.yml")
+		})
+
+		It("writes tunnel profiles that can be loaded again", func() {
+			cfg := &config.Config{
+				Tunnels: map[string]config.Tunnel{
+					"db-debug": {
+						App:      "app1",
+						Instance: 1,
+						Forwards: []string{"5432:db:5432", "9000:localhost:9000"},
+					},
+				},
+			}
+
+			Expect(cfg.SaveTunnels(path)).To(Succeed())
+
+			loaded, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal(cfg))
+		})
+
+		Context("when the file was written by hand", func() {
+			var original string
+
+			BeforeEach(func() {
+				original = `# team defaults
+defaults:
+  instance: 2 # the canary
+  proxy: socks5://proxy.example.com:1080
+
+tunnels:
+  old:
+    app: app1
+    forward:
+    - 8080:localhost:8080
+
+# kept for the next version
+future_setting: value
+`
+				Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(path, []byte(original), 0600)).To(Succeed())
+			})
+
+			It("rewrites only the tunnels section when a profile is saved", func() {
+				cfg, err := config.Load(path)
+				Expect(err).NotTo(HaveOccurred())
+				cfg.Tunnels["db-debug"] = config.Tunnel{App: "app2", Forwards: []string{"5432:db:5432"}}
+
+				Expect(cfg.SaveTunnels(path)).To(Succeed())
+
+				contents, err := ioutil.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("# team defaults\ndefaults:\n  instance: 2 # the canary\n  proxy: socks5://proxy.example.com:1080\n\ntunnels:\n"))
+				Expect(string(contents)).To(HaveSuffix("\n# kept for the next version\nfuture_setting: value\n"))
+
+				loaded, err := config.Load(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(loaded.Tunnels).To(HaveKey("old"))
+				Expect(loaded.Tunnels).To(HaveKey("db-debug"))
+			})
+
+			It("keeps the other keys when the last profile is deleted", func() {
+				cfg, err := config.Load(path)
+				Expect(err).NotTo(HaveOccurred())
+				delete(cfg.Tunnels, "old")
+
+				Expect(cfg.SaveTunnels(path)).To(Succeed())
+
+				contents, err := ioutil.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`# team defaults
+defaults:
+  instance: 2 # the canary
+  proxy: socks5://proxy.example.com:1080
+
+
+# kept for the next version
+future_setting: value
+`))
+			})
+		})
+
+		It("appends the tunnels section to a file without one", func() {
+			Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte("future_setting: value"), 0600)).To(Succeed())
+
+			cfg := &config.Config{Tunnels: map[string]config.Tunnel{"db-debug": {App: "app1", Forwards: []string{"5432:db:5432"}}}}
+			Expect(cfg.SaveTunnels(path)).To(Succeed())
+
+			contents, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(HavePrefix("future_setting: value\ntunnels:\n"))
+		})
+
+		It("keeps the file private to the user", func() {
+			Expect((&config.Config{}).SaveTunnels(path)).To(Succeed())
+
+			fileInfo, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fileInfo.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/sykesm/cf-ssh-plugin/api"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+	"github.com/sykesm/cf-ssh-plugin/options"
+)
+
+// useCredentials selects where access tokens come from. A token that does
+// not come from the cf login session is also used for the API requests,
+// which then go straight to the endpoint set with cf api instead of through
+// cf curl. Without a login session there is no targeted space to find the
+// app in unless the CLI configuration still names one, so opts falls back
+// to that org and space when the app is not located with --space or --guid.
+func (c *SshPlugin) useCredentials(settings config.Credentials, opts *options.Options) error {
+	if credential.Source(settings) == credential.SourceCLI {
+		return nil
+	}
+
+	t, err := c.targetFactory(settings).Get()
+	if err != nil {
+		return err
+	}
+	if t.API == "" {
+		return errors.New("No API endpoint set. Use 'cf api' to set an endpoint.")
+	}
+
+	httpClient := api.NewHTTPClient(t.SkipSSLValidation)
+
+	anonymous := logging.NewCliConnection(api.NewCliConnection(t.API, nil, httpClient), c.logger())
+	c.CredFactory, err = credential.NewFactory(anonymous, settings, httpClient)
+	if err != nil {
+		return err
+	}
+
+	cli := logging.NewCliConnection(api.NewCliConnection(t.API, c.CredFactory, httpClient), c.logger())
+	c.AppFactory = app.NewAppFactory(cli)
+	c.InfoFactory = info.NewInfoFactory(cli)
+	c.InstancesFactory = instances.NewInstancesFactory(cli)
+	c.SpaceFactory = space.NewSpaceFactory(cli)
+
+	if opts.Space == "" && !opts.ByGuid {
+		if t.Space == "" {
+			return errors.New("No space is targeted. Use --space or --guid to locate the app.")
+		}
+		opts.Org, opts.Space = t.Org, t.Space
+	}
+
+	return nil
+}
+
+// targetFactory returns where the target is read from: cf target needs a
+// login session, so other credential sources read the CLI configuration.
+func (c *SshPlugin) targetFactory(settings config.Credentials) target.TargetFactory {
+	if credential.Source(settings) == credential.SourceCLI {
+		return c.TargetFactory
+	}
+	return c.ConfigTargetFactory
+}
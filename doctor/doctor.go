@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sykesm/cf-ssh-plugin/failures"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Check is one link in the chain between the CLI and an instance. Run
+// returns a short description of what it found, or the reason it failed.
+// Hint is shown when the failure does not carry its own.
+type Check struct {
+	Name string
+	Hint string
+	Run  func() (string, error)
+}
+
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string
+}
+
+type skipped struct {
+	reason string
+}
+
+func (s skipped) Error() string {
+	return s.reason
+}
+
+// Skip is returned by a check that cannot run, usually because a check it
+// depends on failed.
+func Skip(reason string) error {
+	return skipped{reason: reason}
+}
+
+// Run runs each check in order.
+func Run(checks []Check) []Result {
+	results := []Result{}
+	for _, check := range checks {
+		results = append(results, run(check))
+	}
+	return results
+}
+
+func run(check Check) Result {
+	detail, err := check.Run()
+	if err == nil {
+		return Result{Name: check.Name, Status: StatusPass, Detail: detail}
+	}
+
+	var skip skipped
+	if errors.As(err, &skip) {
+		return Result{Name: check.Name, Status: StatusSkip, Detail: skip.reason}
+	}
+
+	hint := check.Hint
+	var failure *failures.Error
+	if errors.As(err, &failure) && failure.Hint != "" {
+		hint = failure.Hint
+	}
+
+	return Result{Name: check.Name, Status: StatusFail, Detail: err.Error(), Hint: hint}
+}
+
+// Passed reports whether no check failed. Skipped checks do not count as
+// failures; they only follow one.
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a line per result, followed by the hint of each failure.
+func Print(w io.Writer, results []Result) {
+	width := 0
+	for _, result := range results {
+		if len(result.Name) > width {
+			width = len(result.Name)
+		}
+	}
+
+	for _, result := range results {
+		fmt.Fprintf(w, "%-4s  %-*s  %s\n", result.Status, width, result.Name, result.Detail)
+		if result.Hint != "" {
+			fmt.Fprintf(w, "      %-*s  TIP: %s\n", width, "", result.Hint)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package doctor_test
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/sykesm/cf-ssh-plugin/doctor"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	var checks []doctor.Check
+
+	BeforeEach(func() {
+		checks = []doctor.Check{
+			{
+				Name: "CLI target",
+				Run:  func() (string, error) { return "api.example.com", nil },
+			},
+			{
+				Name: "App",
+				Hint: "Check the app.",
+				Run:  func() (string, error) { return "", failures.SSHDisabled("app1") },
+			},
+			{
+				Name: "DNS",
+				Hint: "Check DNS.",
+				Run:  func() (string, error) { return "", errors.New("no such host") },
+			},
+			{
+				Name: "SSH handshake",
+				Run:  func() (string, error) { return "", doctor.Skip("requires the app") },
+			},
+		}
+	})
+
+	Describe("Run", func() {
+		It("runs every check in order", func() {
+			results := doctor.Run(checks)
+			Expect(results).To(Equal([]doctor.Result{
+				{Name: "CLI target", Status: doctor.StatusPass, Detail: "api.example.com"},
+				{Name: "App", Status: doctor.StatusFail, Detail: "SSH is disabled for app app1", Hint: "Enable it with 'cf enable-ssh app1' and restart the app."},
+				{Name: "DNS", Status: doctor.StatusFail, Detail: "no such host", Hint: "Check DNS."},
+				{Name: "SSH handshake", Status: doctor.StatusSkip, Detail: "requires the app"},
+			}))
+		})
+	})
+
+	Describe("Passed", func() {
+		It("is false when any check fails", func() {
+			Expect(doctor.Passed(doctor.Run(checks))).To(BeFalse())
+		})
+
+		It("ignores skipped checks", func() {
+			Expect(doctor.Passed(doctor.Run([]doctor.Check{checks[0], checks[3]}))).To(BeTrue())
+		})
+	})
+
+	Describe("Print", func() {
+		It("aligns the results and shows hints under failures", func() {
+			out := &bytes.Buffer{}
+			doctor.Print(out, doctor.Run([]doctor.Check{checks[0], checks[2], checks[3]}))
+
+			Expect(out.String()).To(Equal(
+				"PASS  CLI target     api.example.com\n" +
+					"FAIL  DNS            no such host\n" +
+					"                     TIP: Check DNS.\n" +
+					"SKIP  SSH handshake  requires the app\n",
+			))
+		})
+	})
+})
@@ -0,0 +1,224 @@
+package escape
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//go:generate counterfeiter -o escape_fakes/fake_handler.go . Handler
+type Handler interface {
+	// Disconnect terminates the connection.
+	Disconnect()
+
+	// Connections describes the forwarded connections that are open.
+	Connections() []string
+
+	// Command runs a line entered at the ~C prompt and returns its output.
+	Command(line string) (string, error)
+}
+
+type state int
+
+const (
+	stateNormal state = iota
+	stateEscape
+	stateCommand
+)
+
+const (
+	ctrlC     = 0x03
+	ctrlU     = 0x15
+	backspace = 0x08
+	del       = 0x7f
+)
+
+// Reader recognizes OpenSSH style escape sequences in terminal input. An
+// escape is only recognized immediately after a newline or at the start of
+// the session; everything else is passed through unchanged.
+type Reader struct {
+	reader     io.Reader
+	out        io.Writer
+	escapeChar byte
+	handler    Handler
+
+	state        state
+	atLineStart  bool
+	command      []byte
+	buffer       []byte
+	pending      []byte
+	err          error
+	disconnected bool
+}
+
+// NewReader wraps reader. Messages for the user are written to out, which
+// is expected to be a terminal in raw mode.
+func NewReader(reader io.Reader, out io.Writer, escapeChar byte, handler Handler) *Reader {
+	return &Reader{
+		reader:      reader,
+		out:         out,
+		escapeChar:  escapeChar,
+		handler:     handler,
+		atLineStart: true,
+		buffer:      make([]byte, 1024),
+	}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.escapeChar == 0 {
+		return r.reader.Read(p)
+	}
+
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.reader.Read(r.buffer)
+		for _, b := range r.buffer[:n] {
+			r.pending = append(r.pending, r.process(b)...)
+			if r.disconnected {
+				err = io.EOF
+				break
+			}
+		}
+		r.err = err
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// process consumes a byte of input and returns the bytes to send to the
+// remote end.
+func (r *Reader) process(b byte) []byte {
+	switch r.state {
+	case stateEscape:
+		r.state = stateNormal
+		return r.escape(b)
+
+	case stateCommand:
+		r.commandInput(b)
+		return nil
+
+	default:
+		if r.atLineStart && b == r.escapeChar {
+			r.state = stateEscape
+			return nil
+		}
+		r.atLineStart = b == '\r' || b == '\n'
+		return []byte{b}
+	}
+}
+
+func (r *Reader) escape(b byte) []byte {
+	switch b {
+	case '.':
+		fmt.Fprintf(r.out, "%c.\r\n", r.escapeChar)
+		r.disconnected = true
+		r.handler.Disconnect()
+		return nil
+
+	case '?':
+		r.help()
+		r.atLineStart = true
+		return nil
+
+	case '#':
+		r.listConnections()
+		r.atLineStart = true
+		return nil
+
+	case 'C':
+		r.state = stateCommand
+		r.command = r.command[:0]
+		fmt.Fprint(r.out, "\r\nssh> ")
+		return nil
+
+	case r.escapeChar:
+		r.atLineStart = false
+		return []byte{b}
+
+	default:
+		r.atLineStart = b == '\r' || b == '\n'
+		return []byte{r.escapeChar, b}
+	}
+}
+
+func (r *Reader) commandInput(b byte) {
+	switch b {
+	case '\r', '\n':
+		fmt.Fprint(r.out, "\r\n")
+		r.state = stateNormal
+		r.atLineStart = true
+
+		line := strings.TrimSpace(string(r.command))
+		if line == "" {
+			return
+		}
+
+		output, err := r.handler.Command(line)
+		if err != nil {
+			fmt.Fprintf(r.out, "%s\r\n", err)
+			return
+		}
+		if output != "" {
+			fmt.Fprint(r.out, toCRLF(output))
+		}
+
+	case ctrlC:
+		fmt.Fprint(r.out, "\r\n")
+		r.state = stateNormal
+		r.atLineStart = true
+
+	case ctrlU:
+		fmt.Fprint(r.out, strings.Repeat("\b \b", len(r.command)))
+		r.command = r.command[:0]
+
+	case backspace, del:
+		if len(r.command) > 0 {
+			r.command = r.command[:len(r.command)-1]
+			fmt.Fprint(r.out, "\b \b")
+		}
+
+	default:
+		r.command = append(r.command, b)
+		r.out.Write([]byte{b})
+	}
+}
+
+func (r *Reader) help() {
+	e := string(r.escapeChar)
+	lines := []string{
+		"Supported escape sequences:",
+		" " + e + ".   - terminate connection",
+		" " + e + "C   - open a command line",
+		" " + e + "#   - list forwarded connections",
+		" " + e + "?   - this message",
+		" " + e + e + "   - send the escape character by typing it twice",
+		"(Note that escapes are only recognized immediately after newline.)",
+	}
+	fmt.Fprint(r.out, "\r\n"+strings.Join(lines, "\r\n")+"\r\n")
+}
+
+func (r *Reader) listConnections() {
+	connections := r.handler.Connections()
+
+	fmt.Fprint(r.out, "\r\nThe following connections are open:\r\n")
+	if len(connections) == 0 {
+		fmt.Fprint(r.out, "  (none)\r\n")
+	}
+	for i, connection := range connections {
+		fmt.Fprintf(r.out, "  #%d %s\r\n", i, connection)
+	}
+}
+
+func toCRLF(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	s = strings.Replace(s, "\n", "\r\n", -1)
+	if !strings.HasSuffix(s, "\r\n") {
+		s += "\r\n"
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+// This file was generated by counterfeiter
+package escape_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/escape"
+)
+
+type FakeHandler struct {
+	DisconnectStub        func()
+	disconnectMutex       sync.RWMutex
+	disconnectArgsForCall []struct{}
+	ConnectionsStub        func() []string
+	connectionsMutex       sync.RWMutex
+	connectionsArgsForCall []struct{}
+	connectionsReturns     struct {
+		result1 []string
+	}
+	CommandStub        func(line string) (string, error)
+	commandMutex       sync.RWMutex
+	commandArgsForCall []struct {
+		line string
+	}
+	commandReturns struct {
+		result1 string
+		result2 error
+	}
+}
+
+func (fake *FakeHandler) Disconnect() {
+	fake.disconnectMutex.Lock()
+	fake.disconnectArgsForCall = append(fake.disconnectArgsForCall, struct{}{})
+	fake.disconnectMutex.Unlock()
+	if fake.DisconnectStub != nil {
+		fake.DisconnectStub()
+	}
+}
+
+func (fake *FakeHandler) DisconnectCallCount() int {
+	fake.disconnectMutex.RLock()
+	defer fake.disconnectMutex.RUnlock()
+	return len(fake.disconnectArgsForCall)
+}
+
+func (fake *FakeHandler) Connections() []string {
+	fake.connectionsMutex.Lock()
+	fake.connectionsArgsForCall = append(fake.connectionsArgsForCall, struct{}{})
+	fake.connectionsMutex.Unlock()
+	if fake.ConnectionsStub != nil {
+		return fake.ConnectionsStub()
+	} else {
+		return fake.connectionsReturns.result1
+	}
+}
+
+func (fake *FakeHandler) ConnectionsCallCount() int {
+	fake.connectionsMutex.RLock()
+	defer fake.connectionsMutex.RUnlock()
+	return len(fake.connectionsArgsForCall)
+}
+
+func (fake *FakeHandler) ConnectionsReturns(result1 []string) {
+	fake.ConnectionsStub = nil
+	fake.connectionsReturns = struct {
+		result1 []string
+	}{result1}
+}
+
+func (fake *FakeHandler) Command(line string) (string, error) {
+	fake.commandMutex.Lock()
+	fake.commandArgsForCall = append(fake.commandArgsForCall, struct {
+		line string
+	}{line})
+	fake.commandMutex.Unlock()
+	if fake.CommandStub != nil {
+		return fake.CommandStub(line)
+	} else {
+		return fake.commandReturns.result1, fake.commandReturns.result2
+	}
+}
+
+func (fake *FakeHandler) CommandCallCount() int {
+	fake.commandMutex.RLock()
+	defer fake.commandMutex.RUnlock()
+	return len(fake.commandArgsForCall)
+}
+
+func (fake *FakeHandler) CommandArgsForCall(i int) string {
+	fake.commandMutex.RLock()
+	defer fake.commandMutex.RUnlock()
+	return fake.commandArgsForCall[i].line
+}
+
+func (fake *FakeHandler) CommandReturns(result1 string, result2 error) {
+	fake.CommandStub = nil
+	fake.commandReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+var _ escape.Handler = new(FakeHandler)
@@ -0,0 +1,13 @@
+package escape_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Escape Suite")
+}
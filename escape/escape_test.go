@@ -0,0 +1,139 @@
+package escape_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sykesm/cf-ssh-plugin/escape"
+	"github.com/sykesm/cf-ssh-plugin/escape/escape_fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reader", func() {
+	var (
+		fakeHandler *escape_fakes.FakeHandler
+		out         *bytes.Buffer
+		escapeChar  byte
+	)
+
+	BeforeEach(func() {
+		fakeHandler = &escape_fakes.FakeHandler{}
+		out = &bytes.Buffer{}
+		escapeChar = '~'
+	})
+
+	filter := func(input string) string {
+		reader := escape.NewReader(strings.NewReader(input), out, escapeChar, fakeHandler)
+		output, err := ioutil.ReadAll(reader)
+		Expect(err).NotTo(HaveOccurred())
+		return string(output)
+	}
+
+	It("passes ordinary input through", func() {
+		Expect(filter("ls -l\rcd ~/app\r")).To(Equal("ls -l\rcd ~/app\r"))
+		Expect(out.Len()).To(BeZero())
+	})
+
+	It("only recognizes escapes at the start of a line", func() {
+		Expect(filter("echo ~.\r")).To(Equal("echo ~.\r"))
+		Expect(fakeHandler.DisconnectCallCount()).To(BeZero())
+	})
+
+	It("sends a literal escape character for ~~", func() {
+		Expect(filter("~~/bin\r")).To(Equal("~/bin\r"))
+	})
+
+	It("sends unrecognized escapes unchanged", func() {
+		Expect(filter("~x\r")).To(Equal("~x\r"))
+	})
+
+	It("disconnects on ~.", func() {
+		Expect(filter("ls\r~.ignored")).To(Equal("ls\r"))
+		Expect(fakeHandler.DisconnectCallCount()).To(Equal(1))
+		Expect(out.String()).To(Equal("~.\r\n"))
+	})
+
+	It("prints help on ~?", func() {
+		Expect(filter("~?ls\r")).To(Equal("ls\r"))
+		Expect(out.String()).To(ContainSubstring("Supported escape sequences:"))
+		Expect(out.String()).To(ContainSubstring(" ~.   - terminate connection"))
+	})
+
+	It("lists forwarded connections on ~#", func() {
+		fakeHandler.ConnectionsReturns([]string{"127.0.0.1:50000 -> db:5432"})
+
+		Expect(filter("~#")).To(BeEmpty())
+		Expect(out.String()).To(ContainSubstring("The following connections are open:\r\n  #0 127.0.0.1:50000 -> db:5432\r\n"))
+	})
+
+	Describe("the ~C command line", func() {
+		It("runs the entered command", func() {
+			fakeHandler.CommandReturns("Forwarding port.\n", nil)
+
+			Expect(filter("~C-L 8080:localhost:8080\rls\r")).To(Equal("ls\r"))
+			Expect(fakeHandler.CommandCallCount()).To(Equal(1))
+			Expect(fakeHandler.CommandArgsForCall(0)).To(Equal("-L 8080:localhost:8080"))
+			Expect(out.String()).To(Equal("\r\nssh> -L 8080:localhost:8080\r\nForwarding port.\r\n"))
+		})
+
+		It("reports command errors", func() {
+			fakeHandler.CommandReturns("", errors.New("Invalid command."))
+
+			filter("~Cbogus\r")
+			Expect(out.String()).To(HaveSuffix("Invalid command.\r\n"))
+		})
+
+		It("supports backspace", func() {
+			filter("~C-Lx\x7f 80:h:80\r")
+			Expect(fakeHandler.CommandArgsForCall(0)).To(Equal("-L 80:h:80"))
+		})
+
+		It("is cancelled by ctrl-c", func() {
+			Expect(filter("~C-L\x03ls\r")).To(Equal("ls\r"))
+			Expect(fakeHandler.CommandCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when escapes are disabled", func() {
+		BeforeEach(func() {
+			escapeChar = 0
+		})
+
+		It("passes everything through", func() {
+			Expect(filter("~.~?\r~C")).To(Equal("~.~?\r~C"))
+			Expect(fakeHandler.DisconnectCallCount()).To(BeZero())
+		})
+	})
+
+	Context("with a different escape character", func() {
+		BeforeEach(func() {
+			escapeChar = '%'
+		})
+
+		It("recognizes that character instead", func() {
+			Expect(filter("~.\r%.")).To(Equal("~.\r"))
+			Expect(fakeHandler.DisconnectCallCount()).To(Equal(1))
+		})
+	})
+
+	It("handles reads into small buffers", func() {
+		reader := escape.NewReader(strings.NewReader("~x"), out, '~', fakeHandler)
+
+		p := make([]byte, 1)
+		n, err := reader.Read(p)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(p[:n])).To(Equal("~"))
+
+		n, err = reader.Read(p)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(p[:n])).To(Equal("x"))
+
+		_, err = reader.Read(p)
+		Expect(err).To(Equal(io.EOF))
+	})
+})
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sykesm/cf-ssh-plugin/forwarder"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+)
+
+const escapeCommandHelp = `Commands:
+      -L[bind_address:]port:host:hostport    Request local forward
+      -KL[bind_address:]port                 Cancel local forward
+`
+
+// escapeHandler carries out escape sequences typed during an interactive
+// session against the session's client and port forwarder.
+type escapeHandler struct {
+	client    reconnect.Client
+	forwarder *forwarder.Forwarder
+}
+
+func (h *escapeHandler) Disconnect() {
+	h.client.Close()
+}
+
+func (h *escapeHandler) Connections() []string {
+	return h.forwarder.Connections()
+}
+
+func (h *escapeHandler) Command(line string) (string, error) {
+	line = strings.TrimSpace(line)
+
+	switch {
+	case line == "":
+		return "", nil
+	case line == "?" || line == "help":
+		return escapeCommandHelp, nil
+	case strings.HasPrefix(line, "-KL"):
+		listenAddress, err := options.ParseListenAddress(strings.TrimSpace(line[3:]))
+		if err != nil {
+			return "", err
+		}
+		err = h.forwarder.Cancel(listenAddress)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Canceled forwarding on %s\n", listenAddress), nil
+	case strings.HasPrefix(line, "-L"):
+		spec, err := options.ParseForwardSpec(strings.TrimSpace(line[2:]))
+		if err != nil {
+			return "", err
+		}
+		_, err = h.forwarder.Forward(spec)
+		if err != nil {
+			return "", fmt.Errorf("Failed to forward %s: %s", spec, err)
+		}
+		return "Forwarding port.\n", nil
+	default:
+		return "", errors.New("Invalid command.")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/picker"
+	"github.com/sykesm/cf-ssh-plugin/report"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// execResult is a session's report and the status the plugin exits with
+// when it is the first session that did not succeed.
+type execResult struct {
+	session report.Session
+	status  int
+}
+
+// runExec runs the command on the selected instance, or with -i all on every
+// running instance, capturing each session's output so the results can be
+// reported together.
+//
+// The instances are connected to one at a time, since the CLI serves the
+// plugin's requests one at a time, and the commands then run concurrently.
+func (c *SshPlugin) runExec(opts *options.Options) {
+	a, err := c.AppFactory.Get(appRef(opts), opts.Process)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	var indexes []int
+	if opts.InstanceSelector == options.SelectAll {
+		indexes, err = c.runningInstances(a)
+	} else {
+		err = c.chooseInstance(opts, false)
+		indexes = []int{opts.Instance}
+	}
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	keyring, closeKeyring, err := localKeyring(opts.ForwardAgent)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+	defer closeKeyring()
+
+	logger := c.logger().Session("exec", lager.Data{"app": opts.AppName, "instances": indexes})
+
+	results := make([]execResult, len(indexes))
+	clients := make([]*ssh.Client, len(indexes))
+	for i, index := range indexes {
+		instanceOpts := *opts
+		instanceOpts.Instance = index
+
+		clients[i], err = c.connect(&instanceOpts)
+		if err == nil && keyring != nil {
+			err = agent.ForwardToAgent(clients[i], keyring)
+			if err != nil {
+				clients[i].Close()
+				clients[i] = nil
+			}
+		}
+		if err != nil {
+			results[i] = failedExec(index, err, failures.ExitCode(err))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		if client == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, client *ssh.Client) {
+			defer wg.Done()
+			defer client.Close()
+			results[i] = execOn(client, indexes[i], opts, logger)
+		}(i, client)
+	}
+	wg.Wait()
+
+	sessions := []report.Session{}
+	for _, result := range results {
+		sessions = append(sessions, result.session)
+		if c.exitCode == 0 && !result.session.Succeeded() {
+			c.exitCode = result.status
+			if c.exitCode <= 0 {
+				c.exitCode = failures.ExitConnection
+			}
+		}
+	}
+
+	summary := report.NewExecSummary(a, opts.Command, sessions)
+	if opts.Output == options.OutputJSON {
+		report.Write(os.Stdout, summary)
+		return
+	}
+
+	printExecSummary(os.Stdout, os.Stderr, a.Name, summary)
+}
+
+// runningInstances returns the indexes of the running instances of a.
+func (c *SshPlugin) runningInstances(a app.App) ([]int, error) {
+	var stats []instances.Instance
+	var err error
+	if a.ProcessType == app.WebProcess {
+		stats, err = c.InstancesFactory.Stats(a.Guid)
+	} else {
+		stats, err = c.InstancesFactory.ProcessStats(a.ProcessGuid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return picker.Running(stats)
+}
+
+// execOn runs the command on client without a pty or stdin.
+func execOn(client *ssh.Client, index int, opts *options.Options, logger lager.Logger) execResult {
+	logger = logger.Session("session", lager.Data{"instance": index})
+
+	session, err := client.NewSession()
+	if err != nil {
+		logger.Error("open-failed", err)
+		return failedExec(index, errors.New("Failed to allocate SSH session"), failures.ExitConnection)
+	}
+	defer session.Close()
+
+	sendEnvironment(session, opts.Environment(os.Environ()), logger)
+
+	if opts.ForwardAgent {
+		err = agent.RequestAgentForwarding(session)
+		logger.Debug("agent-forwarding-reply", lager.Data{"accepted": err == nil})
+		if err != nil {
+			return failedExec(index, errors.New("Failed to request agent forwarding"), failures.ExitConnection)
+		}
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	start := time.Now()
+	err = session.Start(opts.Command)
+	logger.Debug("exec-reply", lager.Data{"accepted": err == nil})
+	if err != nil {
+		return failedExec(index, errors.New("Failed to run command"), failures.ExitConnection)
+	}
+
+	err = session.Wait()
+	return newExecResult(index, err, stdout.Bytes(), stderr.Bytes(), time.Since(start))
+}
+
+// failedExec describes a session that could not be run.
+func failedExec(index int, err error, status int) execResult {
+	return execResult{session: report.NewSession(index, 0, "", err, nil, nil, 0), status: status}
+}
+
+// newExecResult describes how a session ended from the error waiting on it
+// returned, with the same exit statuses as an interactive session.
+func newExecResult(index int, err error, stdout, stderr []byte, duration time.Duration) execResult {
+	switch exitErr := err.(type) {
+	case nil:
+		return execResult{session: report.NewSession(index, 0, "", nil, stdout, stderr, duration)}
+	case *ssh.ExitError:
+		return execResult{
+			session: report.NewSession(index, exitErr.ExitStatus(), exitErr.Signal(), nil, stdout, stderr, duration),
+			status:  exitErr.ExitStatus(),
+		}
+	case *ssh.ExitMissingError:
+		err = errors.New("Connection closed without an exit status")
+		return execResult{session: report.NewSession(index, 0, "", err, stdout, stderr, duration), status: failures.ExitConnection}
+	default:
+		return execResult{session: report.NewSession(index, 0, "", err, stdout, stderr, duration), status: failures.ExitConnection}
+	}
+}
+
+// printExecSummary writes each session's output under a header naming the
+// instance and how the command ended, then a count of the sessions that
+// succeeded.
+func printExecSummary(stdout, stderr io.Writer, appName string, summary report.ExecSummary) {
+	for _, session := range summary.Sessions {
+		var outcome string
+		switch {
+		case session.Error != nil:
+			outcome = "failed: " + session.Error.Message
+		case session.Signal != "":
+			outcome = "terminated by signal " + session.Signal
+		default:
+			outcome = fmt.Sprintf("exited with status %d", *session.ExitStatus)
+		}
+
+		fmt.Fprintf(stdout, "==> %s/%d %s <==\n", appName, session.Instance, outcome)
+		io.WriteString(stdout, session.Stdout)
+		io.WriteString(stderr, session.Stderr)
+	}
+
+	fmt.Fprintf(stdout, "%d of %d instances succeeded\n", summary.Succeeded, len(summary.Sessions))
+}
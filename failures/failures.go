@@ -0,0 +1,281 @@
+package failures
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Kind int
+
+const (
+	AppNotFoundKind Kind = iota + 1
+	ProcessNotFoundKind
+	OrgNotFoundKind
+	SpaceNotFoundKind
+	AmbiguousKind
+	NotDiegoKind
+	SSHDisabledKind
+	AppNotStartedKind
+	SpaceDisallowedKind
+	InstanceOutOfRangeKind
+	EndpointUnreachableKind
+	HostKeyMismatchKind
+	AuthRejectedKind
+	APIRequestKind
+	LoginRequiredKind
+	InvalidUsageKind
+)
+
+var kindNames = map[Kind]string{
+	AppNotFoundKind:         "app_not_found",
+	ProcessNotFoundKind:     "process_not_found",
+	OrgNotFoundKind:         "org_not_found",
+	SpaceNotFoundKind:       "space_not_found",
+	AmbiguousKind:           "ambiguous",
+	NotDiegoKind:            "not_diego",
+	SSHDisabledKind:         "ssh_disabled",
+	AppNotStartedKind:       "app_not_started",
+	SpaceDisallowedKind:     "space_disallowed",
+	InstanceOutOfRangeKind:  "instance_out_of_range",
+	EndpointUnreachableKind: "endpoint_unreachable",
+	HostKeyMismatchKind:     "host_key_mismatch",
+	AuthRejectedKind:        "auth_rejected",
+	APIRequestKind:          "api_request",
+	LoginRequiredKind:       "login_required",
+	InvalidUsageKind:        "invalid_usage",
+}
+
+// String names the kind in machine-readable output.
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Exit codes follow ssh: problems reaching or authenticating with the
+// endpoint exit with 255, while problems with the app or space reported by
+// the API exit with 1 as the cf CLI does.
+const (
+	ExitFailure    = 1
+	ExitConnection = 255
+)
+
+// Error is a failure the user can usually fix. It carries a hint describing
+// how, and the exit status the plugin should end with.
+type Error struct {
+	Kind    Kind
+	Message string
+	Hint    string
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func (e *Error) ExitCode() int {
+	switch e.Kind {
+	case EndpointUnreachableKind, HostKeyMismatchKind, AuthRejectedKind:
+		return ExitConnection
+	default:
+		return ExitFailure
+	}
+}
+
+func AppNotFound(message string) *Error {
+	return &Error{
+		Kind:    AppNotFoundKind,
+		Message: message,
+		Hint:    "Check the app name with 'cf apps' and the targeted org and space with 'cf target'.",
+	}
+}
+
+func ProcessNotFound(appName, processType string) *Error {
+	return &Error{
+		Kind:    ProcessNotFoundKind,
+		Message: fmt.Sprintf("App %s has no %s process", appName, processType),
+		Hint:    fmt.Sprintf("List the processes of the app with 'cf app %s'.", appName),
+	}
+}
+
+func OrgNotFound(orgName string) *Error {
+	return &Error{
+		Kind:    OrgNotFoundKind,
+		Message: fmt.Sprintf("Org %s is not found", orgName),
+		Hint:    "Check the org name with 'cf orgs'.",
+	}
+}
+
+func SpaceNotFound(spaceName string) *Error {
+	return &Error{
+		Kind:    SpaceNotFoundKind,
+		Message: fmt.Sprintf("Space %s is not found", spaceName),
+		Hint:    "Check the space name with 'cf spaces', and the org with --org.",
+	}
+}
+
+// Ambiguous reports that what names more than one resource, listing them so
+// the user can choose.
+func Ambiguous(what string, matches []string, hint string) *Error {
+	return &Error{
+		Kind:    AmbiguousKind,
+		Message: fmt.Sprintf("%s is ambiguous; it matches %s", what, strings.Join(matches, ", ")),
+		Hint:    hint,
+	}
+}
+
+func NotDiego(appName string) *Error {
+	return &Error{
+		Kind:    NotDiegoKind,
+		Message: fmt.Sprintf("App %s is not running on Diego", appName),
+		Hint:    "SSH is only available to apps running on Diego.",
+	}
+}
+
+func SSHDisabled(appName string) *Error {
+	return &Error{
+		Kind:    SSHDisabledKind,
+		Message: fmt.Sprintf("SSH is disabled for app %s", appName),
+		Hint:    fmt.Sprintf("Enable it with 'cf enable-ssh %s' and restart the app.", appName),
+	}
+}
+
+func AppNotStarted(appName, state string) *Error {
+	return &Error{
+		Kind:    AppNotStartedKind,
+		Message: fmt.Sprintf("App %s is %s", appName, state),
+		Hint:    fmt.Sprintf("Start it with 'cf start %s'.", appName),
+	}
+}
+
+func SpaceDisallowed(spaceName string) *Error {
+	return &Error{
+		Kind:    SpaceDisallowedKind,
+		Message: fmt.Sprintf("SSH is disabled in space %s", spaceName),
+		Hint:    fmt.Sprintf("Ask a space manager to run 'cf allow-space-ssh %s'.", spaceName),
+	}
+}
+
+func InstanceOutOfRange(appName string, index, instances int) *Error {
+	return &Error{
+		Kind:    InstanceOutOfRangeKind,
+		Message: fmt.Sprintf("Instance %d of app %s does not exist; the app has %d instances", index, appName, instances),
+		Hint:    fmt.Sprintf("Choose an instance between 0 and %d with -i.", instances-1),
+	}
+}
+
+func EndpointUnreachable(endpoint string, cause error) *Error {
+	return &Error{
+		Kind:    EndpointUnreachableKind,
+		Message: fmt.Sprintf("Unable to reach the SSH endpoint %s", endpoint),
+		Hint:    "Check the network path to the endpoint, any --proxy or -J settings, or override it with --ssh-endpoint.",
+		Cause:   cause,
+	}
+}
+
+func HostKeyMismatch(expected, actual string) *Error {
+	return &Error{
+		Kind:    HostKeyMismatchKind,
+		Message: fmt.Sprintf("Host fingerprint does not match: expected %s, got %s", expected, actual),
+		Hint:    "Confirm the fingerprint advertised by 'cf curl /v2/info', or pass the expected one with --ssh-fingerprint.",
+	}
+}
+
+func AuthRejected(cause error) *Error {
+	return &Error{
+		Kind:    AuthRejectedKind,
+		Message: "Authentication with the SSH endpoint failed",
+		Hint:    "Log in again with 'cf login', or check that your user is a space developer.",
+		Cause:   cause,
+	}
+}
+
+// APIRequest reports a Cloud Controller request that failed or whose
+// response could not be understood.
+func APIRequest(message string, cause error) *Error {
+	return &Error{
+		Kind:    APIRequestKind,
+		Message: message,
+		Hint:    "Check the API endpoint with 'cf api', and run the command again with -v for details.",
+		Cause:   cause,
+	}
+}
+
+func LoginRequired() *Error {
+	return &Error{
+		Kind:    LoginRequiredKind,
+		Message: "Your session has expired. Please log in again with 'cf login'.",
+		Hint:    "Log in again with 'cf login', or configure a credential source for unattended use.",
+	}
+}
+
+// InvalidUsage reports arguments to command that could not be parsed.
+func InvalidUsage(cause error, command string) *Error {
+	return &Error{
+		Kind:    InvalidUsageKind,
+		Message: "Invalid usage",
+		Hint:    fmt.Sprintf("Run 'cf %s -h' to see its usage.", command),
+		Cause:   cause,
+	}
+}
+
+// Permanent reports whether err is, or wraps, a failure that trying again
+// will not fix: the app, its space, or the user's access has to change
+// first. Unreachable endpoints, failed API requests, and apps that are
+// stopped or scaled down, as during a restart, are not permanent.
+func Permanent(err error) bool {
+	var failure *Error
+	if !errors.As(err, &failure) {
+		return false
+	}
+
+	switch failure.Kind {
+	case AppNotFoundKind, ProcessNotFoundKind, OrgNotFoundKind, SpaceNotFoundKind, AmbiguousKind,
+		NotDiegoKind, SSHDisabledKind, SpaceDisallowedKind, HostKeyMismatchKind, AuthRejectedKind, LoginRequiredKind:
+		return true
+	default:
+		return false
+	}
+}
+
+// Is reports whether err is, or wraps, a failure of the given kind.
+func Is(err error, kind Kind) bool {
+	var failure *Error
+	return errors.As(err, &failure) && failure.Kind == kind
+}
+
+// ExitCode returns the exit status for err: the failure's own code when err
+// is or wraps a failure, ExitFailure for any other error, and 0 for nil.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var failure *Error
+	if errors.As(err, &failure) {
+		return failure.ExitCode()
+	}
+	return ExitFailure
+}
+
+// Render writes err the way the cf CLI reports failures, followed by the
+// hint of the first failure it wraps.
+func Render(w io.Writer, err error) {
+	fmt.Fprintln(w, "FAILED")
+	fmt.Fprintln(w, err)
+
+	var failure *Error
+	if errors.As(err, &failure) && failure.Hint != "" {
+		fmt.Fprintln(w, "TIP:", failure.Hint)
+	}
+}
@@ -0,0 +1,13 @@
+package failures_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFailures(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Failures Suite")
+}
@@ -0,0 +1,124 @@
+package failures_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/sykesm/cf-ssh-plugin/failures"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Failures", func() {
+	Describe("Error", func() {
+		It("includes the cause in the message", func() {
+			cause := errors.New("connection refused")
+			err := failures.EndpointUnreachable("ssh.example.com:2222", cause)
+
+			Expect(err).To(MatchError("Unable to reach the SSH endpoint ssh.example.com:2222: connection refused"))
+			Expect(errors.Is(err, cause)).To(BeTrue())
+		})
+
+		It("omits a missing cause", func() {
+			Expect(failures.SSHDisabled("app1")).To(MatchError("SSH is disabled for app app1"))
+		})
+	})
+
+	Describe("ExitCode", func() {
+		It("exits with 255 for connection failures", func() {
+			Expect(failures.ExitCode(failures.EndpointUnreachable("ssh.example.com:2222", nil))).To(Equal(255))
+			Expect(failures.ExitCode(failures.HostKeyMismatch("aa", "bb"))).To(Equal(255))
+			Expect(failures.ExitCode(failures.AuthRejected(nil))).To(Equal(255))
+		})
+
+		It("exits with 1 for app and space failures", func() {
+			Expect(failures.ExitCode(failures.AppNotFound("App app1 is not found"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.ProcessNotFound("app1", "worker"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.OrgNotFound("org1"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.SpaceNotFound("development"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.Ambiguous("Space development", []string{"org1", "org2"}, ""))).To(Equal(1))
+			Expect(failures.ExitCode(failures.NotDiego("app1"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.AppNotStarted("app1", "STOPPED"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.SpaceDisallowed("development"))).To(Equal(1))
+			Expect(failures.ExitCode(failures.InstanceOutOfRange("app1", 3, 2))).To(Equal(1))
+			Expect(failures.ExitCode(failures.APIRequest("Failed to acquire space info", nil))).To(Equal(1))
+			Expect(failures.ExitCode(failures.LoginRequired())).To(Equal(1))
+		})
+
+		It("exits with 1 for other errors", func() {
+			Expect(failures.ExitCode(errors.New("woops"))).To(Equal(1))
+		})
+
+		It("exits with 0 without an error", func() {
+			Expect(failures.ExitCode(nil)).To(Equal(0))
+		})
+
+		It("finds wrapped failures", func() {
+			err := fmt.Errorf("ssh: handshake failed: %w", failures.HostKeyMismatch("aa", "bb"))
+			Expect(failures.ExitCode(err)).To(Equal(255))
+			Expect(failures.Is(err, failures.HostKeyMismatchKind)).To(BeTrue())
+			Expect(failures.Is(err, failures.AuthRejectedKind)).To(BeFalse())
+		})
+	})
+
+	Describe("Kind", func() {
+		It("has a name for machine-readable output", func() {
+			Expect(failures.SSHDisabledKind.String()).To(Equal("ssh_disabled"))
+			Expect(failures.LoginRequiredKind.String()).To(Equal("login_required"))
+			Expect(failures.Kind(0).String()).To(Equal("unknown"))
+		})
+	})
+
+	Describe("InvalidUsage", func() {
+		It("wraps the parse error and points at the command's usage", func() {
+			err := failures.InvalidUsage(errors.New("Flag 'L' is not supported by cf ssh-info"), "ssh-info")
+			Expect(err).To(MatchError("Invalid usage: Flag 'L' is not supported by cf ssh-info"))
+			Expect(err.Hint).To(Equal("Run 'cf ssh-info -h' to see its usage."))
+			Expect(err.Kind.String()).To(Equal("invalid_usage"))
+			Expect(failures.ExitCode(err)).To(Equal(1))
+		})
+	})
+
+	Describe("Permanent", func() {
+		It("holds for failures that need the app, space, or user to change", func() {
+			Expect(failures.Permanent(failures.AppNotFound("App app1 is not found"))).To(BeTrue())
+			Expect(failures.Permanent(failures.SSHDisabled("app1"))).To(BeTrue())
+			Expect(failures.Permanent(failures.SpaceDisallowed("development"))).To(BeTrue())
+			Expect(failures.Permanent(failures.HostKeyMismatch("aa", "bb"))).To(BeTrue())
+			Expect(failures.Permanent(fmt.Errorf("refresh: %w", failures.LoginRequired()))).To(BeTrue())
+		})
+
+		It("does not hold for failures that may clear up", func() {
+			Expect(failures.Permanent(failures.EndpointUnreachable("ssh.example.com:2222", nil))).To(BeFalse())
+			Expect(failures.Permanent(failures.APIRequest("Failed to acquire space info", nil))).To(BeFalse())
+			Expect(failures.Permanent(failures.AppNotStarted("app1", "STOPPED"))).To(BeFalse())
+			Expect(failures.Permanent(failures.InstanceOutOfRange("app1", 3, 2))).To(BeFalse())
+			Expect(failures.Permanent(errors.New("connection reset"))).To(BeFalse())
+		})
+	})
+
+	Describe("Render", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+		})
+
+		It("renders the message and hint", func() {
+			failures.Render(out, failures.SSHDisabled("app1"))
+			Expect(out.String()).To(Equal("FAILED\nSSH is disabled for app app1\nTIP: Enable it with 'cf enable-ssh app1' and restart the app.\n"))
+		})
+
+		It("lists the matches of an ambiguous name", func() {
+			failures.Render(out, failures.Ambiguous("Space development", []string{"org1", "org2"}, "Choose the org with --org."))
+			Expect(out.String()).To(Equal("FAILED\nSpace development is ambiguous; it matches org1, org2\nTIP: Choose the org with --org.\n"))
+		})
+
+		It("renders other errors without a hint", func() {
+			failures.Render(out, errors.New("woops"))
+			Expect(out.String()).To(Equal("FAILED\nwoops\n"))
+		})
+	})
+})
@@ -0,0 +1,198 @@
+package forwarder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sykesm/cf-ssh-plugin/options"
+)
+
+var errClosed = errors.New("forwarder is closed")
+
+//go:generate counterfeiter -o forwarder_fakes/fake_dialer.go . Dialer
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Forwarder accepts connections on local listeners and relays them to the
+// connect address of their forward spec through a Dialer, typically an
+// *ssh.Client.
+type Forwarder struct {
+	dialer Dialer
+
+	connections int64
+	bytesIn     int64
+	bytesOut    int64
+
+	lock     sync.Mutex
+	forwards []*forward
+	active   map[net.Conn]string
+	closed   bool
+}
+
+type forward struct {
+	spec     options.ForwardSpec
+	listener net.Listener
+}
+
+// Stats are running totals across every forwarded connection. BytesIn
+// counts data received from the remote end and BytesOut data sent to it.
+type Stats struct {
+	Connections int64
+	BytesIn     int64
+	BytesOut    int64
+}
+
+func New(dialer Dialer) *Forwarder {
+	return &Forwarder{
+		dialer: dialer,
+		active: map[net.Conn]string{},
+	}
+}
+
+func (f *Forwarder) Stats() Stats {
+	return Stats{
+		Connections: atomic.LoadInt64(&f.connections),
+		BytesIn:     atomic.LoadInt64(&f.bytesIn),
+		BytesOut:    atomic.LoadInt64(&f.bytesOut),
+	}
+}
+
+func (f *Forwarder) Forward(spec options.ForwardSpec) (net.Listener, error) {
+	listener, err := net.Listen("tcp", spec.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		listener.Close()
+		return nil, errClosed
+	}
+	f.forwards = append(f.forwards, &forward{spec: spec, listener: listener})
+	f.lock.Unlock()
+
+	go f.acceptLoop(listener, spec.ConnectAddress)
+
+	return listener, nil
+}
+
+// Cancel stops listening on the given listen address. Connections that were
+// already accepted are left alone.
+func (f *Forwarder) Cancel(listenAddress string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, fwd := range f.forwards {
+		if fwd.spec.ListenAddress == listenAddress || fwd.listener.Addr().String() == listenAddress {
+			fwd.listener.Close()
+			f.forwards = append(f.forwards[:i], f.forwards[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown port forwarding for %s", listenAddress)
+}
+
+// Forwards returns the specs currently being listened on.
+func (f *Forwarder) Forwards() []options.ForwardSpec {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	specs := []options.ForwardSpec{}
+	for _, fwd := range f.forwards {
+		specs = append(specs, fwd.spec)
+	}
+	return specs
+}
+
+// Connections describes each open forwarded connection as the local peer
+// and the remote address it is connected to.
+func (f *Forwarder) Connections() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	connections := []string{}
+	for conn, connectAddress := range f.active {
+		connections = append(connections, fmt.Sprintf("%s -> %s", conn.RemoteAddr(), connectAddress))
+	}
+	sort.Strings(connections)
+	return connections
+}
+
+func (f *Forwarder) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closed = true
+	for _, fwd := range f.forwards {
+		fwd.listener.Close()
+	}
+	f.forwards = nil
+
+	return nil
+}
+
+func (f *Forwarder) acceptLoop(listener net.Listener, connectAddress string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go f.handleConnection(conn, connectAddress)
+	}
+}
+
+func (f *Forwarder) handleConnection(conn net.Conn, connectAddress string) {
+	defer conn.Close()
+
+	remote, err := f.dialer.Dial("tcp", connectAddress)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	atomic.AddInt64(&f.connections, 1)
+
+	f.lock.Lock()
+	f.active[conn] = connectAddress
+	f.lock.Unlock()
+
+	defer func() {
+		f.lock.Lock()
+		delete(f.active, conn)
+		f.lock.Unlock()
+	}()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+
+	go copyAndClose(wg, remote, &countingWriter{writer: remote, count: &f.bytesOut}, conn)
+	go copyAndClose(wg, conn, &countingWriter{writer: conn, count: &f.bytesIn}, remote)
+
+	wg.Wait()
+}
+
+func copyAndClose(wg *sync.WaitGroup, dest io.Closer, writer io.Writer, src io.Reader) {
+	io.Copy(writer, src)
+	dest.Close()
+	wg.Done()
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	atomic.AddInt64(w.count, int64(n))
+	return n, err
+}
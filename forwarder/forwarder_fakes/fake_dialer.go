@@ -0,0 +1,58 @@
+// This file was generated by counterfeiter
+package forwarder_fakes
+
+import (
+	"net"
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/forwarder"
+)
+
+type FakeDialer struct {
+	DialStub        func(network, address string) (net.Conn, error)
+	dialMutex       sync.RWMutex
+	dialArgsForCall []struct {
+		network string
+		address string
+	}
+	dialReturns struct {
+		result1 net.Conn
+		result2 error
+	}
+}
+
+func (fake *FakeDialer) Dial(network string, address string) (net.Conn, error) {
+	fake.dialMutex.Lock()
+	fake.dialArgsForCall = append(fake.dialArgsForCall, struct {
+		network string
+		address string
+	}{network, address})
+	fake.dialMutex.Unlock()
+	if fake.DialStub != nil {
+		return fake.DialStub(network, address)
+	} else {
+		return fake.dialReturns.result1, fake.dialReturns.result2
+	}
+}
+
+func (fake *FakeDialer) DialCallCount() int {
+	fake.dialMutex.RLock()
+	defer fake.dialMutex.RUnlock()
+	return len(fake.dialArgsForCall)
+}
+
+func (fake *FakeDialer) DialArgsForCall(i int) (string, string) {
+	fake.dialMutex.RLock()
+	defer fake.dialMutex.RUnlock()
+	return fake.dialArgsForCall[i].network, fake.dialArgsForCall[i].address
+}
+
+func (fake *FakeDialer) DialReturns(result1 net.Conn, result2 error) {
+	fake.DialStub = nil
+	fake.dialReturns = struct {
+		result1 net.Conn
+		result2 error
+	}{result1, result2}
+}
+
+var _ forwarder.Dialer = new(FakeDialer)
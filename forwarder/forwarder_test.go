@@ -0,0 +1,178 @@
+package forwarder_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/sykesm/cf-ssh-plugin/forwarder"
+	"github.com/sykesm/cf-ssh-plugin/forwarder/forwarder_fakes"
+	"github.com/sykesm/cf-ssh-plugin/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarder", func() {
+	var (
+		fakeDialer   *forwarder_fakes.FakeDialer
+		echoListener net.Listener
+		fwd          *forwarder.Forwarder
+		spec         options.ForwardSpec
+	)
+
+	BeforeEach(func() {
+		var err error
+		echoListener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				conn, err := echoListener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					io.Copy(conn, conn)
+					conn.Close()
+				}()
+			}
+		}()
+
+		fakeDialer = &forwarder_fakes.FakeDialer{}
+		fakeDialer.DialStub = func(network, address string) (net.Conn, error) {
+			return net.Dial(network, echoListener.Addr().String())
+		}
+
+		spec = options.ForwardSpec{
+			ListenAddress:  "127.0.0.1:0",
+			ConnectAddress: "db.internal:5432",
+		}
+
+		fwd = forwarder.New(fakeDialer)
+	})
+
+	AfterEach(func() {
+		fwd.Close()
+		echoListener.Close()
+	})
+
+	It("relays connections through the dialer to the connect address", func() {
+		listener, err := fwd.Forward(spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("hello\n"))
+
+		Expect(fakeDialer.DialCallCount()).To(Equal(1))
+		network, address := fakeDialer.DialArgsForCall(0)
+		Expect(network).To(Equal("tcp"))
+		Expect(address).To(Equal("db.internal:5432"))
+	})
+
+	It("counts connections and bytes in each direction", func() {
+		listener, err := fwd.Forward(spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = bufio.NewReader(conn).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(fwd.Stats).Should(Equal(forwarder.Stats{
+			Connections: 1,
+			BytesIn:     6,
+			BytesOut:    6,
+		}))
+	})
+
+	It("lists the open connections", func() {
+		listener, err := fwd.Forward(spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(fwd.Connections).Should(ConsistOf(conn.LocalAddr().String() + " -> db.internal:5432"))
+
+		conn.Close()
+		Eventually(fwd.Connections).Should(BeEmpty())
+	})
+
+	Describe("Cancel", func() {
+		It("stops listening on the forward's listen address", func() {
+			listener, err := fwd.Forward(spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fwd.Forwards()).To(ConsistOf(spec))
+
+			Expect(fwd.Cancel(listener.Addr().String())).To(Succeed())
+			Expect(fwd.Forwards()).To(BeEmpty())
+
+			_, err = net.Dial("tcp", listener.Addr().String())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("matches the listen address of the spec", func() {
+			_, err := fwd.Forward(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fwd.Cancel("127.0.0.1:0")).To(Succeed())
+			Expect(fwd.Forwards()).To(BeEmpty())
+		})
+
+		It("fails for an unknown listen address", func() {
+			Expect(fwd.Cancel("localhost:1")).To(MatchError("Unknown port forwarding for localhost:1"))
+		})
+	})
+
+	Context("when the dialer fails", func() {
+		BeforeEach(func() {
+			fakeDialer.DialReturns(nil, errors.New("woops"))
+		})
+
+		It("closes the local connection", func() {
+			listener, err := fwd.Forward(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	Context("when the listen address is in use", func() {
+		It("returns an error", func() {
+			_, err := fwd.Forward(options.ForwardSpec{ListenAddress: echoListener.Addr().String()})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the forwarder is closed", func() {
+		It("stops accepting connections", func() {
+			listener, err := fwd.Forward(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			fwd.Close()
+
+			_, err = net.Dial("tcp", listener.Addr().String())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package handshake
+
+import (
+	"net"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// authRejectedError marks a handshake that the endpoint refused during
+// authentication.
+type authRejectedError struct {
+	error
+}
+
+// AuthRejected reports whether err is from a handshake whose credentials
+// the endpoint refused.
+func AuthRejected(err error) bool {
+	_, ok := err.(authRejectedError)
+	return ok
+}
+
+// NewClientConn performs the SSH handshake on conn like ssh.NewClientConn.
+// A handshake that fails after the host key was accepted, while the
+// connection itself kept working, was refused during authentication; its
+// error is marked for AuthRejected, which does not depend on the wording of
+// x/crypto's errors. A nil HostKeyCallback accepts any host key.
+func NewClientConn(conn net.Conn, address string, config *ssh.ClientConfig) (ssh.Conn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	var keyAccepted int32
+	hostKeyCallback := config.HostKeyCallback
+
+	handshakeConfig := *config
+	handshakeConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if hostKeyCallback != nil {
+			err := hostKeyCallback(hostname, remote, key)
+			if err != nil {
+				return err
+			}
+		}
+		atomic.StoreInt32(&keyAccepted, 1)
+		return nil
+	}
+
+	watched := &watchedConn{Conn: conn}
+	sshConn, chans, reqs, err := ssh.NewClientConn(watched, address, &handshakeConfig)
+	if err != nil && atomic.LoadInt32(&keyAccepted) == 1 && !watched.Failed() {
+		err = authRejectedError{err}
+	}
+
+	return sshConn, chans, reqs, err
+}
+
+// watchedConn records whether reading or writing failed before the
+// connection was closed, which tells a dropped connection from a refused
+// credential.
+type watchedConn struct {
+	net.Conn
+	closed int32
+	failed int32
+}
+
+func (c *watchedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.record(err)
+	return n, err
+}
+
+func (c *watchedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.record(err)
+	return n, err
+}
+
+func (c *watchedConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return c.Conn.Close()
+}
+
+func (c *watchedConn) Failed() bool {
+	return atomic.LoadInt32(&c.failed) == 1
+}
+
+func (c *watchedConn) record(err error) {
+	if err != nil && atomic.LoadInt32(&c.closed) == 0 {
+		atomic.StoreInt32(&c.failed, 1)
+	}
+}
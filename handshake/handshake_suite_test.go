@@ -0,0 +1,13 @@
+package handshake_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Handshake Suite")
+}
@@ -0,0 +1,126 @@
+package handshake_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net"
+
+	"github.com/sykesm/cf-ssh-plugin/handshake"
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handshake", func() {
+	var (
+		serverConfig *ssh.ServerConfig
+		clientConfig *ssh.ClientConfig
+		listener     net.Listener
+		serverConn   net.Conn
+		clientConn   net.Conn
+	)
+
+	BeforeEach(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		hostSigner, err := ssh.NewSignerFromKey(key)
+		Expect(err).NotTo(HaveOccurred())
+
+		serverConfig = &ssh.ServerConfig{
+			PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				if string(password) == "bearer token" {
+					return nil, nil
+				}
+				return nil, errors.New("rejected")
+			},
+		}
+		serverConfig.AddHostKey(hostSigner)
+
+		clientConfig = &ssh.ClientConfig{
+			User: "cf:app-guid/0",
+			Auth: []ssh.AuthMethod{ssh.Password("bearer token")},
+		}
+
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+			ssh.NewServerConn(conn, serverConfig)
+		}()
+
+		var err error
+		clientConn, err = net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		serverConn = <-accepted
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		clientConn.Close()
+		serverConn.Close()
+	})
+
+	It("connects when the endpoint accepts the credential", func() {
+		sshConn, _, _, err := handshake.NewClientConn(clientConn, "ssh.example.com:2222", clientConfig)
+		Expect(err).NotTo(HaveOccurred())
+		sshConn.Close()
+	})
+
+	Context("when the endpoint refuses the credential", func() {
+		BeforeEach(func() {
+			clientConfig.Auth = []ssh.AuthMethod{ssh.Password("bearer expired")}
+		})
+
+		It("reports the rejection", func() {
+			_, _, _, err := handshake.NewClientConn(clientConn, "ssh.example.com:2222", clientConfig)
+			Expect(err).To(HaveOccurred())
+			Expect(handshake.AuthRejected(err)).To(BeTrue())
+		})
+	})
+
+	Context("when the host key is refused", func() {
+		BeforeEach(func() {
+			clientConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+				return errors.New("host key mismatch")
+			}
+		})
+
+		It("does not report a rejected credential", func() {
+			_, _, _, err := handshake.NewClientConn(clientConn, "ssh.example.com:2222", clientConfig)
+			Expect(err).To(HaveOccurred())
+			Expect(handshake.AuthRejected(err)).To(BeFalse())
+		})
+	})
+
+	Context("when the connection drops during authentication", func() {
+		BeforeEach(func() {
+			serverConfig.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				serverConn.Close()
+				return nil, errors.New("dropped")
+			}
+		})
+
+		It("does not report a rejected credential", func() {
+			_, _, _, err := handshake.NewClientConn(clientConn, "ssh.example.com:2222", clientConfig)
+			Expect(err).To(HaveOccurred())
+			Expect(handshake.AuthRejected(err)).To(BeFalse())
+		})
+	})
+
+	It("does not mistake other errors for a rejected credential", func() {
+		Expect(handshake.AuthRejected(errors.New("ssh: unable to authenticate"))).To(BeFalse())
+		Expect(handshake.AuthRejected(nil)).To(BeFalse())
+	})
+})
@@ -0,0 +1,19 @@
+// Package homedir locates the current user's home directory the way the cf
+// CLI does, for packages that sit below config.
+package homedir
+
+import (
+	"os"
+	"runtime"
+)
+
+// Dir returns the current user's home directory.
+func Dir() string {
+	if runtime.GOOS == "windows" {
+		if home := os.Getenv("USERPROFILE"); home != "" {
+			return home
+		}
+		return os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
+	}
+	return os.Getenv("HOME")
+}
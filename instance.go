@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/picker"
+)
+
+// chooseInstance resolves the instance selector in opts to an index. When
+// -i was omitted, the instances are offered in a picker if prompt is set and
+// there is more than one; otherwise instance 0 is used as before.
+func (c *SshPlugin) chooseInstance(opts *options.Options, prompt bool) error {
+	selector := opts.InstanceSelector
+	if selector == options.SelectAll {
+		return errors.New("Value all for flag 'i' is only supported when running a command with cf ssh")
+	}
+	opts.InstanceSelector = options.SelectIndex
+
+	if selector == options.SelectIndex || selector == options.SelectPrompt && !prompt {
+		return nil
+	}
+
+	a, err := c.AppFactory.Get(appRef(opts), opts.Process)
+	if err != nil {
+		return err
+	}
+
+	if selector == options.SelectPrompt && a.Instances <= 1 {
+		return nil
+	}
+
+	// The v2 statistics only cover the web process; the others are read
+	// from the v3 process, so every selector sees which instances run.
+	var stats []instances.Instance
+	if a.ProcessType == app.WebProcess {
+		stats, err = c.InstancesFactory.Stats(a.Guid)
+	} else {
+		stats, err = c.InstancesFactory.ProcessStats(a.ProcessGuid)
+	}
+	if err != nil {
+		return err
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	switch selector {
+	case options.SelectPrompt:
+		fmt.Printf("Instances of %s:\n\n", a.Name)
+		opts.Instance, err = picker.Prompt(os.Stdin, os.Stdout, stats)
+	case options.SelectRandom:
+		opts.Instance, err = picker.Random(stats, random.Intn)
+	case options.SelectLeastLoaded:
+		opts.Instance, err = picker.LeastLoaded(stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	if selector != options.SelectPrompt {
+		fmt.Fprintf(os.Stderr, "Using instance %d\n", opts.Instance)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package jump
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/proxy"
+)
+
+// IdentityFiles are the private keys, relative to the ~/.ssh directory,
+// offered to a jump host after any keys held by the local agent.
+var IdentityFiles = []string{"id_rsa", "id_ecdsa", "id_ed25519"}
+
+// Connect authenticates to the jump host with the keys from the local agent
+// and ~/.ssh, verifying its host key against ~/.ssh/known_hosts. The target
+// is then reached with the returned client's Dial, which opens a
+// direct-tcpip channel.
+func Connect(host options.JumpHost, dialer proxy.DialFunc, handshakeTimeout time.Duration) (*ssh.Client, error) {
+	sshDir := filepath.Join(config.HomeDir(), ".ssh")
+
+	hostKeyCallback, err := knownhosts.New(filepath.Join(sshDir, "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load known hosts for jump host %s: %s", host, err)
+	}
+
+	auth, closeAgent := authMethods(sshDir)
+	defer closeAgent()
+
+	username := host.User
+	if username == "" {
+		username = currentUser()
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	conn, err := dialer("tcp", host.Address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to jump host %s: %s", host, err)
+	}
+
+	if handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, host.Address, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to connect to jump host %s: %s", host, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+func authMethods(sshDir string) ([]ssh.AuthMethod, func()) {
+	signers := []ssh.Signer{}
+	closeAgent := func() {}
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			closeAgent = func() { conn.Close() }
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+
+	for _, name := range IdentityFiles {
+		key, err := ioutil.ReadFile(filepath.Join(sshDir, name))
+		if err != nil {
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, closeAgent
+}
+
+func currentUser() string {
+	name := os.Getenv("USER")
+	if runtime.GOOS == "windows" {
+		name = os.Getenv("USERNAME")
+	}
+	if name != "" {
+		return name
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
@@ -0,0 +1,13 @@
+package jump_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestJump(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jump Suite")
+}
@@ -0,0 +1,223 @@
+package jump_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/jump"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/proxy"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jump", func() {
+	var (
+		home             string
+		savedHome        string
+		savedUserProfile string
+		savedAuthSock    string
+
+		hostSigner ssh.Signer
+		bastion    net.Listener
+		target     net.Listener
+		jumpHost   options.JumpHost
+	)
+
+	newSigner := func() (ssh.Signer, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalECPrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+		signer, err := ssh.ParsePrivateKey(keyPEM)
+		Expect(err).NotTo(HaveOccurred())
+		return signer, keyPEM
+	}
+
+	writeKnownHosts := func(key ssh.PublicKey) {
+		line := knownhosts.Line([]string{bastion.Addr().String()}, key)
+		Expect(ioutil.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "jump")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Mkdir(filepath.Join(home, ".ssh"), 0700)).To(Succeed())
+
+		savedHome = os.Getenv("HOME")
+		savedUserProfile = os.Getenv("USERPROFILE")
+		savedAuthSock = os.Getenv("SSH_AUTH_SOCK")
+		os.Setenv("HOME", home)
+		os.Setenv("USERPROFILE", home)
+		os.Setenv("SSH_AUTH_SOCK", "")
+
+		clientSigner, clientKey := newSigner()
+		Expect(ioutil.WriteFile(filepath.Join(home, ".ssh", "id_ecdsa"), clientKey, 0600)).To(Succeed())
+
+		hostSigner, _ = newSigner()
+
+		serverConfig := &ssh.ServerConfig{
+			PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if conn.User() == "ops" && bytes.Equal(key.Marshal(), clientSigner.PublicKey().Marshal()) {
+					return nil, nil
+				}
+				return nil, errors.New("denied")
+			},
+		}
+		serverConfig.AddHostKey(hostSigner)
+
+		bastion, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		go serveBastion(bastion, serverConfig)
+
+		target, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		go serveEcho(target)
+
+		jumpHost = options.JumpHost{User: "ops", Address: bastion.Addr().String()}
+	})
+
+	AfterEach(func() {
+		bastion.Close()
+		target.Close()
+		os.Setenv("HOME", savedHome)
+		os.Setenv("USERPROFILE", savedUserProfile)
+		os.Setenv("SSH_AUTH_SOCK", savedAuthSock)
+		os.RemoveAll(home)
+	})
+
+	Context("when the jump host is known", func() {
+		BeforeEach(func() {
+			writeKnownHosts(hostSigner.PublicKey())
+		})
+
+		It("dials the target through the jump host", func() {
+			client, err := jump.Connect(jumpHost, proxy.Direct(time.Second), time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			conn, err := client.Dial("tcp", target.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("hello"))
+			Expect(err).NotTo(HaveOccurred())
+
+			reply := make([]byte, 5)
+			_, err = io.ReadFull(conn, reply)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(reply)).To(Equal("hello"))
+		})
+
+		It("fails when the jump host rejects the user", func() {
+			jumpHost.User = "someone-else"
+
+			_, err := jump.Connect(jumpHost, proxy.Direct(time.Second), time.Second)
+			Expect(err).To(MatchError(ContainSubstring("Failed to connect to jump host someone-else@")))
+		})
+	})
+
+	Context("when the jump host key does not match known_hosts", func() {
+		BeforeEach(func() {
+			otherSigner, _ := newSigner()
+			writeKnownHosts(otherSigner.PublicKey())
+		})
+
+		It("refuses to connect", func() {
+			_, err := jump.Connect(jumpHost, proxy.Direct(time.Second), time.Second)
+			Expect(err).To(MatchError(ContainSubstring("key mismatch")))
+		})
+	})
+
+	Context("when there is no known_hosts file", func() {
+		It("returns an error", func() {
+			_, err := jump.Connect(jumpHost, proxy.Direct(time.Second), time.Second)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Failed to load known hosts for jump host %s", jumpHost))))
+		})
+	})
+})
+
+func serveBastion(listener net.Listener, serverConfig *ssh.ServerConfig) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+
+			for newChannel := range chans {
+				if newChannel.ChannelType() != "direct-tcpip" {
+					newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+					continue
+				}
+
+				var payload struct {
+					Host       string
+					Port       uint32
+					OriginHost string
+					OriginPort uint32
+				}
+				ssh.Unmarshal(newChannel.ExtraData(), &payload)
+
+				remote, err := net.Dial("tcp", net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port)))
+				if err != nil {
+					newChannel.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					remote.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+				go func() {
+					io.Copy(channel, remote)
+					channel.Close()
+				}()
+				go func() {
+					io.Copy(remote, channel)
+					remote.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func serveEcho(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			io.Copy(conn, conn)
+			conn.Close()
+		}()
+	}
+}
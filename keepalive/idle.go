@@ -0,0 +1,96 @@
+package keepalive
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Activity records when data last flowed through the readers and writers
+// it wraps.
+type Activity struct {
+	lock sync.Mutex
+	last time.Time
+}
+
+func NewActivity() *Activity {
+	return &Activity{last: time.Now()}
+}
+
+func (a *Activity) Touch() {
+	a.lock.Lock()
+	a.last = time.Now()
+	a.lock.Unlock()
+}
+
+func (a *Activity) IdleFor() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return time.Since(a.last)
+}
+
+func (a *Activity) Reader(r io.Reader) io.Reader {
+	return &activityReader{reader: r, activity: a}
+}
+
+func (a *Activity) Writer(w io.Writer) io.Writer {
+	return &activityWriter{writer: w, activity: a}
+}
+
+// WatchIdle calls onIdle once the activity has been idle for timeout. The
+// returned function stops watching.
+func WatchIdle(activity *Activity, timeout time.Duration, onIdle func()) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+				idle := activity.IdleFor()
+				if idle >= timeout {
+					onIdle()
+					return
+				}
+				timer.Reset(timeout - idle)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+type activityReader struct {
+	reader   io.Reader
+	activity *Activity
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.activity.Touch()
+	}
+	return n, err
+}
+
+type activityWriter struct {
+	writer   io.Writer
+	activity *Activity
+}
+
+func (w *activityWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.activity.Touch()
+	}
+	return n, err
+}
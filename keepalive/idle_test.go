@@ -0,0 +1,72 @@
+package keepalive_test
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/keepalive"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idle", func() {
+	var activity *keepalive.Activity
+
+	BeforeEach(func() {
+		activity = keepalive.NewActivity()
+	})
+
+	It("records activity on reads and writes", func() {
+		time.Sleep(20 * time.Millisecond)
+		Expect(activity.IdleFor()).To(BeNumerically(">=", 20*time.Millisecond))
+
+		_, err := activity.Reader(strings.NewReader("hello")).Read(make([]byte, 5))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(activity.IdleFor()).To(BeNumerically("<", 20*time.Millisecond))
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = activity.Writer(&bytes.Buffer{}).Write([]byte("hello"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(activity.IdleFor()).To(BeNumerically("<", 20*time.Millisecond))
+	})
+
+	Describe("WatchIdle", func() {
+		var idled int32
+
+		BeforeEach(func() {
+			idled = 0
+		})
+
+		onIdle := func() {
+			atomic.AddInt32(&idled, 1)
+		}
+
+		idleCount := func() int32 {
+			return atomic.LoadInt32(&idled)
+		}
+
+		It("calls onIdle once the timeout passes without activity", func() {
+			stop := keepalive.WatchIdle(activity, 20*time.Millisecond, onIdle)
+			defer stop()
+
+			Eventually(idleCount).Should(Equal(int32(1)))
+		})
+
+		It("waits while there is activity", func() {
+			stop := keepalive.WatchIdle(activity, 50*time.Millisecond, onIdle)
+			defer stop()
+
+			for i := 0; i < 10; i++ {
+				time.Sleep(10 * time.Millisecond)
+				activity.Touch()
+			}
+			Expect(idleCount()).To(BeZero())
+
+			Eventually(idleCount).Should(Equal(int32(1)))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package keepalive
+
+import (
+	"time"
+)
+
+const RequestType = "keepalive@openssh.com"
+
+//go:generate counterfeiter -o keepalive_fakes/fake_requester.go . Requester
+type Requester interface {
+	SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error)
+}
+
+// Start sends a keepalive global request every interval. The peer is
+// considered dead when countMax consecutive requests go unanswered for an
+// interval or a request fails, at which point onDead is called once. The
+// returned function stops the keepalives.
+func Start(requester Requester, interval time.Duration, countMax int, onDead func()) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		replies := make(chan error, 1)
+		pending := false
+		missed := 0
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			case err := <-replies:
+				pending = false
+				if err != nil {
+					onDead()
+					return
+				}
+				missed = 0
+
+			case <-ticker.C:
+				if pending {
+					missed++
+					if countMax > 0 && missed >= countMax {
+						onDead()
+						return
+					}
+					continue
+				}
+
+				pending = true
+				go func() {
+					// Any reply, including a failure reply, shows the peer is alive.
+					_, _, err := requester.SendRequest(RequestType, true, nil)
+					replies <- err
+				}()
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
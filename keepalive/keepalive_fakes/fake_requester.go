@@ -0,0 +1,61 @@
+// This file was generated by counterfeiter
+package keepalive_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/keepalive"
+)
+
+type FakeRequester struct {
+	SendRequestStub        func(name string, wantReply bool, payload []byte) (bool, []byte, error)
+	sendRequestMutex       sync.RWMutex
+	sendRequestArgsForCall []struct {
+		name      string
+		wantReply bool
+		payload   []byte
+	}
+	sendRequestReturns struct {
+		result1 bool
+		result2 []byte
+		result3 error
+	}
+}
+
+func (fake *FakeRequester) SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error) {
+	fake.sendRequestMutex.Lock()
+	fake.sendRequestArgsForCall = append(fake.sendRequestArgsForCall, struct {
+		name      string
+		wantReply bool
+		payload   []byte
+	}{name, wantReply, payload})
+	fake.sendRequestMutex.Unlock()
+	if fake.SendRequestStub != nil {
+		return fake.SendRequestStub(name, wantReply, payload)
+	} else {
+		return fake.sendRequestReturns.result1, fake.sendRequestReturns.result2, fake.sendRequestReturns.result3
+	}
+}
+
+func (fake *FakeRequester) SendRequestCallCount() int {
+	fake.sendRequestMutex.RLock()
+	defer fake.sendRequestMutex.RUnlock()
+	return len(fake.sendRequestArgsForCall)
+}
+
+func (fake *FakeRequester) SendRequestArgsForCall(i int) (string, bool, []byte) {
+	fake.sendRequestMutex.RLock()
+	defer fake.sendRequestMutex.RUnlock()
+	return fake.sendRequestArgsForCall[i].name, fake.sendRequestArgsForCall[i].wantReply, fake.sendRequestArgsForCall[i].payload
+}
+
+func (fake *FakeRequester) SendRequestReturns(result1 bool, result2 []byte, result3 error) {
+	fake.SendRequestStub = nil
+	fake.sendRequestReturns = struct {
+		result1 bool
+		result2 []byte
+		result3 error
+	}{result1, result2, result3}
+}
+
+var _ keepalive.Requester = new(FakeRequester)
@@ -0,0 +1,13 @@
+package keepalive_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestKeepalive(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Keepalive Suite")
+}
@@ -0,0 +1,78 @@
+package keepalive_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/keepalive"
+	"github.com/sykesm/cf-ssh-plugin/keepalive/keepalive_fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keepalive", func() {
+	var (
+		fakeRequester *keepalive_fakes.FakeRequester
+		deaths        int32
+		onDead        func()
+		stop          func()
+	)
+
+	BeforeEach(func() {
+		fakeRequester = &keepalive_fakes.FakeRequester{}
+		deaths = 0
+		onDead = func() { atomic.AddInt32(&deaths, 1) }
+	})
+
+	AfterEach(func() {
+		if stop != nil {
+			stop()
+		}
+	})
+
+	deathCount := func() int32 {
+		return atomic.LoadInt32(&deaths)
+	}
+
+	It("periodically sends keepalive requests that want a reply", func() {
+		fakeRequester.SendRequestReturns(false, nil, nil)
+		stop = keepalive.Start(fakeRequester, 10*time.Millisecond, 3, onDead)
+
+		Eventually(fakeRequester.SendRequestCallCount).Should(BeNumerically(">=", 3))
+		name, wantReply, payload := fakeRequester.SendRequestArgsForCall(0)
+		Expect(name).To(Equal("keepalive@openssh.com"))
+		Expect(wantReply).To(BeTrue())
+		Expect(payload).To(BeNil())
+
+		Consistently(deathCount).Should(BeZero())
+	})
+
+	It("declares the peer dead when a request fails", func() {
+		fakeRequester.SendRequestReturns(false, nil, errors.New("EOF"))
+		stop = keepalive.Start(fakeRequester, 10*time.Millisecond, 3, onDead)
+
+		Eventually(deathCount).Should(Equal(int32(1)))
+		Consistently(deathCount).Should(Equal(int32(1)))
+	})
+
+	It("declares the peer dead after too many missed replies", func() {
+		block := make(chan struct{})
+		defer close(block)
+
+		fakeRequester.SendRequestStub = func(string, bool, []byte) (bool, []byte, error) {
+			<-block
+			return true, nil, nil
+		}
+		stop = keepalive.Start(fakeRequester, 10*time.Millisecond, 3, onDead)
+
+		Eventually(deathCount).Should(Equal(int32(1)))
+		Expect(fakeRequester.SendRequestCallCount()).To(Equal(1))
+	})
+
+	It("does nothing when the interval is zero", func() {
+		stop = keepalive.Start(fakeRequester, 0, 3, onDead)
+		Consistently(fakeRequester.SendRequestCallCount).Should(BeZero())
+	})
+})
@@ -0,0 +1,131 @@
+package keyboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// AnswerFunc supplies an automatic answer, such as an access token.
+type AnswerFunc func() (string, error)
+
+// PromptFunc asks the user to answer a question.
+type PromptFunc func(question string, echo bool) (string, error)
+
+// Responder answers keyboard-interactive challenges. Questions asking for a
+// passcode are answered with Passcode and questions asking for a password
+// or token with Token. Anything else, or anything an automatic answer fails
+// for, is put to the user with Prompt when it is set.
+type Responder struct {
+	Token    AnswerFunc
+	Passcode AnswerFunc
+	Prompt   PromptFunc
+	Out      io.Writer
+}
+
+func (r *Responder) Challenge(user, instruction string, questions []string, echos []bool) ([]string, error) {
+	if instruction != "" && r.Prompt != nil && r.Out != nil {
+		fmt.Fprintln(r.Out, instruction)
+	}
+
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		answer, err := r.answer(question, echos[i])
+		if err != nil {
+			return nil, err
+		}
+		answers[i] = answer
+	}
+
+	return answers, nil
+}
+
+func (r *Responder) answer(question string, echo bool) (string, error) {
+	var automatic AnswerFunc
+
+	words := promptWords(question)
+	switch {
+	case words["passcode"] || words["code"]:
+		automatic = r.Passcode
+	case words["password"] || words["token"]:
+		automatic = r.Token
+	}
+
+	if automatic != nil {
+		answer, err := automatic()
+		if err == nil {
+			return answer, nil
+		}
+		if r.Prompt == nil {
+			return "", err
+		}
+	}
+
+	if r.Prompt == nil {
+		return "", fmt.Errorf("Unable to answer authentication prompt: %s", strings.TrimSpace(question))
+	}
+
+	return r.Prompt(question, echo)
+}
+
+// promptWords returns the lower-cased words of question, so a prompt for a
+// "code" is recognized but one mentioning "encoded" or "QR-codes" is not.
+func promptWords(question string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// TerminalPrompt returns a PromptFunc that reads answers from in, or nil if
+// in is not a terminal. Answers are not echoed unless the server asks.
+func TerminalPrompt(in *os.File, out io.Writer) PromptFunc {
+	fd := int(in.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil
+	}
+
+	return func(question string, echo bool) (string, error) {
+		fmt.Fprint(out, question)
+
+		if !echo {
+			answer, err := terminal.ReadPassword(fd)
+			fmt.Fprintln(out)
+			return string(answer), err
+		}
+
+		line, err := ReadLine(in)
+		if err != nil && line == "" {
+			return "", errors.New("Failed to read answer")
+		}
+		return strings.TrimRight(line, "\r"), nil
+	}
+}
+
+// ReadLine reads up to a newline one byte at a time so nothing after it is
+// taken from r; the rest of stdin belongs to the session.
+func ReadLine(r io.Reader) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package keyboard_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestKeyboard(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Keyboard Suite")
+}
@@ -0,0 +1,119 @@
+package keyboard_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/sykesm/cf-ssh-plugin/keyboard"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keyboard", func() {
+	var (
+		responder *keyboard.Responder
+		prompted  []string
+		out       *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		prompted = nil
+		out = &bytes.Buffer{}
+		responder = &keyboard.Responder{
+			Token:    func() (string, error) { return "bearer token", nil },
+			Passcode: func() (string, error) { return "abc123", nil },
+			Prompt: func(question string, echo bool) (string, error) {
+				prompted = append(prompted, question)
+				return "typed", nil
+			},
+			Out: out,
+		}
+	})
+
+	It("answers token and passcode prompts automatically", func() {
+		answers, err := responder.Challenge("cf:guid/0", "", []string{"Password: ", "One-time Passcode: ", "Access token: "}, []bool{false, false, false})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answers).To(Equal([]string{"bearer token", "abc123", "bearer token"}))
+		Expect(prompted).To(BeEmpty())
+	})
+
+	It("recognizes passcode prompts by whole words", func() {
+		answers, err := responder.Challenge("cf:guid/0", "", []string{"Verification code: ", "Enter the base64-encoded answer: "}, []bool{false, true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answers).To(Equal([]string{"abc123", "typed"}))
+		Expect(prompted).To(Equal([]string{"Enter the base64-encoded answer: "}))
+	})
+
+	It("prompts for anything else", func() {
+		answers, err := responder.Challenge("cf:guid/0", "Second factor required", []string{"Favorite color? "}, []bool{true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answers).To(Equal([]string{"typed"}))
+		Expect(prompted).To(Equal([]string{"Favorite color? "}))
+		Expect(out.String()).To(Equal("Second factor required\n"))
+	})
+
+	It("prompts when an automatic answer is unavailable", func() {
+		responder.Passcode = func() (string, error) { return "", errors.New("no ssh-code") }
+
+		answers, err := responder.Challenge("cf:guid/0", "", []string{"Passcode: "}, []bool{false})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answers).To(Equal([]string{"typed"}))
+	})
+
+	It("accepts challenges without questions", func() {
+		answers, err := responder.Challenge("cf:guid/0", "", nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answers).To(BeEmpty())
+	})
+
+	Context("without a terminal", func() {
+		BeforeEach(func() {
+			responder.Prompt = nil
+		})
+
+		It("fails on questions it can't answer", func() {
+			_, err := responder.Challenge("cf:guid/0", "", []string{"Favorite color? "}, []bool{true})
+			Expect(err).To(MatchError("Unable to answer authentication prompt: Favorite color?"))
+		})
+
+		It("returns the error from a failed automatic answer", func() {
+			responder.Passcode = func() (string, error) { return "", errors.New("no ssh-code") }
+
+			_, err := responder.Challenge("cf:guid/0", "", []string{"Passcode: "}, []bool{false})
+			Expect(err).To(MatchError("no ssh-code"))
+		})
+	})
+
+	Describe("TerminalPrompt", func() {
+		It("is nil when the input is not a terminal", func() {
+			file, err := ioutil.TempFile("", "keyboard")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(file.Name())
+			defer file.Close()
+
+			Expect(keyboard.TerminalPrompt(file, out)).To(BeNil())
+		})
+	})
+
+	Describe("ReadLine", func() {
+		It("does not read past the newline", func() {
+			in := strings.NewReader("answer\nls\n")
+
+			line, err := keyboard.ReadLine(in)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(line).To(Equal("answer"))
+			Expect(in.Len()).To(Equal(3))
+		})
+
+		It("returns a final line without a newline with the error", func() {
+			line, err := keyboard.ReadLine(strings.NewReader("answer"))
+			Expect(err).To(Equal(io.EOF))
+			Expect(line).To(Equal("answer"))
+		})
+	})
+})
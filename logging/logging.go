@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/pivotal-golang/lager"
+)
+
+const redacted = "[PRIVATE DATA HIDDEN]"
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[^\s"']+`)
+	secretPattern = regexp.MustCompile(`("(?:access_token|refresh_token|id_token|client_secret|password|token)"\s*:\s*")[^"]*(")`)
+
+	// secretCommands print nothing but credentials, which need not be
+	// prefixed or quoted in a way Redact recognizes: ssh-code prints a bare
+	// one-time passcode.
+	secretCommands = map[string]bool{
+		"ssh-code":    true,
+		"oauth-token": true,
+	}
+)
+
+// Configure registers sinks on logger for the verbosity requested with -v
+// or -vv and for CF_SSH_TRACE. A trace of "true" or "1" logs everything to
+// stderr; any other value except "false" or "0" names a file to append to.
+// The returned function closes the trace file, if any.
+func Configure(logger lager.Logger, verbosity int, trace string, stderr io.Writer) (func(), error) {
+	stderrLevel := lager.LogLevel(-1)
+	switch {
+	case verbosity >= 2:
+		stderrLevel = lager.DEBUG
+	case verbosity == 1:
+		stderrLevel = lager.INFO
+	}
+
+	closeTrace := func() {}
+
+	switch strings.ToLower(trace) {
+	case "", "false", "0":
+	case "true", "1":
+		stderrLevel = lager.DEBUG
+	default:
+		file, err := os.OpenFile(trace, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open trace file %s: %s", trace, err)
+		}
+		logger.RegisterSink(lager.NewWriterSink(file, lager.DEBUG))
+		closeTrace = func() { file.Close() }
+	}
+
+	if stderrLevel >= lager.DEBUG {
+		logger.RegisterSink(lager.NewWriterSink(stderr, stderrLevel))
+	}
+
+	return closeTrace, nil
+}
+
+// Redact hides bearer tokens and secret JSON fields.
+func Redact(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	return secretPattern.ReplaceAllString(s, "${1}"+redacted+"${2}")
+}
+
+type cliConnection struct {
+	cli    plugin.CliConnection
+	logger lager.Logger
+}
+
+// NewCliConnection logs each command run through cli along with its
+// redacted output. The output of commands that print credentials is never
+// logged.
+func NewCliConnection(cli plugin.CliConnection, logger lager.Logger) plugin.CliConnection {
+	return &cliConnection{cli: cli, logger: logger}
+}
+
+func (c *cliConnection) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	output, err := c.cli.CliCommandWithoutTerminalOutput(args...)
+	c.log(args, output, err)
+	return output, err
+}
+
+func (c *cliConnection) CliCommand(args ...string) ([]string, error) {
+	output, err := c.cli.CliCommand(args...)
+	c.log(args, output, err)
+	return output, err
+}
+
+func (c *cliConnection) log(args []string, output []string, err error) {
+	command := Redact(strings.Join(args, " "))
+
+	loggedOutput := redacted
+	if len(args) == 0 || !secretCommands[args[0]] {
+		loggedOutput = Redact(strings.Join(output, "\n"))
+	}
+
+	if err != nil {
+		c.logger.Error("cli-command", err, lager.Data{"command": command, "output": loggedOutput})
+		return
+	}
+
+	c.logger.Info("cli-command", lager.Data{"command": command})
+	c.logger.Debug("cli-command-output", lager.Data{"command": command, "output": loggedOutput})
+}
@@ -0,0 +1,186 @@
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/pivotal-golang/lager"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	var (
+		logger lager.Logger
+		stderr *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		logger = lager.NewLogger("ssh")
+		stderr = &bytes.Buffer{}
+	})
+
+	Describe("Configure", func() {
+		log := func() {
+			logger.Debug("debug-message")
+			logger.Info("info-message")
+		}
+
+		It("logs nothing by default", func() {
+			_, err := logging.Configure(logger, 0, "", stderr)
+			Expect(err).NotTo(HaveOccurred())
+
+			log()
+			Expect(stderr.Len()).To(BeZero())
+		})
+
+		It("logs info messages to stderr with -v", func() {
+			_, err := logging.Configure(logger, 1, "false", stderr)
+			Expect(err).NotTo(HaveOccurred())
+
+			log()
+			Expect(stderr.String()).To(ContainSubstring("ssh.info-message"))
+			Expect(stderr.String()).NotTo(ContainSubstring("ssh.debug-message"))
+		})
+
+		It("logs debug messages to stderr with -vv", func() {
+			_, err := logging.Configure(logger, 2, "", stderr)
+			Expect(err).NotTo(HaveOccurred())
+
+			log()
+			Expect(stderr.String()).To(ContainSubstring("ssh.debug-message"))
+		})
+
+		It("logs debug messages to stderr when tracing is enabled", func() {
+			_, err := logging.Configure(logger, 0, "true", stderr)
+			Expect(err).NotTo(HaveOccurred())
+
+			log()
+			Expect(stderr.String()).To(ContainSubstring("ssh.debug-message"))
+		})
+
+		Context("when tracing to a file", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "logging")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("appends debug messages to the file", func() {
+				path := filepath.Join(dir, "trace.log")
+				closeTrace, err := logging.Configure(logger, 0, path, stderr)
+				Expect(err).NotTo(HaveOccurred())
+
+				log()
+				closeTrace()
+
+				contents, err := ioutil.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(ContainSubstring("ssh.debug-message"))
+				Expect(stderr.Len()).To(BeZero())
+			})
+
+			It("fails when the file can't be opened", func() {
+				path := filepath.Join(dir, "missing", "trace.log")
+				_, err := logging.Configure(logger, 0, path, stderr)
+				Expect(err).To(MatchError(HavePrefix("Failed to open trace file " + path)))
+			})
+		})
+	})
+
+	Describe("Redact", func() {
+		It("hides bearer tokens", func() {
+			Expect(logging.Redact("bearer eyJhbGciOiJSUzI1NiJ9.abc.def")).To(Equal("bearer [PRIVATE DATA HIDDEN]"))
+			Expect(logging.Redact(`Authorization: Bearer abc123`)).To(Equal("Authorization: Bearer [PRIVATE DATA HIDDEN]"))
+		})
+
+		It("hides secret JSON fields", func() {
+			Expect(logging.Redact(`{"access_token": "abc", "token_type": "bearer", "refresh_token":"def"}`)).To(Equal(
+				`{"access_token": "[PRIVATE DATA HIDDEN]", "token_type": "bearer", "refresh_token":"[PRIVATE DATA HIDDEN]"}`,
+			))
+		})
+
+		It("leaves everything else alone", func() {
+			Expect(logging.Redact(`{"app_ssh_endpoint": "ssh.example.com:2222"}`)).To(Equal(`{"app_ssh_endpoint": "ssh.example.com:2222"}`))
+		})
+	})
+
+	Describe("CliConnection", func() {
+		var fakeCliConnection *fakes.FakeCliConnection
+
+		BeforeEach(func() {
+			fakeCliConnection = &fakes.FakeCliConnection{}
+			logger.RegisterSink(lager.NewWriterSink(stderr, lager.DEBUG))
+		})
+
+		It("logs commands with their redacted output", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{"access_token": "secret-token", "app_ssh_endpoint": "ssh.example.com:2222"}`}, nil)
+
+			cli := logging.NewCliConnection(fakeCliConnection, logger)
+			output, err := cli.CliCommandWithoutTerminalOutput("curl", "/v2/info")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]string{`{"access_token": "secret-token", "app_ssh_endpoint": "ssh.example.com:2222"}`}))
+
+			Expect(stderr.String()).To(ContainSubstring(`"command":"curl /v2/info"`))
+			Expect(stderr.String()).To(ContainSubstring("ssh.example.com:2222"))
+			Expect(stderr.String()).NotTo(ContainSubstring("secret-token"))
+		})
+
+		It("never logs the output of oauth-token", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"Getting OAuth token", "secret-token"}, nil)
+
+			cli := logging.NewCliConnection(fakeCliConnection, logger)
+			output, err := cli.CliCommandWithoutTerminalOutput("oauth-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]string{"Getting OAuth token", "secret-token"}))
+
+			Expect(stderr.String()).To(ContainSubstring(`"command":"oauth-token"`))
+			Expect(stderr.String()).NotTo(ContainSubstring("secret-token"))
+		})
+
+		It("never logs the passcode printed by ssh-code", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"abc123passcode"}, nil)
+
+			cli := logging.NewCliConnection(fakeCliConnection, logger)
+			output, err := cli.CliCommandWithoutTerminalOutput("ssh-code")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]string{"abc123passcode"}))
+
+			Expect(stderr.String()).To(ContainSubstring(`"command":"ssh-code"`))
+			Expect(stderr.String()).NotTo(ContainSubstring("abc123passcode"))
+		})
+
+		It("does not log the output of a failed ssh-code", func() {
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"abc123passcode"}, errors.New("exit status 1"))
+
+			cli := logging.NewCliConnection(fakeCliConnection, logger)
+			cli.CliCommandWithoutTerminalOutput("ssh-code")
+
+			Expect(stderr.String()).To(ContainSubstring(`"error":"exit status 1"`))
+			Expect(stderr.String()).NotTo(ContainSubstring("abc123passcode"))
+		})
+
+		It("logs failures", func() {
+			fakeCliConnection.CliCommandReturns([]string{"FAILED"}, errors.New("exit status 1"))
+
+			cli := logging.NewCliConnection(fakeCliConnection, logger)
+			_, err := cli.CliCommand("curl", "/v2/info")
+			Expect(err).To(MatchError("exit status 1"))
+
+			Expect(stderr.String()).To(ContainSubstring(`"error":"exit status 1"`))
+			Expect(stderr.String()).To(ContainSubstring(`"command":"curl /v2/info"`))
+		})
+	})
+})
@@ -3,13 +3,37 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 )
 
+// WebProcess is the process type used when none is requested.
+const WebProcess = "web"
+
 //go:generate counterfeiter -o fakes/fake_app_factory.go . AppFactory
 type AppFactory interface {
-	Get(string) (App, error)
+	Get(ref Ref, processType string) (App, error)
+}
+
+// Ref identifies an app: by GUID, by name within a space, or by name within
+// the targeted space when Space is empty. A space without an org is looked
+// up across every org the user can see.
+type Ref struct {
+	Name  string
+	Guid  string
+	Org   string
+	Space string
+}
+
+func (r Ref) String() string {
+	if r.Guid != "" {
+		return r.Guid
+	}
+	return r.Name
 }
 
 type appFactory struct {
@@ -20,11 +44,24 @@ func NewAppFactory(cli plugin.CliConnection) AppFactory {
 	return &appFactory{cli: cli}
 }
 
+// App is an app and the process within it that SSH sessions are opened to.
+// Foundations without the v3 API only have the web process, whose GUID is
+// the app's.
 type App struct {
-	Guid      string
-	EnableSSH bool
-	Diego     bool
-	State     string
+	Name        string
+	Guid        string
+	SpaceGuid   string
+	ProcessType string
+	ProcessGuid string
+	Instances   int
+	EnableSSH   bool
+	Diego       bool
+	State       string
+}
+
+// User returns the SSH user for an instance of the app's process.
+func (a App) User(index int) string {
+	return fmt.Sprintf("cf:%s/%d", a.ProcessGuid, index)
 }
 
 type metadata struct {
@@ -32,6 +69,9 @@ type metadata struct {
 }
 
 type entity struct {
+	Name      string `json:"name"`
+	SpaceGuid string `json:"space_guid"`
+	Instances int    `json:"instances"`
 	EnableSSH bool   `json:"enable_ssh"`
 	Diego     bool   `json:"diego"`
 	State     string `json:"state"`
@@ -42,31 +82,287 @@ type cfApp struct {
 	Entity   entity   `json:"entity"`
 }
 
-func (af *appFactory) Get(appName string) (App, error) {
-	output, err := af.cli.CliCommandWithoutTerminalOutput("app", appName, "--guid")
+// v3Error is an entry in the errors list of a failed v3 request.
+type v3Error struct {
+	Code  int    `json:"code"`
+	Title string `json:"title"`
+}
+
+type v3App struct {
+	Errors        []v3Error `json:"errors"`
+	Guid          string    `json:"guid"`
+	Name          string    `json:"name"`
+	State         string    `json:"state"`
+	Relationships struct {
+		Space struct {
+			Data struct {
+				Guid string `json:"guid"`
+			} `json:"data"`
+		} `json:"space"`
+	} `json:"relationships"`
+}
+
+type v3Process struct {
+	Guid      string `json:"guid"`
+	Type      string `json:"type"`
+	Instances int    `json:"instances"`
+}
+
+type v3SSHEnabled struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// resource is the part of a v2 list entry needed to resolve names.
+type resource struct {
+	Metadata metadata `json:"metadata"`
+	Entity   struct {
+		Name             string `json:"name"`
+		OrganizationGuid string `json:"organization_guid"`
+	} `json:"entity"`
+}
+
+type page struct {
+	NextURL   string     `json:"next_url"`
+	Resources []resource `json:"resources"`
+}
+
+var errV3Unavailable = errors.New("v3 API unavailable")
+
+// Get resolves the app and its process of the given type, the web process
+// when processType is empty. The v3 API is used when the foundation
+// supports it; otherwise only the web process can be selected.
+func (af *appFactory) Get(ref Ref, processType string) (App, error) {
+	if processType == "" {
+		processType = WebProcess
+	}
+
+	guid, err := af.resolve(ref)
 	if err != nil {
-		return App{}, errors.New(output[len(output)-1])
+		return App{}, err
+	}
+
+	app, err := af.getV3(ref.String(), guid, processType)
+	if err == errV3Unavailable {
+		if processType != WebProcess {
+			return App{}, fmt.Errorf("Selecting process %s requires the v3 API, which this foundation does not support", processType)
+		}
+		return af.getV2(ref.String(), guid)
+	}
+
+	return app, err
+}
+
+// resolve returns the GUID of the app ref identifies.
+func (af *appFactory) resolve(ref Ref) (string, error) {
+	switch {
+	case ref.Guid != "":
+		return ref.Guid, nil
+	case ref.Space != "":
+		return af.resolveInSpace(ref)
 	}
 
-	output, err = af.cli.CliCommandWithoutTerminalOutput("curl", "/v2/apps/"+output[0])
+	output, err := af.cli.CliCommandWithoutTerminalOutput("app", ref.Name, "--guid")
+	if err != nil {
+		if len(output) == 0 {
+			return "", errors.New("Failed to acquire " + ref.Name + " info")
+		}
+
+		message := output[len(output)-1]
+		if strings.Contains(message, "not found") {
+			return "", failures.AppNotFound(message)
+		}
+		return "", errors.New(message)
+	}
+
+	return output[0], nil
+}
+
+func (af *appFactory) resolveInSpace(ref Ref) (string, error) {
+	spaceGuid, err := af.resolveSpace(ref.Org, ref.Space)
+	if err != nil {
+		return "", err
+	}
+
+	apps, err := af.list("/v2/spaces/" + spaceGuid + "/apps?q=" + url.QueryEscape("name:"+ref.Name))
+	if err != nil {
+		return "", errors.New("Failed to acquire " + ref.Name + " info")
+	}
+
+	switch len(apps) {
+	case 0:
+		return "", failures.AppNotFound(fmt.Sprintf("App %s is not found in space %s", ref.Name, ref.Space))
+	case 1:
+		return apps[0].Metadata.Guid, nil
+	}
+
+	guids := []string{}
+	for _, app := range apps {
+		guids = append(guids, app.Metadata.Guid)
+	}
+	return "", failures.Ambiguous("App "+ref.Name, guids, "Select the app by GUID with --guid.")
+}
+
+// resolveSpace returns the GUID of the named space. Without an org, the
+// space must be the only one of that name the user can see.
+func (af *appFactory) resolveSpace(orgName, spaceName string) (string, error) {
+	query := "?q=" + url.QueryEscape("name:"+spaceName)
+
+	if orgName != "" {
+		orgs, err := af.list("/v2/organizations?q=" + url.QueryEscape("name:"+orgName))
+		if err != nil {
+			return "", errors.New("Failed to acquire org " + orgName + " info")
+		}
+		if len(orgs) == 0 {
+			return "", failures.OrgNotFound(orgName)
+		}
+		query += "&q=" + url.QueryEscape("organization_guid:"+orgs[0].Metadata.Guid)
+	}
+
+	spaces, err := af.list("/v2/spaces" + query)
+	if err != nil {
+		return "", errors.New("Failed to acquire space " + spaceName + " info")
+	}
+
+	switch len(spaces) {
+	case 0:
+		return "", failures.SpaceNotFound(spaceName)
+	case 1:
+		return spaces[0].Metadata.Guid, nil
+	}
+
+	orgNames := []string{}
+	for _, space := range spaces {
+		org := resource{}
+		err := af.curl("/v2/organizations/"+space.Entity.OrganizationGuid, &org)
+		if err != nil || org.Entity.Name == "" {
+			orgNames = append(orgNames, space.Entity.OrganizationGuid)
+			continue
+		}
+		orgNames = append(orgNames, org.Entity.Name)
+	}
+	return "", failures.Ambiguous("Space "+spaceName, orgNames, "Choose the org with --org.")
+}
+
+// list returns the resources of every page of a v2 list.
+func (af *appFactory) list(path string) ([]resource, error) {
+	resources := []resource{}
+
+	for path != "" {
+		p := page{}
+		err := af.curl(path, &p)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, p.Resources...)
+		path = p.NextURL
+	}
+
+	return resources, nil
+}
+
+func (af *appFactory) getV3(appName, guid, processType string) (App, error) {
+	v3 := v3App{}
+	err := af.curl("/v3/apps/"+guid, &v3)
+	if err != nil {
+		return App{}, errV3Unavailable
+	}
+	if v3.Guid == "" {
+		// Foundations without the v3 API answer with a v2 error, which has
+		// no errors list; only a v3 error means the app is missing.
+		for _, e := range v3.Errors {
+			if e.Title == "CF-ResourceNotFound" {
+				return App{}, failures.AppNotFound(fmt.Sprintf("App %s is not found", appName))
+			}
+		}
+		return App{}, errV3Unavailable
+	}
+
+	process := v3Process{}
+	err = af.curl("/v3/apps/"+guid+"/processes/"+processType, &process)
 	if err != nil {
 		return App{}, errors.New("Failed to acquire " + appName + " info")
 	}
+	if process.Guid == "" {
+		return App{}, failures.ProcessNotFound(appName, processType)
+	}
 
-	response := []byte(output[0])
-	app := cfApp{}
+	// Older v3 foundations do not serve ssh_enabled; v2 still reports the
+	// flag there, and the web process is the v2 app itself.
+	sshEnabled := v3SSHEnabled{}
+	err = af.curl("/v3/apps/"+guid+"/ssh_enabled", &sshEnabled)
+	if err != nil {
+		sshEnabled.Enabled = nil
+	}
+	if sshEnabled.Enabled == nil && processType == WebProcess {
+		return af.getV2(appName, guid)
+	}
+
+	// v3 does not say whether an app runs on Diego or a DEA, so that is read
+	// from v2 as well. Foundations that no longer serve v2 only run Diego.
+	v2 := cfApp{}
+	err = af.curl("/v2/apps/"+guid, &v2)
+	if err != nil || v2.Metadata.Guid == "" {
+		if sshEnabled.Enabled == nil {
+			return App{}, errors.New("Failed to acquire " + appName + " info")
+		}
+		v2.Entity.Diego = true
+	}
+	if sshEnabled.Enabled == nil {
+		sshEnabled.Enabled = &v2.Entity.EnableSSH
+	}
+
+	if v3.Name != "" {
+		appName = v3.Name
+	}
+
+	return App{
+		Name:        appName,
+		Guid:        v3.Guid,
+		SpaceGuid:   v3.Relationships.Space.Data.Guid,
+		ProcessType: process.Type,
+		ProcessGuid: process.Guid,
+		Instances:   process.Instances,
+		EnableSSH:   *sshEnabled.Enabled,
+		Diego:       v2.Entity.Diego,
+		State:       v3.State,
+	}, nil
+}
 
-	err = json.Unmarshal(response, &app)
+func (af *appFactory) getV2(appName, guid string) (App, error) {
+	app := cfApp{}
+	err := af.curl("/v2/apps/"+guid, &app)
 	if err != nil {
-		return App{}, err
+		return App{}, errors.New("Failed to acquire " + appName + " info")
+	}
+	if app.Metadata.Guid == "" {
+		return App{}, failures.AppNotFound(fmt.Sprintf("App %s is not found", appName))
+	}
+
+	if app.Entity.Name != "" {
+		appName = app.Entity.Name
 	}
 
 	return App{
-		Guid:      app.Metadata.Guid,
-		EnableSSH: app.Entity.EnableSSH,
-		Diego:     app.Entity.Diego,
-		State:     app.Entity.State,
+		Name:        appName,
+		Guid:        app.Metadata.Guid,
+		SpaceGuid:   app.Entity.SpaceGuid,
+		ProcessType: WebProcess,
+		ProcessGuid: app.Metadata.Guid,
+		Instances:   app.Entity.Instances,
+		EnableSSH:   app.Entity.EnableSSH,
+		Diego:       app.Entity.Diego,
+		State:       app.Entity.State,
 	}, nil
+}
+
+func (af *appFactory) curl(path string, response interface{}) error {
+	output, err := af.cli.CliCommandWithoutTerminalOutput("curl", path)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		return errors.New("empty response from " + path)
+	}
 
-	return App{}, nil
+	return json.Unmarshal([]byte(strings.Join(output, "\n")), response)
 }
@@ -8,10 +8,11 @@ import (
 )
 
 type FakeAppFactory struct {
-	GetStub        func(string) (app.App, error)
+	GetStub        func(ref app.Ref, processType string) (app.App, error)
 	getMutex       sync.RWMutex
 	getArgsForCall []struct {
-		arg1 string
+		ref         app.Ref
+		processType string
 	}
 	getReturns struct {
 		result1 app.App
@@ -19,14 +20,15 @@ type FakeAppFactory struct {
 	}
 }
 
-func (fake *FakeAppFactory) Get(arg1 string) (app.App, error) {
+func (fake *FakeAppFactory) Get(ref app.Ref, processType string) (app.App, error) {
 	fake.getMutex.Lock()
 	fake.getArgsForCall = append(fake.getArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		ref         app.Ref
+		processType string
+	}{ref, processType})
 	fake.getMutex.Unlock()
 	if fake.GetStub != nil {
-		return fake.GetStub(arg1)
+		return fake.GetStub(ref, processType)
 	} else {
 		return fake.getReturns.result1, fake.getReturns.result2
 	}
@@ -38,10 +40,10 @@ func (fake *FakeAppFactory) GetCallCount() int {
 	return len(fake.getArgsForCall)
 }
 
-func (fake *FakeAppFactory) GetArgsForCall(i int) string {
+func (fake *FakeAppFactory) GetArgsForCall(i int) (app.Ref, string) {
 	fake.getMutex.RLock()
 	defer fake.getMutex.RUnlock()
-	return fake.getArgsForCall[i].arg1
+	return fake.getArgsForCall[i].ref, fake.getArgsForCall[i].processType
 }
 
 func (fake *FakeAppFactory) GetReturns(result1 app.App, result2 error) {
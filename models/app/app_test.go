@@ -2,105 +2,446 @@ package app_test
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 	"github.com/sykesm/cf-ssh-plugin/models/app"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+type response struct {
+	output []string
+	err    error
+}
+
 var _ = Describe("App", func() {
 	var (
 		fakeCliConnection *fakes.FakeCliConnection
 		af                app.AppFactory
+		responses         map[string]response
 	)
 
 	BeforeEach(func() {
 		fakeCliConnection = &fakes.FakeCliConnection{}
 		af = app.NewAppFactory(fakeCliConnection)
+
+		responses = map[string]response{
+			"app app1 --guid": {output: []string{"app1-guid"}},
+		}
+		fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+			r, ok := responses[strings.Join(args, " ")]
+			Expect(ok).To(BeTrue(), "unexpected command: %v", args)
+			return r.output, r.err
+		}
 	})
 
 	Describe("Get", func() {
-		Context("when CC returns a valid response", func() {
+		Context("when the foundation does not support the v3 API", func() {
 			BeforeEach(func() {
-				expectedJson := `{
-				"metadata": {
-					"guid": "app1-guid"
-				},
-				"entity": {
-					"instances": 1,
-					"state": "STARTED",
-					"diego": true,
-					"enable_ssh": true
-				}
-			}`
+				responses["curl /v3/apps/app1-guid"] = response{output: []string{`{
+					"code": 10000,
+					"description": "Unknown request",
+					"error_code": "CF-NotFound"
+				}`}}
+			})
 
-				fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
-					if fakeCliConnection.CliCommandWithoutTerminalOutputCallCount() == 1 {
-						Expect(args).To(ConsistOf("app", "app1", "--guid"))
-						return []string{"app1-guid"}, nil
+			Context("when CC returns a valid response", func() {
+				BeforeEach(func() {
+					responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+						"metadata": {
+							"guid": "app1-guid"
+						},
+						"entity": {
+							"space_guid": "space1-guid",
+							"instances": 1,
+							"state": "STARTED",
+							"diego": true,
+							"enable_ssh": true
+						}
+					}`}}
+				})
+
+				It("returns a populated App model", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "")
+
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.Name).To(Equal("app1"))
+					Expect(model.Guid).To(Equal("app1-guid"))
+					Expect(model.SpaceGuid).To(Equal("space1-guid"))
+					Expect(model.Instances).To(Equal(1))
+					Expect(model.EnableSSH).To(BeTrue())
+					Expect(model.Diego).To(BeTrue())
+					Expect(model.State).To(Equal("STARTED"))
+				})
+
+				It("uses the app as the web process", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "web")
+
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.ProcessType).To(Equal("web"))
+					Expect(model.ProcessGuid).To(Equal("app1-guid"))
+					Expect(model.User(2)).To(Equal("cf:app1-guid/2"))
+				})
+
+				It("cannot select other processes", func() {
+					_, err := af.Get(app.Ref{Name: "app1"}, "worker")
+					Expect(err).To(MatchError("Selecting process worker requires the v3 API, which this foundation does not support"))
+				})
+			})
+
+			Context("when curling the app model fails", func() {
+				BeforeEach(func() {
+					responses["curl /v2/apps/app1-guid"] = response{output: []string{"{}"}, err: errors.New("Failed to acquire app1 info")}
+				})
+
+				It("returns 'fail to acquire app info' error", func() {
+					_, err := af.Get(app.Ref{Name: "app1"}, "")
+
+					Expect(err).To(MatchError("Failed to acquire app1 info"))
+				})
+			})
+		})
+
+		Context("when the foundation supports the v3 API", func() {
+			BeforeEach(func() {
+				responses["curl /v3/apps/app1-guid"] = response{output: []string{`{
+					"guid": "app1-guid",
+					"name": "app1",
+					"state": "STARTED",
+					"relationships": {
+						"space": {
+							"data": {
+								"guid": "space1-guid"
+							}
+						}
 					}
-					if fakeCliConnection.CliCommandWithoutTerminalOutputCallCount() == 2 {
-						Expect(args).To(ConsistOf("curl", "/v2/apps/app1-guid"))
-						return []string{expectedJson}, nil
+				}`}}
+				responses["curl /v3/apps/app1-guid/processes/web"] = response{output: []string{`{
+					"guid": "app1-guid",
+					"type": "web",
+					"instances": 2
+				}`}}
+				responses["curl /v3/apps/app1-guid/processes/worker"] = response{output: []string{`{
+					"guid": "worker1-guid",
+					"type": "worker",
+					"instances": 3
+				}`}}
+				responses["curl /v3/apps/app1-guid/processes/clock"] = response{output: []string{`{
+					"errors": [{"code": 10010, "title": "CF-ResourceNotFound", "detail": "Process not found"}]
+				}`}}
+				responses["curl /v3/apps/app1-guid/ssh_enabled"] = response{output: []string{`{
+					"enabled": true,
+					"reason": ""
+				}`}}
+				responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+					"metadata": {
+						"guid": "app1-guid"
+					},
+					"entity": {
+						"name": "app1",
+						"space_guid": "space1-guid",
+						"instances": 2,
+						"state": "STARTED",
+						"diego": true,
+						"enable_ssh": true
 					}
-					Expect(false).To(BeTrue())
-					return []string{}, nil
-				}
+				}`}}
 			})
 
-			It("returns a populated App model", func() {
-				model, err := af.Get("app1")
+			It("returns the web process by default", func() {
+				model, err := af.Get(app.Ref{Name: "app1"}, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(model).To(Equal(app.App{
+					Name:        "app1",
+					Guid:        "app1-guid",
+					SpaceGuid:   "space1-guid",
+					ProcessType: "web",
+					ProcessGuid: "app1-guid",
+					Instances:   2,
+					EnableSSH:   true,
+					Diego:       true,
+					State:       "STARTED",
+				}))
+			})
 
+			It("returns the requested process", func() {
+				model, err := af.Get(app.Ref{Name: "app1"}, "worker")
 				Expect(err).NotTo(HaveOccurred())
+				Expect(model.ProcessType).To(Equal("worker"))
+				Expect(model.ProcessGuid).To(Equal("worker1-guid"))
+				Expect(model.Instances).To(Equal(3))
+				Expect(model.User(1)).To(Equal("cf:worker1-guid/1"))
+			})
+
+			It("fails when the process does not exist", func() {
+				_, err := af.Get(app.Ref{Name: "app1"}, "clock")
+				Expect(err).To(MatchError("App app1 has no clock process"))
+				Expect(failures.Is(err, failures.ProcessNotFoundKind)).To(BeTrue())
+			})
+
+			Context("when the app runs on a DEA", func() {
+				BeforeEach(func() {
+					responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+						"metadata": {"guid": "app1-guid"},
+						"entity": {"name": "app1", "diego": false, "enable_ssh": true}
+					}`}}
+				})
+
+				It("reports that it is not a Diego app", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.Diego).To(BeFalse())
+				})
+			})
+
+			Context("when the foundation no longer serves v2", func() {
+				BeforeEach(func() {
+					responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+						"errors": [{"code": 10000, "title": "CF-NotFound", "detail": "Unknown request"}]
+					}`}}
+				})
+
+				It("assumes the app runs on Diego", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "worker")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.Diego).To(BeTrue())
+					Expect(model.EnableSSH).To(BeTrue())
+				})
+			})
+
+			Context("when SSH is disabled", func() {
+				BeforeEach(func() {
+					responses["curl /v3/apps/app1-guid/ssh_enabled"] = response{output: []string{`{
+						"enabled": false,
+						"reason": "Disabled for space development"
+					}`}}
+				})
+
+				It("reports it", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.EnableSSH).To(BeFalse())
+				})
+			})
+
+			Context("when the foundation does not serve the SSH state through v3", func() {
+				BeforeEach(func() {
+					responses["curl /v3/apps/app1-guid/ssh_enabled"] = response{output: []string{`{
+						"errors": [{"code": 10000, "title": "CF-NotFound", "detail": "Unknown request"}]
+					}`}}
+				})
+
+				It("falls back to the v2 app for the web process", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.ProcessGuid).To(Equal("app1-guid"))
+					Expect(model.Instances).To(Equal(2))
+					Expect(model.EnableSSH).To(BeTrue())
+				})
+
+				It("reads the SSH state of other processes from the v2 app", func() {
+					model, err := af.Get(app.Ref{Name: "app1"}, "worker")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.ProcessGuid).To(Equal("worker1-guid"))
+					Expect(model.Instances).To(Equal(3))
+					Expect(model.EnableSSH).To(BeTrue())
+				})
+
+				It("returns an error when the v2 app cannot be read either", func() {
+					responses["curl /v2/apps/app1-guid"] = response{output: []string{"{}"}, err: errors.New("woops")}
+
+					_, err := af.Get(app.Ref{Name: "app1"}, "worker")
+					Expect(err).To(MatchError("Failed to acquire app1 info"))
+				})
+			})
+		})
+
+		Context("when the app is selected by GUID", func() {
+			BeforeEach(func() {
+				delete(responses, "app app1 --guid")
+				responses["curl /v3/apps/app1-guid"] = response{output: []string{"{}"}}
+				responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+					"metadata": {"guid": "app1-guid"},
+					"entity": {"name": "app1", "space_guid": "space1-guid", "instances": 1, "state": "STARTED"}
+				}`}}
+			})
+
+			It("uses the GUID without looking up the name", func() {
+				model, err := af.Get(app.Ref{Guid: "app1-guid"}, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(model.Name).To(Equal("app1"))
 				Expect(model.Guid).To(Equal("app1-guid"))
-				Expect(model.EnableSSH).To(BeTrue())
-				Expect(model.Diego).To(BeTrue())
-				Expect(model.State).To(Equal("STARTED"))
+			})
+
+			Context("when the GUID does not exist on a foundation with the v3 API", func() {
+				BeforeEach(func() {
+					responses["curl /v3/apps/bad-guid"] = response{output: []string{`{
+						"errors": [{"code": 10010, "title": "CF-ResourceNotFound", "detail": "App not found"}]
+					}`}}
+				})
+
+				It("returns an AppNotFound failure", func() {
+					_, err := af.Get(app.Ref{Guid: "bad-guid"}, "")
+					Expect(err).To(MatchError("App bad-guid is not found"))
+					Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeTrue())
+				})
+
+				It("does not blame the API version when a process is requested", func() {
+					_, err := af.Get(app.Ref{Guid: "bad-guid"}, "worker")
+					Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeTrue())
+				})
+			})
+
+			Context("when the GUID does not exist on a foundation without the v3 API", func() {
+				BeforeEach(func() {
+					responses["curl /v3/apps/bad-guid"] = response{output: []string{`{
+						"code": 10000,
+						"description": "Unknown request",
+						"error_code": "CF-NotFound"
+					}`}}
+					responses["curl /v2/apps/bad-guid"] = response{output: []string{`{
+						"code": 100004,
+						"description": "The app could not be found: bad-guid",
+						"error_code": "CF-AppNotFound"
+					}`}}
+				})
+
+				It("returns an AppNotFound failure", func() {
+					_, err := af.Get(app.Ref{Guid: "bad-guid"}, "")
+					Expect(err).To(MatchError("App bad-guid is not found"))
+					Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeTrue())
+				})
+			})
+		})
+
+		Context("when the app is selected in another space", func() {
+			BeforeEach(func() {
+				delete(responses, "app app1 --guid")
+				responses["curl /v3/apps/app1-guid"] = response{output: []string{"{}"}}
+				responses["curl /v2/apps/app1-guid"] = response{output: []string{`{
+					"metadata": {"guid": "app1-guid"},
+					"entity": {"name": "app1", "space_guid": "space1-guid", "instances": 1, "state": "STARTED"}
+				}`}}
+
+				responses["curl /v2/organizations?q=name%3Aorg1"] = response{output: []string{`{
+					"next_url": null,
+					"resources": [{"metadata": {"guid": "org1-guid"}, "entity": {"name": "org1"}}]
+				}`}}
+				responses["curl /v2/spaces?q=name%3Aspace1&q=organization_guid%3Aorg1-guid"] = response{output: []string{`{
+					"next_url": null,
+					"resources": [{"metadata": {"guid": "space1-guid"}, "entity": {"name": "space1", "organization_guid": "org1-guid"}}]
+				}`}}
+				responses["curl /v2/spaces/space1-guid/apps?q=name%3Aapp1"] = response{output: []string{`{
+					"next_url": "/v2/spaces/space1-guid/apps?q=name%3Aapp1&page=2",
+					"resources": []
+				}`}}
+				responses["curl /v2/spaces/space1-guid/apps?q=name%3Aapp1&page=2"] = response{output: []string{`{
+					"next_url": null,
+					"resources": [{"metadata": {"guid": "app1-guid"}, "entity": {"name": "app1"}}]
+				}`}}
+			})
+
+			It("finds the app through the space, following every page", func() {
+				model, err := af.Get(app.Ref{Name: "app1", Org: "org1", Space: "space1"}, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(model.Guid).To(Equal("app1-guid"))
+				Expect(model.SpaceGuid).To(Equal("space1-guid"))
+			})
+
+			It("reports an org that does not exist", func() {
+				responses["curl /v2/organizations?q=name%3Aorg1"] = response{output: []string{`{"next_url": null, "resources": []}`}}
+
+				_, err := af.Get(app.Ref{Name: "app1", Org: "org1", Space: "space1"}, "")
+				Expect(err).To(MatchError("Org org1 is not found"))
+				Expect(failures.Is(err, failures.OrgNotFoundKind)).To(BeTrue())
+			})
+
+			It("reports an app missing from the space", func() {
+				responses["curl /v2/spaces/space1-guid/apps?q=name%3Aapp1&page=2"] = response{output: []string{`{"next_url": null, "resources": []}`}}
+
+				_, err := af.Get(app.Ref{Name: "app1", Org: "org1", Space: "space1"}, "")
+				Expect(err).To(MatchError("App app1 is not found in space space1"))
+				Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeTrue())
+			})
+
+			Context("without an org", func() {
+				BeforeEach(func() {
+					responses["curl /v2/spaces?q=name%3Aspace1"] = response{output: []string{`{
+						"next_url": null,
+						"resources": [{"metadata": {"guid": "space1-guid"}, "entity": {"name": "space1", "organization_guid": "org1-guid"}}]
+					}`}}
+				})
+
+				It("finds the only space of that name", func() {
+					model, err := af.Get(app.Ref{Name: "app1", Space: "space1"}, "")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(model.Guid).To(Equal("app1-guid"))
+				})
+
+				It("reports a space that does not exist", func() {
+					responses["curl /v2/spaces?q=name%3Aspace1"] = response{output: []string{`{"next_url": null, "resources": []}`}}
+
+					_, err := af.Get(app.Ref{Name: "app1", Space: "space1"}, "")
+					Expect(err).To(MatchError("Space space1 is not found"))
+					Expect(failures.Is(err, failures.SpaceNotFoundKind)).To(BeTrue())
+				})
+
+				It("lists the orgs when the space name is ambiguous", func() {
+					responses["curl /v2/spaces?q=name%3Aspace1"] = response{output: []string{`{
+						"next_url": null,
+						"resources": [
+							{"metadata": {"guid": "space1-guid"}, "entity": {"name": "space1", "organization_guid": "org1-guid"}},
+							{"metadata": {"guid": "space2-guid"}, "entity": {"name": "space1", "organization_guid": "org2-guid"}}
+						]
+					}`}}
+					responses["curl /v2/organizations/org1-guid"] = response{output: []string{`{"metadata": {"guid": "org1-guid"}, "entity": {"name": "org1"}}`}}
+					responses["curl /v2/organizations/org2-guid"] = response{output: []string{`{"metadata": {"guid": "org2-guid"}, "entity": {"name": "org2"}}`}}
+
+					_, err := af.Get(app.Ref{Name: "app1", Space: "space1"}, "")
+					Expect(err).To(MatchError("Space space1 is ambiguous; it matches org1, org2"))
+					Expect(failures.Is(err, failures.AmbiguousKind)).To(BeTrue())
+				})
 			})
 		})
 
 		Context("when the app does not exist", func() {
 			BeforeEach(func() {
-				fakeCliConnection.CliCommandWithoutTerminalOutputReturns(
-					[]string{"FAILED", "App app1 is not found"},
-					errors.New("Error executing cli core command"),
-				)
+				responses["app app1 --guid"] = response{
+					output: []string{"FAILED", "App app1 is not found"},
+					err:    errors.New("Error executing cli core command"),
+				}
 			})
 
 			It("returns 'App not found' error", func() {
-				_, err := af.Get("app1")
+				_, err := af.Get(app.Ref{Name: "app1"}, "")
 				Expect(err).To(MatchError("App app1 is not found"))
 
 				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
 				args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)
 				Expect(args).To(ConsistOf("app", "app1", "--guid"))
 			})
-		})
 
-		Context("when curling the app model fails", func() {
-			Context("when CC returns a valid response", func() {
-				BeforeEach(func() {
-					fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
-						if fakeCliConnection.CliCommandWithoutTerminalOutputCallCount() == 1 {
-							Expect(args).To(ConsistOf("app", "app1", "--guid"))
-							return []string{"app1-guid"}, nil
-						}
-						if fakeCliConnection.CliCommandWithoutTerminalOutputCallCount() == 2 {
-							Expect(args).To(ConsistOf("curl", "/v2/apps/app1-guid"))
-							return []string{"{}"}, errors.New("Failed to acquire app1 info")
-						}
-						Expect(false).To(BeTrue())
-						return []string{}, nil
-					}
-				})
+			It("returns an AppNotFound failure", func() {
+				_, err := af.Get(app.Ref{Name: "app1"}, "")
+				Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeTrue())
+			})
+		})
 
-				It("returns 'fail to acquire app info' error", func() {
-					_, err := af.Get("app1")
+		Context("when the cli fails for another reason", func() {
+			BeforeEach(func() {
+				responses["app app1 --guid"] = response{
+					output: []string{"FAILED", "Not logged in. Use 'cf login' to log in."},
+					err:    errors.New("Error executing cli core command"),
+				}
+			})
 
-					Expect(err).To(MatchError("Failed to acquire app1 info"))
-				})
+			It("returns the cli's message", func() {
+				_, err := af.Get(app.Ref{Name: "app1"}, "")
+				Expect(err).To(MatchError("Not logged in. Use 'cf login' to log in."))
+				Expect(failures.Is(err, failures.AppNotFoundKind)).To(BeFalse())
 			})
 		})
 	})
@@ -1,11 +1,20 @@
 package credential
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 )
 
+// ErrLoginRequired is returned when the CLI can no longer refresh the access
+// token, typically because the refresh token has expired as well.
+var ErrLoginRequired = failures.LoginRequired()
+
 type CredentialFactory interface {
 	Get() (Credential, error)
 }
@@ -22,11 +31,51 @@ type Credential struct {
 	Token string
 }
 
+// String keeps tokens out of logs and error messages.
+func (c Credential) String() string {
+	return "Credential{Token: [REDACTED]}"
+}
+
+func (c Credential) GoString() string {
+	return c.String()
+}
+
+// ExpiresAt returns the expiry recorded in the token when it is a JWT, as
+// UAA tokens are.
+func (c Credential) ExpiresAt() (time.Time, bool) {
+	token := c.Token
+	if fields := strings.Fields(token); len(fields) == 2 && strings.EqualFold(fields[0], "bearer") {
+		token = fields[1]
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
+
 func (credFactory *credFactory) Get() (Credential, error) {
 	var cred Credential
 
 	output, err := credFactory.cli.CliCommandWithoutTerminalOutput("oauth-token")
 	if err != nil {
+		if loginRequired(output) {
+			return cred, ErrLoginRequired
+		}
 		return cred, errors.New("Failed to acquire oauth token")
 	}
 
@@ -34,3 +83,15 @@ func (credFactory *credFactory) Get() (Credential, error) {
 
 	return cred, nil
 }
+
+// loginRequired recognizes the cf CLI's messages for a session it cannot
+// refresh. Other failures, such as UAA being unreachable, are not matched.
+func loginRequired(output []string) bool {
+	text := strings.ToLower(strings.Join(output, " "))
+	for _, hint := range []string{"not logged in", "token expired", "invalid_token", "please log in again"} {
+		if strings.Contains(text, hint) {
+			return true
+		}
+	}
+	return false
+}
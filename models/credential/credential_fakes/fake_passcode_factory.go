@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package credential_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+)
+
+type FakePasscodeFactory struct {
+	GetStub        func() (string, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct{}
+	getReturns struct {
+		result1 string
+		result2 error
+	}
+}
+
+func (fake *FakePasscodeFactory) Get() (string, error) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct{}{})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub()
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2
+	}
+}
+
+func (fake *FakePasscodeFactory) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakePasscodeFactory) GetReturns(result1 string, result2 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+var _ credential.PasscodeFactory = new(FakePasscodeFactory)
@@ -1,9 +1,13 @@
 package credential_test
 
 import (
+	"encoding/base64"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 	"github.com/sykesm/cf-ssh-plugin/models/credential"
 
 	. "github.com/onsi/ginkgo"
@@ -58,5 +62,63 @@ var _ = Describe("Credential", func() {
 				Expect(err).To(MatchError("Failed to acquire oauth token"))
 			})
 		})
+
+		Context("when the refresh token has expired", func() {
+			It("asks the user to log in again", func() {
+				for _, output := range [][]string{
+					{"FAILED", "Not logged in. Use 'cf login' to log in."},
+					{"FAILED", "The token expired, was revoked, or the token ID is incorrect. Please log back in to re-authenticate."},
+					{"FAILED", "Server error, status code: 401, error code: invalid_token"},
+					{"FAILED", "The session has ended. Please log in again."},
+				} {
+					fakeCliConnection.CliCommandWithoutTerminalOutputReturns(output, errors.New("exit status 1"))
+
+					_, err := credFactory.Get()
+					Expect(err).To(Equal(credential.ErrLoginRequired))
+					Expect(failures.Is(err, failures.LoginRequiredKind)).To(BeTrue())
+				}
+			})
+		})
+
+		Context("when the CLI fails for another reason", func() {
+			It("does not ask the user to log in again", func() {
+				for _, output := range [][]string{
+					{"FAILED", "Error performing request: failed to login to UAA at https://login.example.com"},
+					{"FAILED", "App login-service not found"},
+				} {
+					fakeCliConnection.CliCommandWithoutTerminalOutputReturns(output, errors.New("exit status 1"))
+
+					_, err := credFactory.Get()
+					Expect(err).To(MatchError("Failed to acquire oauth token"))
+					Expect(failures.Is(err, failures.LoginRequiredKind)).To(BeFalse())
+				}
+			})
+		})
+	})
+
+	Describe("ExpiresAt", func() {
+		jwt := func(payload string) string {
+			return "bearer header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+		}
+
+		It("returns the expiry of a JWT", func() {
+			expiresAt, ok := credential.Credential{Token: jwt(`{"exp": 1451703845}`)}.ExpiresAt()
+			Expect(ok).To(BeTrue())
+			Expect(expiresAt).To(Equal(time.Unix(1451703845, 0)))
+		})
+
+		It("accepts tokens without the bearer prefix", func() {
+			token := strings.TrimPrefix(jwt(`{"exp": 1451703845}`), "bearer ")
+			_, ok := credential.Credential{Token: token}.ExpiresAt()
+			Expect(ok).To(BeTrue())
+		})
+
+		It("does not know the expiry of other tokens", func() {
+			_, ok := credential.Credential{Token: "bearer opaque-token"}.ExpiresAt()
+			Expect(ok).To(BeFalse())
+
+			_, ok = credential.Credential{Token: jwt(`{"sub": "user"}`)}.ExpiresAt()
+			Expect(ok).To(BeFalse())
+		})
 	})
 })
@@ -0,0 +1,37 @@
+package credential
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//go:generate counterfeiter -o credential_fakes/fake_passcode_factory.go . PasscodeFactory
+type PasscodeFactory interface {
+	Get() (string, error)
+}
+
+type passcodeFactory struct {
+	cli plugin.CliConnection
+}
+
+// NewPasscodeFactory fetches one-time SSH passcodes with cf ssh-code.
+func NewPasscodeFactory(cli plugin.CliConnection) PasscodeFactory {
+	return &passcodeFactory{cli: cli}
+}
+
+func (f *passcodeFactory) Get() (string, error) {
+	output, err := f.cli.CliCommandWithoutTerminalOutput("ssh-code")
+	if err != nil {
+		return "", errors.New("Failed to acquire one-time passcode")
+	}
+
+	for i := len(output) - 1; i >= 0; i-- {
+		if code := strings.TrimSpace(output[i]); code != "" {
+			return code, nil
+		}
+	}
+
+	return "", errors.New("Failed to acquire one-time passcode")
+}
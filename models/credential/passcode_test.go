@@ -0,0 +1,47 @@
+package credential_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Passcode", func() {
+	var (
+		fakeCliConnection *fakes.FakeCliConnection
+		passcodeFactory   credential.PasscodeFactory
+	)
+
+	BeforeEach(func() {
+		fakeCliConnection = &fakes.FakeCliConnection{}
+		passcodeFactory = credential.NewPasscodeFactory(fakeCliConnection)
+	})
+
+	It("returns the code printed by cf ssh-code", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"abc123\n", ""}, nil)
+
+		code, err := passcodeFactory.Get()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal("abc123"))
+
+		Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("ssh-code"))
+	})
+
+	It("fails when cf ssh-code fails", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("unknown command"))
+
+		_, err := passcodeFactory.Get()
+		Expect(err).To(MatchError("Failed to acquire one-time passcode"))
+	})
+
+	It("fails when no code is printed", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{""}, nil)
+
+		_, err := passcodeFactory.Get()
+		Expect(err).To(MatchError("Failed to acquire one-time passcode"))
+	})
+})
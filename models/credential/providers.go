@@ -0,0 +1,255 @@
+package credential
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+)
+
+const (
+	SourceCLI               = "cli"
+	SourceEnv               = "env"
+	SourceFile              = "file"
+	SourceClientCredentials = "client_credentials"
+)
+
+// Source returns the credential source selected by settings. When none is
+// configured, CF_SSH_TOKEN is used if it is set and the cf login session
+// otherwise.
+func Source(settings config.Credentials) string {
+	if settings.Source != "" {
+		return settings.Source
+	}
+	if os.Getenv("CF_SSH_TOKEN") != "" {
+		return SourceEnv
+	}
+	return SourceCLI
+}
+
+// NewFactory returns the credential factory selected by settings. The
+// client secret may be provided through CF_SSH_CLIENT_SECRET to keep it out
+// of the configuration file. Client credentials are exchanged for a token
+// with httpClient at the token endpoint cli reports in /v2/info.
+func NewFactory(cli plugin.CliConnection, settings config.Credentials, httpClient *http.Client) (CredentialFactory, error) {
+	source := Source(settings)
+
+	switch source {
+	case SourceCLI:
+		return NewCredentialFactory(cli), nil
+	case SourceEnv:
+		return NewEnvCredentialFactory(), nil
+	case SourceFile:
+		if settings.TokenFile == "" {
+			return nil, errors.New("Credential source file requires token_file")
+		}
+		return NewFileCredentialFactory(settings.TokenFile), nil
+	case SourceClientCredentials:
+		clientID := settings.ClientID
+		if clientID == "" {
+			clientID = os.Getenv("CF_SSH_CLIENT_ID")
+		}
+		clientSecret := settings.ClientSecret
+		if clientSecret == "" {
+			clientSecret = os.Getenv("CF_SSH_CLIENT_SECRET")
+		}
+		if clientID == "" {
+			return nil, errors.New("Credential source client_credentials requires client_id")
+		}
+		return NewCachingFactory(NewClientCredentialsFactory(info.NewInfoFactory(cli), clientID, clientSecret, httpClient)), nil
+	default:
+		return nil, fmt.Errorf("Unknown credential source: %s", source)
+	}
+}
+
+type envCredentialFactory struct{}
+
+// NewEnvCredentialFactory reads the access token from CF_SSH_TOKEN.
+func NewEnvCredentialFactory() CredentialFactory {
+	return &envCredentialFactory{}
+}
+
+func (f *envCredentialFactory) Get() (Credential, error) {
+	token := strings.TrimSpace(os.Getenv("CF_SSH_TOKEN"))
+	if token == "" {
+		return Credential{}, errors.New("Failed to acquire oauth token: CF_SSH_TOKEN is not set")
+	}
+	return Credential{Token: bearer(token)}, nil
+}
+
+type fileCredentialFactory struct {
+	path string
+}
+
+// NewFileCredentialFactory reads the access token from a file each time one
+// is needed, so a token rotated by another process is picked up.
+func NewFileCredentialFactory(path string) CredentialFactory {
+	return &fileCredentialFactory{path: path}
+}
+
+func (f *fileCredentialFactory) Get() (Credential, error) {
+	contents, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return Credential{}, fmt.Errorf("Failed to acquire oauth token from %s", f.path)
+	}
+
+	token := strings.TrimSpace(string(contents))
+	if token == "" {
+		return Credential{}, fmt.Errorf("Failed to acquire oauth token from %s", f.path)
+	}
+
+	return Credential{Token: bearer(token)}, nil
+}
+
+// ExpirySkew is how long before its recorded expiry a cached credential is
+// replaced, so it does not expire between being handed out and being used.
+const ExpirySkew = time.Minute
+
+type cachingFactory struct {
+	factory CredentialFactory
+
+	mutex sync.Mutex
+	cred  *Credential
+}
+
+// NewCachingFactory reuses the credentials of factory until ExpirySkew
+// before they expire. Credentials without a recorded expiry are not cached.
+func NewCachingFactory(factory CredentialFactory) CredentialFactory {
+	return &cachingFactory{factory: factory}
+}
+
+func (f *cachingFactory) Get() (Credential, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.cred != nil {
+		if expiresAt, ok := f.cred.ExpiresAt(); ok && time.Now().Add(ExpirySkew).Before(expiresAt) {
+			return *f.cred, nil
+		}
+	}
+
+	return f.get()
+}
+
+// Refresh replaces the cached credential even when it has not expired.
+func (f *cachingFactory) Refresh() (Credential, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.get()
+}
+
+func (f *cachingFactory) get() (Credential, error) {
+	f.cred = nil
+
+	cred, err := f.factory.Get()
+	if err != nil {
+		return cred, err
+	}
+
+	f.cred = &cred
+	return cred, nil
+}
+
+// Refresh returns a new credential from factory, bypassing any cache. It is
+// used when the endpoint has rejected the credential Get returned.
+func Refresh(factory CredentialFactory) (Credential, error) {
+	if refresher, ok := factory.(interface {
+		Refresh() (Credential, error)
+	}); ok {
+		return refresher.Refresh()
+	}
+	return factory.Get()
+}
+
+type clientCredentialsFactory struct {
+	infoFactory  info.InfoFactory
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	tokenEndpoint string
+}
+
+// NewClientCredentialsFactory requests a token from UAA with a client
+// credentials grant. The UAA endpoint is discovered from /v2/info the first
+// time a token is requested.
+func NewClientCredentialsFactory(infoFactory info.InfoFactory, clientID, clientSecret string, httpClient *http.Client) CredentialFactory {
+	return &clientCredentialsFactory{
+		infoFactory:  infoFactory,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+	}
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+func (f *clientCredentialsFactory) Get() (Credential, error) {
+	if f.tokenEndpoint == "" {
+		endpointInfo, err := f.infoFactory.Get()
+		if err != nil {
+			return Credential{}, err
+		}
+		if endpointInfo.TokenEndpoint == "" {
+			return Credential{}, errors.New("Failed to acquire oauth token: no token endpoint advertised")
+		}
+		f.tokenEndpoint = endpointInfo.TokenEndpoint
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"response_type": {"token"},
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(f.tokenEndpoint, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credential{}, errors.New("Failed to acquire oauth token")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(f.clientID, f.clientSecret)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return Credential{}, fmt.Errorf("Failed to acquire oauth token: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Credential{}, errors.New("Failed to acquire oauth token")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Credential{}, fmt.Errorf("Failed to acquire oauth token: %s", resp.Status)
+	}
+
+	token := tokenResponse{}
+	err = json.Unmarshal(body, &token)
+	if err != nil || token.AccessToken == "" {
+		return Credential{}, errors.New("Failed to acquire oauth token")
+	}
+
+	return Credential{Token: bearer(token.AccessToken)}, nil
+}
+
+// bearer formats a token the way cf oauth-token does.
+func bearer(token string) string {
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		return token
+	}
+	return "bearer " + token
+}
@@ -0,0 +1,314 @@
+package credential_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/credential"
+	"github.com/sykesm/cf-ssh-plugin/models/credential/credential_fakes"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/models/info/info_fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Providers", func() {
+	var savedToken, savedClientID, savedClientSecret string
+
+	BeforeEach(func() {
+		savedToken = os.Getenv("CF_SSH_TOKEN")
+		savedClientID = os.Getenv("CF_SSH_CLIENT_ID")
+		savedClientSecret = os.Getenv("CF_SSH_CLIENT_SECRET")
+		os.Setenv("CF_SSH_TOKEN", "")
+		os.Setenv("CF_SSH_CLIENT_ID", "")
+		os.Setenv("CF_SSH_CLIENT_SECRET", "")
+	})
+
+	AfterEach(func() {
+		os.Setenv("CF_SSH_TOKEN", savedToken)
+		os.Setenv("CF_SSH_CLIENT_ID", savedClientID)
+		os.Setenv("CF_SSH_CLIENT_SECRET", savedClientSecret)
+	})
+
+	Describe("Credential", func() {
+		It("does not format the token", func() {
+			cred := credential.Credential{Token: "bearer secret-token"}
+			Expect(fmt.Sprintf("%s %v %+v %#v", cred, cred, cred, cred)).NotTo(ContainSubstring("secret-token"))
+		})
+	})
+
+	Describe("NewFactory", func() {
+		var fakeCliConnection *fakes.FakeCliConnection
+
+		BeforeEach(func() {
+			fakeCliConnection = &fakes.FakeCliConnection{}
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"bearer from-cli"}, nil)
+			os.Setenv("CF_SSH_TOKEN", "from-env")
+		})
+
+		It("uses the cf session by default", func() {
+			os.Setenv("CF_SSH_TOKEN", "")
+			factory, err := credential.NewFactory(fakeCliConnection, config.Credentials{}, http.DefaultClient)
+			Expect(err).NotTo(HaveOccurred())
+
+			cred, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer from-cli"))
+		})
+
+		It("prefers CF_SSH_TOKEN when it is set", func() {
+			factory, err := credential.NewFactory(fakeCliConnection, config.Credentials{}, http.DefaultClient)
+			Expect(err).NotTo(HaveOccurred())
+
+			cred, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer from-env"))
+		})
+
+		It("honors an explicit source", func() {
+			factory, err := credential.NewFactory(fakeCliConnection, config.Credentials{Source: "cli"}, http.DefaultClient)
+			Expect(err).NotTo(HaveOccurred())
+
+			cred, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer from-cli"))
+		})
+
+		It("rejects incomplete or unknown sources", func() {
+			_, err := credential.NewFactory(fakeCliConnection, config.Credentials{Source: "file"}, http.DefaultClient)
+			Expect(err).To(MatchError("Credential source file requires token_file"))
+
+			_, err = credential.NewFactory(fakeCliConnection, config.Credentials{Source: "client_credentials"}, http.DefaultClient)
+			Expect(err).To(MatchError("Credential source client_credentials requires client_id"))
+
+			_, err = credential.NewFactory(fakeCliConnection, config.Credentials{Source: "vault"}, http.DefaultClient)
+			Expect(err).To(MatchError("Unknown credential source: vault"))
+		})
+
+		It("reports the selected source", func() {
+			Expect(credential.Source(config.Credentials{})).To(Equal(credential.SourceEnv))
+			Expect(credential.Source(config.Credentials{Source: "file"})).To(Equal(credential.SourceFile))
+
+			os.Setenv("CF_SSH_TOKEN", "")
+			Expect(credential.Source(config.Credentials{})).To(Equal(credential.SourceCLI))
+		})
+
+		It("takes the client id from the environment", func() {
+			os.Setenv("CF_SSH_CLIENT_ID", "ci")
+			_, err := credential.NewFactory(fakeCliConnection, config.Credentials{Source: "client_credentials"}, http.DefaultClient)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("EnvCredentialFactory", func() {
+		It("adds the bearer prefix when it is missing", func() {
+			os.Setenv("CF_SSH_TOKEN", "abc123\n")
+			cred, err := credential.NewEnvCredentialFactory().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer abc123"))
+
+			os.Setenv("CF_SSH_TOKEN", "Bearer abc123")
+			cred, err = credential.NewEnvCredentialFactory().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("Bearer abc123"))
+		})
+
+		It("fails when the variable is not set", func() {
+			_, err := credential.NewEnvCredentialFactory().Get()
+			Expect(err).To(MatchError("Failed to acquire oauth token: CF_SSH_TOKEN is not set"))
+		})
+	})
+
+	Describe("FileCredentialFactory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "credential")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the token from the file", func() {
+			path := filepath.Join(dir, "token")
+			Expect(ioutil.WriteFile(path, []byte("abc123\n"), 0600)).To(Succeed())
+
+			cred, err := credential.NewFileCredentialFactory(path).Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer abc123"))
+		})
+
+		It("fails when the file is missing", func() {
+			path := filepath.Join(dir, "missing")
+			_, err := credential.NewFileCredentialFactory(path).Get()
+			Expect(err).To(MatchError("Failed to acquire oauth token from " + path))
+		})
+	})
+
+	Describe("ClientCredentialsFactory", func() {
+		var (
+			fakeInfoFactory *info_fakes.FakeInfoFactory
+			server          *httptest.Server
+			requests        []*http.Request
+			statusCode      int
+		)
+
+		BeforeEach(func() {
+			requests = nil
+			statusCode = http.StatusOK
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				requests = append(requests, r)
+				w.WriteHeader(statusCode)
+				w.Write([]byte(`{"access_token": "uaa-token", "token_type": "bearer"}`))
+			}))
+
+			fakeInfoFactory = &info_fakes.FakeInfoFactory{}
+			fakeInfoFactory.GetReturns(info.Info{TokenEndpoint: server.URL + "/"}, nil)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("requests a token with a client credentials grant", func() {
+			factory := credential.NewClientCredentialsFactory(fakeInfoFactory, "ci", "s3cret", http.DefaultClient)
+
+			cred, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred.Token).To(Equal("bearer uaa-token"))
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Method).To(Equal("POST"))
+			Expect(requests[0].URL.Path).To(Equal("/oauth/token"))
+			Expect(requests[0].PostForm.Get("grant_type")).To(Equal("client_credentials"))
+
+			user, password, ok := requests[0].BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(user).To(Equal("ci"))
+			Expect(password).To(Equal("s3cret"))
+		})
+
+		It("discovers the token endpoint only once", func() {
+			factory := credential.NewClientCredentialsFactory(fakeInfoFactory, "ci", "s3cret", http.DefaultClient)
+
+			_, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeInfoFactory.GetCallCount()).To(Equal(1))
+			Expect(requests).To(HaveLen(2))
+		})
+
+		It("fails when UAA rejects the client", func() {
+			statusCode = http.StatusUnauthorized
+			factory := credential.NewClientCredentialsFactory(fakeInfoFactory, "ci", "wrong", http.DefaultClient)
+
+			_, err := factory.Get()
+			Expect(err).To(MatchError("Failed to acquire oauth token: 401 Unauthorized"))
+		})
+
+		It("fails when the token endpoint can't be discovered", func() {
+			fakeInfoFactory.GetReturns(info.Info{}, errors.New("Failed to acquire SSH endpoint info"))
+			factory := credential.NewClientCredentialsFactory(fakeInfoFactory, "ci", "s3cret", http.DefaultClient)
+
+			_, err := factory.Get()
+			Expect(err).To(MatchError("Failed to acquire SSH endpoint info"))
+		})
+	})
+
+	Describe("CachingFactory", func() {
+		var (
+			fakeFactory *credential_fakes.FakeCredentialFactory
+			factory     credential.CredentialFactory
+		)
+
+		jwt := func(expiresAt time.Time) string {
+			payload := fmt.Sprintf(`{"exp": %d}`, expiresAt.Unix())
+			return "bearer header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+		}
+
+		BeforeEach(func() {
+			fakeFactory = &credential_fakes.FakeCredentialFactory{}
+			factory = credential.NewCachingFactory(fakeFactory)
+		})
+
+		It("reuses a credential until shortly before it expires", func() {
+			fakeFactory.GetReturns(credential.Credential{Token: jwt(time.Now().Add(time.Hour))}, nil)
+
+			first, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			second, err := factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(second).To(Equal(first))
+			Expect(fakeFactory.GetCallCount()).To(Equal(1))
+		})
+
+		It("replaces a credential that is about to expire", func() {
+			fakeFactory.GetReturns(credential.Credential{Token: jwt(time.Now().Add(credential.ExpirySkew / 2))}, nil)
+
+			factory.Get()
+			factory.Get()
+
+			Expect(fakeFactory.GetCallCount()).To(Equal(2))
+		})
+
+		It("does not cache a credential without a recorded expiry", func() {
+			fakeFactory.GetReturns(credential.Credential{Token: "bearer opaque-token"}, nil)
+
+			factory.Get()
+			factory.Get()
+
+			Expect(fakeFactory.GetCallCount()).To(Equal(2))
+		})
+
+		It("does not cache failures", func() {
+			fakeFactory.GetReturns(credential.Credential{}, errors.New("woops"))
+
+			_, err := factory.Get()
+			Expect(err).To(MatchError("woops"))
+
+			fakeFactory.GetReturns(credential.Credential{Token: jwt(time.Now().Add(time.Hour))}, nil)
+			_, err = factory.Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeFactory.GetCallCount()).To(Equal(2))
+		})
+
+		Describe("Refresh", func() {
+			It("replaces a cached credential that has not expired", func() {
+				fakeFactory.GetReturns(credential.Credential{Token: jwt(time.Now().Add(time.Hour))}, nil)
+				factory.Get()
+
+				fakeFactory.GetReturns(credential.Credential{Token: "bearer new-token"}, nil)
+				cred, err := credential.Refresh(factory)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cred.Token).To(Equal("bearer new-token"))
+				Expect(fakeFactory.GetCallCount()).To(Equal(2))
+			})
+
+			It("gets a credential from factories without a cache", func() {
+				fakeFactory.GetReturns(credential.Credential{Token: "bearer token"}, nil)
+
+				cred, err := credential.Refresh(fakeFactory)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cred.Token).To(Equal("bearer token"))
+			})
+		})
+	})
+})
@@ -2,9 +2,9 @@ package info
 
 import (
 	"encoding/json"
-	"errors"
 
 	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 )
 
 type InfoFactory interface {
@@ -22,6 +22,7 @@ func NewInfoFactory(cli plugin.CliConnection) InfoFactory {
 type Info struct {
 	SSHEndpoint            string `json:"app_ssh_endpoint"`
 	SSHEndpointFingerprint string `json:"app_ssh_host_key_fingerprint"`
+	TokenEndpoint          string `json:"token_endpoint"`
 }
 
 func (ifactory *infoFactory) Get() (Info, error) {
@@ -29,14 +30,17 @@ func (ifactory *infoFactory) Get() (Info, error) {
 
 	output, err := ifactory.cli.CliCommandWithoutTerminalOutput("curl", "/v2/info")
 	if err != nil {
-		return info, errors.New("Failed to acquire SSH endpoint info")
+		return info, failures.APIRequest("Failed to acquire SSH endpoint info", err)
+	}
+	if len(output) == 0 {
+		return info, failures.APIRequest("Failed to acquire SSH endpoint info", nil)
 	}
 
 	response := []byte(output[0])
 
 	err = json.Unmarshal(response, &info)
 	if err != nil {
-		return info, errors.New("Failed to acquire SSH endpoint info")
+		return info, failures.APIRequest("Failed to acquire SSH endpoint info", err)
 	}
 
 	return info, nil
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/failures"
 	"github.com/sykesm/cf-ssh-plugin/models/info"
 
 	. "github.com/onsi/ginkgo"
@@ -32,7 +33,8 @@ var _ = Describe("Info", func() {
 			BeforeEach(func() {
 				expectedJson = `{
 					"app_ssh_endpoint": "ssh.example.com:1234",
-					"app_ssh_host_key_fingerprint": "00:11:22:33:44:55:66:77:88"
+					"app_ssh_host_key_fingerprint": "00:11:22:33:44:55:66:77:88",
+					"token_endpoint": "https://uaa.example.com"
 				}`
 
 				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{expectedJson}, nil)
@@ -47,6 +49,7 @@ var _ = Describe("Info", func() {
 
 				Expect(model.SSHEndpoint).To(Equal("ssh.example.com:1234"))
 				Expect(model.SSHEndpointFingerprint).To(Equal("00:11:22:33:44:55:66:77:88"))
+				Expect(model.TokenEndpoint).To(Equal("https://uaa.example.com"))
 			})
 		})
 
@@ -57,7 +60,8 @@ var _ = Describe("Info", func() {
 
 			It("fails with an error", func() {
 				_, err := infoFactory.Get()
-				Expect(err).To(MatchError("Failed to acquire SSH endpoint info"))
+				Expect(err).To(MatchError("Failed to acquire SSH endpoint info: woops"))
+				Expect(failures.Is(err, failures.APIRequestKind)).To(BeTrue())
 			})
 		})
 
@@ -68,7 +72,7 @@ var _ = Describe("Info", func() {
 
 			It("fails with an error", func() {
 				_, err := infoFactory.Get()
-				Expect(err).To(MatchError("Failed to acquire SSH endpoint info"))
+				Expect(err).To(MatchError(HavePrefix("Failed to acquire SSH endpoint info: ")))
 			})
 		})
 	})
@@ -0,0 +1,175 @@
+package instances
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+)
+
+//go:generate counterfeiter -o instances_fakes/fake_instances_factory.go . InstancesFactory
+type InstancesFactory interface {
+	Get(appGuid string) ([]Instance, error)
+	Stats(appGuid string) ([]Instance, error)
+	ProcessStats(processGuid string) ([]Instance, error)
+}
+
+type instancesFactory struct {
+	cli plugin.CliConnection
+}
+
+func NewInstancesFactory(cli plugin.CliConnection) InstancesFactory {
+	return &instancesFactory{cli: cli}
+}
+
+type Instance struct {
+	Index int
+	State string
+	Since float64
+
+	// Resource usage is only reported by Stats. CPU is the fraction of a
+	// core in use; memory and disk are in bytes.
+	Uptime      time.Duration
+	CPU         float64
+	Memory      uint64
+	MemoryQuota uint64
+	Disk        uint64
+	DiskQuota   uint64
+}
+
+type cfInstance struct {
+	State string  `json:"state"`
+	Since float64 `json:"since"`
+}
+
+type cfInstanceStats struct {
+	State string `json:"state"`
+	Stats struct {
+		Uptime    int64  `json:"uptime"`
+		MemQuota  uint64 `json:"mem_quota"`
+		DiskQuota uint64 `json:"disk_quota"`
+		Usage     struct {
+			CPU  float64 `json:"cpu"`
+			Mem  uint64  `json:"mem"`
+			Disk uint64  `json:"disk"`
+		} `json:"usage"`
+	} `json:"stats"`
+}
+
+// Get returns the instances of an app ordered by index.
+func (f *instancesFactory) Get(appGuid string) ([]Instance, error) {
+	output, err := f.cli.CliCommandWithoutTerminalOutput("curl", "/v2/apps/"+appGuid+"/instances")
+	if err != nil || len(output) == 0 {
+		return nil, failures.APIRequest("Failed to acquire instance information", err)
+	}
+
+	response := map[string]cfInstance{}
+	err = json.Unmarshal([]byte(output[0]), &response)
+	if err != nil {
+		return nil, failures.APIRequest("Failed to acquire instance information", err)
+	}
+
+	instances := []Instance{}
+	for key, instance := range response {
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, failures.APIRequest("Failed to acquire instance information", err)
+		}
+		instances = append(instances, Instance{Index: index, State: instance.State, Since: instance.Since})
+	}
+	sort.Sort(byIndex(instances))
+
+	return instances, nil
+}
+
+// Stats returns the instances of an app with their resource usage, ordered
+// by index.
+func (f *instancesFactory) Stats(appGuid string) ([]Instance, error) {
+	output, err := f.cli.CliCommandWithoutTerminalOutput("curl", "/v2/apps/"+appGuid+"/stats")
+	if err != nil || len(output) == 0 {
+		return nil, failures.APIRequest("Failed to acquire instance statistics", err)
+	}
+
+	response := map[string]cfInstanceStats{}
+	err = json.Unmarshal([]byte(output[0]), &response)
+	if err != nil {
+		return nil, failures.APIRequest("Failed to acquire instance statistics", err)
+	}
+
+	instances := []Instance{}
+	for key, instance := range response {
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, failures.APIRequest("Failed to acquire instance statistics", err)
+		}
+		instances = append(instances, Instance{
+			Index:       index,
+			State:       instance.State,
+			Uptime:      time.Duration(instance.Stats.Uptime) * time.Second,
+			CPU:         instance.Stats.Usage.CPU,
+			Memory:      instance.Stats.Usage.Mem,
+			MemoryQuota: instance.Stats.MemQuota,
+			Disk:        instance.Stats.Usage.Disk,
+			DiskQuota:   instance.Stats.DiskQuota,
+		})
+	}
+	sort.Sort(byIndex(instances))
+
+	return instances, nil
+}
+
+type v3ProcessStats struct {
+	Resources []struct {
+		Index     int    `json:"index"`
+		State     string `json:"state"`
+		Uptime    int64  `json:"uptime"`
+		MemQuota  uint64 `json:"mem_quota"`
+		DiskQuota uint64 `json:"disk_quota"`
+		Usage     struct {
+			CPU  float64 `json:"cpu"`
+			Mem  uint64  `json:"mem"`
+			Disk uint64  `json:"disk"`
+		} `json:"usage"`
+	} `json:"resources"`
+}
+
+// ProcessStats returns the instances of a v3 process with their resource
+// usage, ordered by index. Unlike Stats, it covers processes other than web.
+func (f *instancesFactory) ProcessStats(processGuid string) ([]Instance, error) {
+	output, err := f.cli.CliCommandWithoutTerminalOutput("curl", "/v3/processes/"+processGuid+"/stats")
+	if err != nil || len(output) == 0 {
+		return nil, failures.APIRequest("Failed to acquire instance statistics", err)
+	}
+
+	response := v3ProcessStats{}
+	err = json.Unmarshal([]byte(output[0]), &response)
+	if err != nil {
+		return nil, failures.APIRequest("Failed to acquire instance statistics", err)
+	}
+
+	instances := []Instance{}
+	for _, instance := range response.Resources {
+		instances = append(instances, Instance{
+			Index:       instance.Index,
+			State:       instance.State,
+			Uptime:      time.Duration(instance.Uptime) * time.Second,
+			CPU:         instance.Usage.CPU,
+			Memory:      instance.Usage.Mem,
+			MemoryQuota: instance.MemQuota,
+			Disk:        instance.Usage.Disk,
+			DiskQuota:   instance.DiskQuota,
+		})
+	}
+	sort.Sort(byIndex(instances))
+
+	return instances, nil
+}
+
+type byIndex []Instance
+
+func (b byIndex) Len() int           { return len(b) }
+func (b byIndex) Less(i, j int) bool { return b[i].Index < b[j].Index }
+func (b byIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,139 @@
+// This file was generated by counterfeiter
+package instances_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+)
+
+type FakeInstancesFactory struct {
+	GetStub        func(appGuid string) ([]instances.Instance, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		appGuid string
+	}
+	getReturns struct {
+		result1 []instances.Instance
+		result2 error
+	}
+	StatsStub        func(appGuid string) ([]instances.Instance, error)
+	statsMutex       sync.RWMutex
+	statsArgsForCall []struct {
+		appGuid string
+	}
+	statsReturns struct {
+		result1 []instances.Instance
+		result2 error
+	}
+	ProcessStatsStub        func(processGuid string) ([]instances.Instance, error)
+	processStatsMutex       sync.RWMutex
+	processStatsArgsForCall []struct {
+		processGuid string
+	}
+	processStatsReturns struct {
+		result1 []instances.Instance
+		result2 error
+	}
+}
+
+func (fake *FakeInstancesFactory) Get(appGuid string) ([]instances.Instance, error) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		appGuid string
+	}{appGuid})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub(appGuid)
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2
+	}
+}
+
+func (fake *FakeInstancesFactory) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeInstancesFactory) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return fake.getArgsForCall[i].appGuid
+}
+
+func (fake *FakeInstancesFactory) GetReturns(result1 []instances.Instance, result2 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 []instances.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeInstancesFactory) Stats(appGuid string) ([]instances.Instance, error) {
+	fake.statsMutex.Lock()
+	fake.statsArgsForCall = append(fake.statsArgsForCall, struct {
+		appGuid string
+	}{appGuid})
+	fake.statsMutex.Unlock()
+	if fake.StatsStub != nil {
+		return fake.StatsStub(appGuid)
+	} else {
+		return fake.statsReturns.result1, fake.statsReturns.result2
+	}
+}
+
+func (fake *FakeInstancesFactory) StatsCallCount() int {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return len(fake.statsArgsForCall)
+}
+
+func (fake *FakeInstancesFactory) StatsArgsForCall(i int) string {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return fake.statsArgsForCall[i].appGuid
+}
+
+func (fake *FakeInstancesFactory) StatsReturns(result1 []instances.Instance, result2 error) {
+	fake.StatsStub = nil
+	fake.statsReturns = struct {
+		result1 []instances.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeInstancesFactory) ProcessStats(processGuid string) ([]instances.Instance, error) {
+	fake.processStatsMutex.Lock()
+	fake.processStatsArgsForCall = append(fake.processStatsArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	fake.processStatsMutex.Unlock()
+	if fake.ProcessStatsStub != nil {
+		return fake.ProcessStatsStub(processGuid)
+	} else {
+		return fake.processStatsReturns.result1, fake.processStatsReturns.result2
+	}
+}
+
+func (fake *FakeInstancesFactory) ProcessStatsCallCount() int {
+	fake.processStatsMutex.RLock()
+	defer fake.processStatsMutex.RUnlock()
+	return len(fake.processStatsArgsForCall)
+}
+
+func (fake *FakeInstancesFactory) ProcessStatsArgsForCall(i int) string {
+	fake.processStatsMutex.RLock()
+	defer fake.processStatsMutex.RUnlock()
+	return fake.processStatsArgsForCall[i].processGuid
+}
+
+func (fake *FakeInstancesFactory) ProcessStatsReturns(result1 []instances.Instance, result2 error) {
+	fake.ProcessStatsStub = nil
+	fake.processStatsReturns = struct {
+		result1 []instances.Instance
+		result2 error
+	}{result1, result2}
+}
+
+var _ instances.InstancesFactory = new(FakeInstancesFactory)
@@ -0,0 +1,13 @@
+package instances_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestInstances(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Instances Suite")
+}
@@ -0,0 +1,176 @@
+package instances_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instances", func() {
+	var (
+		fakeCliConnection *fakes.FakeCliConnection
+		instancesFactory  instances.InstancesFactory
+	)
+
+	BeforeEach(func() {
+		fakeCliConnection = &fakes.FakeCliConnection{}
+		instancesFactory = instances.NewInstancesFactory(fakeCliConnection)
+	})
+
+	Describe("Get", func() {
+		Context("when retrieving the instances is successful", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{
+					"1": {"state": "STARTING", "since": 1445026000.5},
+					"0": {"state": "RUNNING", "since": 1445025000.25}
+				}`}, nil)
+			})
+
+			It("returns the instances ordered by index", func() {
+				models, err := instancesFactory.Get("app-guid")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("curl", "/v2/apps/app-guid/instances"))
+
+				Expect(models).To(Equal([]instances.Instance{
+					{Index: 0, State: "RUNNING", Since: 1445025000.25},
+					{Index: 1, State: "STARTING", Since: 1445026000.5},
+				}))
+			})
+		})
+
+		Context("when the curl fails", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("woops"))
+			})
+
+			It("fails with an error", func() {
+				_, err := instancesFactory.Get("app-guid")
+				Expect(err).To(MatchError("Failed to acquire instance information: woops"))
+				Expect(failures.Is(err, failures.APIRequestKind)).To(BeTrue())
+			})
+		})
+
+		Context("when the response is not an instance map", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{"code": 170002, "description": "App has not finished staging"}`}, nil)
+			})
+
+			It("fails with an error", func() {
+				_, err := instancesFactory.Get("app-guid")
+				Expect(err).To(MatchError(HavePrefix("Failed to acquire instance information: ")))
+			})
+		})
+	})
+
+	Describe("Stats", func() {
+		Context("when retrieving the statistics is successful", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{
+					"1": {"state": "DOWN", "since": 1445026000.5},
+					"0": {
+						"state": "RUNNING",
+						"stats": {
+							"uptime": 7384,
+							"mem_quota": 268435456,
+							"disk_quota": 1073741824,
+							"usage": {"cpu": 0.012, "mem": 134217728, "disk": 83886080}
+						}
+					}
+				}`}, nil)
+			})
+
+			It("returns the instances and their usage ordered by index", func() {
+				models, err := instancesFactory.Stats("app-guid")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("curl", "/v2/apps/app-guid/stats"))
+
+				Expect(models).To(Equal([]instances.Instance{
+					{
+						Index:       0,
+						State:       "RUNNING",
+						Uptime:      7384 * time.Second,
+						CPU:         0.012,
+						Memory:      134217728,
+						MemoryQuota: 268435456,
+						Disk:        83886080,
+						DiskQuota:   1073741824,
+					},
+					{Index: 1, State: "DOWN"},
+				}))
+			})
+		})
+
+		Context("when the curl fails", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("woops"))
+			})
+
+			It("fails with an error", func() {
+				_, err := instancesFactory.Stats("app-guid")
+				Expect(err).To(MatchError("Failed to acquire instance statistics: woops"))
+			})
+		})
+	})
+
+	Describe("ProcessStats", func() {
+		Context("when retrieving the statistics is successful", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{
+					"resources": [
+						{"type": "worker", "index": 1, "state": "CRASHED"},
+						{
+							"type": "worker",
+							"index": 0,
+							"state": "RUNNING",
+							"uptime": 7384,
+							"mem_quota": 268435456,
+							"disk_quota": 1073741824,
+							"usage": {"cpu": 0.012, "mem": 134217728, "disk": 83886080}
+						}
+					]
+				}`}, nil)
+			})
+
+			It("returns the instances and their usage ordered by index", func() {
+				models, err := instancesFactory.ProcessStats("process-guid")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("curl", "/v3/processes/process-guid/stats"))
+
+				Expect(models).To(Equal([]instances.Instance{
+					{
+						Index:       0,
+						State:       "RUNNING",
+						Uptime:      7384 * time.Second,
+						CPU:         0.012,
+						Memory:      134217728,
+						MemoryQuota: 268435456,
+						Disk:        83886080,
+						DiskQuota:   1073741824,
+					},
+					{Index: 1, State: "CRASHED"},
+				}))
+			})
+		})
+
+		Context("when the curl fails", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("woops"))
+			})
+
+			It("fails with an error", func() {
+				_, err := instancesFactory.ProcessStats("process-guid")
+				Expect(err).To(MatchError("Failed to acquire instance statistics: woops"))
+			})
+		})
+	})
+})
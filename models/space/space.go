@@ -0,0 +1,60 @@
+package space
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+)
+
+//go:generate counterfeiter -o space_fakes/fake_space_factory.go . SpaceFactory
+type SpaceFactory interface {
+	Get(spaceGuid string) (Space, error)
+}
+
+type spaceFactory struct {
+	cli plugin.CliConnection
+}
+
+func NewSpaceFactory(cli plugin.CliConnection) SpaceFactory {
+	return &spaceFactory{cli: cli}
+}
+
+type Space struct {
+	Guid     string
+	Name     string
+	AllowSSH bool
+}
+
+type metadata struct {
+	Guid string `json:"guid"`
+}
+
+type entity struct {
+	Name     string `json:"name"`
+	AllowSSH bool   `json:"allow_ssh"`
+}
+
+type cfSpace struct {
+	Metadata metadata `json:"metadata"`
+	Entity   entity   `json:"entity"`
+}
+
+func (sf *spaceFactory) Get(spaceGuid string) (Space, error) {
+	output, err := sf.cli.CliCommandWithoutTerminalOutput("curl", "/v2/spaces/"+spaceGuid)
+	if err != nil || len(output) == 0 {
+		return Space{}, failures.APIRequest("Failed to acquire space info", err)
+	}
+
+	space := cfSpace{}
+	err = json.Unmarshal([]byte(output[0]), &space)
+	if err != nil {
+		return Space{}, failures.APIRequest("Failed to acquire space info", err)
+	}
+
+	return Space{
+		Guid:     space.Metadata.Guid,
+		Name:     space.Entity.Name,
+		AllowSSH: space.Entity.AllowSSH,
+	}, nil
+}
@@ -0,0 +1,55 @@
+// This file was generated by counterfeiter
+package space_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+)
+
+type FakeSpaceFactory struct {
+	GetStub        func(spaceGuid string) (space.Space, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		spaceGuid string
+	}
+	getReturns struct {
+		result1 space.Space
+		result2 error
+	}
+}
+
+func (fake *FakeSpaceFactory) Get(spaceGuid string) (space.Space, error) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		spaceGuid string
+	}{spaceGuid})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub(spaceGuid)
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2
+	}
+}
+
+func (fake *FakeSpaceFactory) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeSpaceFactory) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return fake.getArgsForCall[i].spaceGuid
+}
+
+func (fake *FakeSpaceFactory) GetReturns(result1 space.Space, result2 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 space.Space
+		result2 error
+	}{result1, result2}
+}
+
+var _ space.SpaceFactory = new(FakeSpaceFactory)
@@ -0,0 +1,76 @@
+package space_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Space", func() {
+	var (
+		fakeCliConnection *fakes.FakeCliConnection
+		sf                space.SpaceFactory
+	)
+
+	BeforeEach(func() {
+		fakeCliConnection = &fakes.FakeCliConnection{}
+		sf = space.NewSpaceFactory(fakeCliConnection)
+	})
+
+	Describe("Get", func() {
+		Context("when CC returns a valid response", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{
+					"metadata": {
+						"guid": "space1-guid"
+					},
+					"entity": {
+						"name": "development",
+						"allow_ssh": true
+					}
+				}`}, nil)
+			})
+
+			It("returns a populated Space model", func() {
+				model, err := sf.Get("space1-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(model).To(Equal(space.Space{
+					Guid:     "space1-guid",
+					Name:     "development",
+					AllowSSH: true,
+				}))
+
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("curl", "/v2/spaces/space1-guid"))
+			})
+		})
+
+		Context("when the curl fails", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{}, errors.New("woops"))
+			})
+
+			It("returns an error", func() {
+				_, err := sf.Get("space1-guid")
+				Expect(err).To(MatchError("Failed to acquire space info: woops"))
+				Expect(failures.Is(err, failures.APIRequestKind)).To(BeTrue())
+			})
+		})
+
+		Context("when the response is not JSON", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{"garbage"}, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := sf.Get("space1-guid")
+				Expect(err).To(MatchError(HavePrefix("Failed to acquire space info: ")))
+			})
+		})
+	})
+})
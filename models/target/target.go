@@ -0,0 +1,120 @@
+package target
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/homedir"
+)
+
+//go:generate counterfeiter -o target_fakes/fake_target_factory.go . TargetFactory
+type TargetFactory interface {
+	Get() (Target, error)
+}
+
+type targetFactory struct {
+	cli plugin.CliConnection
+}
+
+func NewTargetFactory(cli plugin.CliConnection) TargetFactory {
+	return &targetFactory{cli: cli}
+}
+
+type Target struct {
+	API               string
+	Org               string
+	Space             string
+	SkipSSLValidation bool
+}
+
+func (tf *targetFactory) Get() (Target, error) {
+	var target Target
+
+	output, err := tf.cli.CliCommandWithoutTerminalOutput("target")
+	if err != nil {
+		return target, errors.New("Failed to acquire target information")
+	}
+
+	for _, line := range output {
+		for _, l := range strings.Split(line, "\n") {
+			parts := strings.SplitN(l, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			value := strings.TrimSpace(parts[1])
+			switch strings.TrimSpace(parts[0]) {
+			case "API endpoint":
+				if fields := strings.Fields(value); len(fields) > 0 {
+					target.API = fields[0]
+				}
+			case "Org":
+				target.Org = value
+			case "Space":
+				target.Space = value
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// ConfigPath returns the location of the cf CLI's own configuration file,
+// which is under CF_HOME when it is set.
+func ConfigPath() string {
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		home = homedir.Dir()
+	}
+	return filepath.Join(home, ".cf", "config.json")
+}
+
+type configTargetFactory struct {
+	path string
+}
+
+// NewConfigTargetFactory reads the target from the cf CLI configuration file
+// rather than running cf target, which requires a cf login session. A
+// missing file is an empty target, as when cf api has never been run.
+func NewConfigTargetFactory(path string) TargetFactory {
+	return &configTargetFactory{path: path}
+}
+
+type cliConfig struct {
+	Target             string `json:"Target"`
+	SSLDisabled        bool   `json:"SSLDisabled"`
+	OrganizationFields struct {
+		Name string `json:"Name"`
+	} `json:"OrganizationFields"`
+	SpaceFields struct {
+		Name string `json:"Name"`
+	} `json:"SpaceFields"`
+}
+
+func (tf *configTargetFactory) Get() (Target, error) {
+	contents, err := ioutil.ReadFile(tf.path)
+	if os.IsNotExist(err) {
+		return Target{}, nil
+	}
+	if err != nil {
+		return Target{}, errors.New("Failed to acquire target information")
+	}
+
+	var cfg cliConfig
+	err = json.Unmarshal(contents, &cfg)
+	if err != nil {
+		return Target{}, errors.New("Failed to acquire target information")
+	}
+
+	return Target{
+		API:               cfg.Target,
+		Org:               cfg.OrganizationFields.Name,
+		Space:             cfg.SpaceFields.Name,
+		SkipSSLValidation: cfg.SSLDisabled,
+	}, nil
+}
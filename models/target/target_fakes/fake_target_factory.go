@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package target_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+)
+
+type FakeTargetFactory struct {
+	GetStub        func() (target.Target, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct{}
+	getReturns struct {
+		result1 target.Target
+		result2 error
+	}
+}
+
+func (fake *FakeTargetFactory) Get() (target.Target, error) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct{}{})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub()
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2
+	}
+}
+
+func (fake *FakeTargetFactory) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeTargetFactory) GetReturns(result1 target.Target, result2 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 target.Target
+		result2 error
+	}{result1, result2}
+}
+
+var _ target.TargetFactory = new(FakeTargetFactory)
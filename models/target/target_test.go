@@ -0,0 +1,130 @@
+package target_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Target", func() {
+	var (
+		fakeCliConnection *fakes.FakeCliConnection
+		targetFactory     target.TargetFactory
+	)
+
+	BeforeEach(func() {
+		fakeCliConnection = &fakes.FakeCliConnection{}
+		targetFactory = target.NewTargetFactory(fakeCliConnection)
+	})
+
+	Describe("Get", func() {
+		Context("when cf target is successful", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{
+					"",
+					"API endpoint:   https://api.example.com (API version: 2.23.0)",
+					"User:           admin",
+					"Org:            org1",
+					"Space:          space1",
+				}, nil)
+			})
+
+			It("returns a populated Target model", func() {
+				model, err := targetFactory.Get()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+				Expect(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(ConsistOf("target"))
+
+				Expect(model.API).To(Equal("https://api.example.com"))
+				Expect(model.Org).To(Equal("org1"))
+				Expect(model.Space).To(Equal("space1"))
+			})
+		})
+
+		Context("when no org or space is targeted", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{
+					"API endpoint:   https://api.example.com (API version: 2.23.0)",
+					"User:           admin",
+					"No org or space targeted, use 'cf target -o ORG -s SPACE'",
+				}, nil)
+			})
+
+			It("returns the API endpoint only", func() {
+				model, err := targetFactory.Get()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(model.API).To(Equal("https://api.example.com"))
+				Expect(model.Org).To(BeEmpty())
+				Expect(model.Space).To(BeEmpty())
+			})
+		})
+
+		Context("when cf target fails", func() {
+			BeforeEach(func() {
+				fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("woops"))
+			})
+
+			It("fails with an error", func() {
+				_, err := targetFactory.Get()
+				Expect(err).To(MatchError("Failed to acquire target information"))
+			})
+		})
+	})
+
+	Describe("ConfigTargetFactory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "target")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the target from the cf CLI configuration", func() {
+			path := filepath.Join(dir, "config.json")
+			err := ioutil.WriteFile(path, []byte(`{
+				"Target": "https://api.example.com",
+				"SSLDisabled": true,
+				"OrganizationFields": {"GUID": "org-guid", "Name": "org1"},
+				"SpaceFields": {"GUID": "space-guid", "Name": "space1"}
+			}`), 0600)
+			Expect(err).NotTo(HaveOccurred())
+
+			model, err := target.NewConfigTargetFactory(path).Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(model).To(Equal(target.Target{
+				API:               "https://api.example.com",
+				Org:               "org1",
+				Space:             "space1",
+				SkipSSLValidation: true,
+			}))
+		})
+
+		It("returns an empty target when there is no configuration", func() {
+			model, err := target.NewConfigTargetFactory(filepath.Join(dir, "missing.json")).Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(model).To(Equal(target.Target{}))
+		})
+
+		It("fails when the configuration is malformed", func() {
+			path := filepath.Join(dir, "config.json")
+			Expect(ioutil.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+
+			_, err := target.NewConfigTargetFactory(path).Get()
+			Expect(err).To(MatchError("Failed to acquire target information"))
+		})
+	})
+})
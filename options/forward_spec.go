@@ -0,0 +1,114 @@
+package options
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ForwardSpec describes a local port forward in the form accepted by -L:
+// [bind_address:]port:host:hostport
+type ForwardSpec struct {
+	ListenAddress  string
+	ConnectAddress string
+}
+
+func (s ForwardSpec) String() string {
+	return s.ListenAddress + ":" + s.ConnectAddress
+}
+
+func ParseForwardSpec(arg string) (ForwardSpec, error) {
+	parts, err := splitForwardSpec(arg)
+	if err != nil {
+		return ForwardSpec{}, err
+	}
+
+	var bindAddress string
+	switch len(parts) {
+	case 3:
+		bindAddress = "localhost"
+	case 4:
+		bindAddress, parts = parts[0], parts[1:]
+	default:
+		return ForwardSpec{}, fmt.Errorf("Unable to parse local forwarding argument: %q", arg)
+	}
+
+	listenPort, connectHost, connectPort := parts[0], parts[1], parts[2]
+	if bindAddress == "*" {
+		bindAddress = ""
+	}
+
+	if !validPort(listenPort) || !validPort(connectPort) || connectHost == "" {
+		return ForwardSpec{}, fmt.Errorf("Unable to parse local forwarding argument: %q", arg)
+	}
+
+	return ForwardSpec{
+		ListenAddress:  net.JoinHostPort(bindAddress, listenPort),
+		ConnectAddress: net.JoinHostPort(connectHost, connectPort),
+	}, nil
+}
+
+// ParseListenAddress parses the [bind_address:]port form used to cancel a
+// forward and returns the listen address a ForwardSpec would have.
+func ParseListenAddress(arg string) (string, error) {
+	parts, err := splitForwardSpec(arg)
+	if err != nil {
+		return "", err
+	}
+
+	bindAddress := "localhost"
+	switch len(parts) {
+	case 1:
+	case 2:
+		bindAddress, parts = parts[0], parts[1:]
+	default:
+		return "", fmt.Errorf("Unable to parse listen address: %q", arg)
+	}
+
+	if bindAddress == "*" {
+		bindAddress = ""
+	}
+	if !validPort(parts[0]) {
+		return "", fmt.Errorf("Unable to parse listen address: %q", arg)
+	}
+
+	return net.JoinHostPort(bindAddress, parts[0]), nil
+}
+
+// splitForwardSpec splits on colons while keeping bracketed IPv6 addresses
+// intact.
+func splitForwardSpec(spec string) ([]string, error) {
+	parts := []string{}
+	for arg := spec; len(arg) > 0; {
+		var part string
+		if strings.HasPrefix(arg, "[") {
+			end := strings.Index(arg, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Unable to parse local forwarding argument: %q", spec)
+			}
+			part, arg = arg[1:end], arg[end+1:]
+			if len(arg) > 0 && arg[0] != ':' {
+				return nil, fmt.Errorf("Unable to parse local forwarding argument: %q", spec)
+			}
+		} else if idx := strings.Index(arg, ":"); idx >= 0 {
+			part, arg = arg[:idx], arg[idx:]
+		} else {
+			part, arg = arg, ""
+		}
+
+		parts = append(parts, part)
+		if len(arg) > 0 {
+			arg = arg[1:]
+			if len(arg) == 0 {
+				parts = append(parts, "")
+			}
+		}
+	}
+	return parts, nil
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p >= 0 && p <= 65535
+}
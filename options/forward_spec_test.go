@@ -0,0 +1,85 @@
+package options_test
+
+import (
+	"github.com/sykesm/cf-ssh-plugin/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ForwardSpec", func() {
+	Describe("ParseForwardSpec", func() {
+		It("defaults the bind address to localhost", func() {
+			spec, err := options.ParseForwardSpec("8080:example.com:80")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec).To(Equal(options.ForwardSpec{
+				ListenAddress:  "localhost:8080",
+				ConnectAddress: "example.com:80",
+			}))
+		})
+
+		It("uses the provided bind address", func() {
+			spec, err := options.ParseForwardSpec("127.0.0.1:8080:example.com:80")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.ListenAddress).To(Equal("127.0.0.1:8080"))
+		})
+
+		It("binds to all interfaces for '*'", func() {
+			spec, err := options.ParseForwardSpec("*:8080:example.com:80")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.ListenAddress).To(Equal(":8080"))
+		})
+
+		It("supports bracketed IPv6 addresses", func() {
+			spec, err := options.ParseForwardSpec("[::1]:8080:[fe80::1]:80")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec).To(Equal(options.ForwardSpec{
+				ListenAddress:  "[::1]:8080",
+				ConnectAddress: "[fe80::1]:80",
+			}))
+		})
+
+		It("renders the spec as listen and connect addresses", func() {
+			spec, err := options.ParseForwardSpec("8080:example.com:80")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.String()).To(Equal("localhost:8080:example.com:80"))
+		})
+
+		Context("when the spec is invalid", func() {
+			invalidSpecs := map[string]string{
+				"too few parts":        "8080:example.com",
+				"too many parts":       "a:1:b:2:c",
+				"non-numeric port":     "http:example.com:80",
+				"port out of range":    "8080:example.com:70000",
+				"missing host":         "8080::80",
+				"unterminated bracket": "[::1:8080:example.com:80",
+			}
+
+			for description, arg := range invalidSpecs {
+				arg := arg
+				It("returns an error for "+description, func() {
+					_, err := options.ParseForwardSpec(arg)
+					Expect(err).To(MatchError(ContainSubstring("Unable to parse local forwarding argument")))
+				})
+			}
+		})
+	})
+	Describe("ParseListenAddress", func() {
+		It("defaults the bind address to localhost", func() {
+			Expect(options.ParseListenAddress("8080")).To(Equal("localhost:8080"))
+		})
+
+		It("uses the provided bind address", func() {
+			Expect(options.ParseListenAddress("0.0.0.0:8080")).To(Equal("0.0.0.0:8080"))
+			Expect(options.ParseListenAddress("[::1]:8080")).To(Equal("[::1]:8080"))
+		})
+
+		It("rejects invalid addresses", func() {
+			_, err := options.ParseListenAddress("localhost:http")
+			Expect(err).To(MatchError(`Unable to parse listen address: "localhost:http"`))
+
+			_, err = options.ParseListenAddress("8080:db:5432")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
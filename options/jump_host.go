@@ -0,0 +1,43 @@
+package options
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// JumpHost describes a bastion in the form accepted by -J:
+// [user@]host[:port]
+type JumpHost struct {
+	User    string
+	Address string
+}
+
+func (j JumpHost) String() string {
+	if j.User == "" {
+		return j.Address
+	}
+	return j.User + "@" + j.Address
+}
+
+func ParseJumpHost(arg string) (JumpHost, error) {
+	var user string
+	hostPort := arg
+	if i := strings.LastIndex(arg, "@"); i >= 0 {
+		user, hostPort = arg[:i], arg[i+1:]
+		if user == "" {
+			return JumpHost{}, fmt.Errorf("Unable to parse jump host: %q", arg)
+		}
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = strings.Trim(hostPort, "[]"), "22"
+	}
+
+	if host == "" || strings.ContainsAny(host, "[]/") || !validPort(port) {
+		return JumpHost{}, fmt.Errorf("Unable to parse jump host: %q", arg)
+	}
+
+	return JumpHost{User: user, Address: net.JoinHostPort(host, port)}, nil
+}
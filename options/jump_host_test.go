@@ -0,0 +1,43 @@
+package options_test
+
+import (
+	"github.com/sykesm/cf-ssh-plugin/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JumpHost", func() {
+	Describe("ParseJumpHost", func() {
+		It("parses a user, host, and port", func() {
+			jumpHost, err := options.ParseJumpHost("ops@bastion.example.com:2200")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jumpHost).To(Equal(options.JumpHost{User: "ops", Address: "bastion.example.com:2200"}))
+			Expect(jumpHost.String()).To(Equal("ops@bastion.example.com:2200"))
+		})
+
+		It("defaults the port to 22 and leaves the user empty", func() {
+			jumpHost, err := options.ParseJumpHost("bastion.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jumpHost).To(Equal(options.JumpHost{Address: "bastion.example.com:22"}))
+			Expect(jumpHost.String()).To(Equal("bastion.example.com:22"))
+		})
+
+		It("accepts IPv6 addresses", func() {
+			jumpHost, err := options.ParseJumpHost("ops@[::1]:2200")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jumpHost.Address).To(Equal("[::1]:2200"))
+
+			jumpHost, err = options.ParseJumpHost("[::1]")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jumpHost.Address).To(Equal("[::1]:22"))
+		})
+
+		It("rejects malformed jump hosts", func() {
+			for _, arg := range []string{"", "@bastion", "bastion:", "bastion:ssh", "bastion:70000", "ops@"} {
+				_, err := options.ParseJumpHost(arg)
+				Expect(err).To(MatchError(ContainSubstring("Unable to parse jump host")), arg)
+			}
+		})
+	})
+})
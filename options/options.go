@@ -2,59 +2,703 @@ package options
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry/cli/flags"
 	"github.com/cloudfoundry/cli/flags/flag"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+)
+
+type TTYRequest int
+
+const (
+	RequestTTYAuto TTYRequest = iota
+	RequestTTYNo
+	RequestTTYYes
+	RequestTTYForce
+)
+
+// InstanceSelector is how the instance to connect to is chosen. Only
+// SelectIndex uses Options.Instance; the others are resolved against the
+// app's instances before connecting. SelectAll runs a command on every
+// running instance.
+type InstanceSelector int
+
+const (
+	SelectIndex InstanceSelector = iota
+	SelectPrompt
+	SelectRandom
+	SelectLeastLoaded
+	SelectAll
 )
 
 type Options struct {
-	AppName            string
-	Instance           int
-	LocalPort          uint16
-	ForwardDestination string
-	LocalProxy         bool
-	LocalPortForward   bool
-	SkipHostValidation bool
+	AppName             string
+	Org                 string
+	Space               string
+	ByGuid              bool
+	Process             string
+	Instance            int
+	InstanceSelector    InstanceSelector
+	ForwardSpecs        []ForwardSpec
+	Command             string
+	TerminalRequest     TTYRequest
+	ConnectTimeout      time.Duration
+	HandshakeTimeout    time.Duration
+	ConnectAttempts     int
+	Wait                bool
+	KeepAliveInterval   time.Duration
+	KeepAliveCountMax   int
+	IdleTimeout         time.Duration
+	EscapeChar          byte
+	Env                 map[string]string
+	SendEnv             []string
+	ForwardAgent        bool
+	SSHEndpoint         string
+	SSHFingerprint      string
+	Proxy               string
+	JumpHost            JumpHost
+	LocalProxy          bool
+	SkipHostValidation  bool
+	SkipRemoteExecution bool
+	Reconnect           bool
+	Verbosity           int
+	Output              OutputFormat
+
+	// Config and Target are consulted for defaults before the flags are
+	// applied. Both are optional.
+	Config *config.Config
+	Target target.Target
 }
 
+const (
+	DefaultConnectTimeout    = 30 * time.Second
+	DefaultHandshakeTimeout  = 30 * time.Second
+	DefaultConnectAttempts   = 3
+	DefaultKeepAliveInterval = 30 * time.Second
+	DefaultKeepAliveCountMax = 3
+	DefaultEscapeChar        = '~'
+)
+
 var UsageError = errors.New("Invalid usage")
 
 func (o *Options) Parse(args []string) error {
+	_, err := o.parse(args, setupFlags())
+	return err
+}
+
+// infoFlags are the flags ssh-info accepts: those that locate the instance
+// and its endpoint, and how to print them.
+var infoFlags = map[string]bool{
+	"i":               true,
+	"org":             true,
+	"space":           true,
+	"guid":            true,
+	"process":         true,
+	"ssh-endpoint":    true,
+	"ssh-fingerprint": true,
+	"output":          true,
+	"v":               true,
+	"vv":              true,
+}
+
+// ParseInfo parses the arguments to ssh-info, rejecting the ssh flags that
+// only matter when connecting.
+func (o *Options) ParseInfo(args []string) error {
+	flagSet := setupFlags()
+	fc, err := o.parse(args, flagSet)
+	if err != nil {
+		return err
+	}
+
+	if name := unsupportedFlag(fc, flagSet, infoFlags); name != "" {
+		return fmt.Errorf("Flag '%s' is not supported by cf ssh-info", name)
+	}
+	return nil
+}
+
+// parse applies the ssh flags in flagSet and returns the context so callers
+// that add flags of their own can read them.
+func (o *Options) parse(args []string, flagSet map[string]flags.FlagSet) (flags.FlagContext, error) {
 	if len(args) == 0 {
-		return UsageError
+		return nil, UsageError
 	}
 
-	fc := flags.NewFlagContext(setupFlags())
+	fc := flags.NewFlagContext(flagSet)
 	err := fc.Parse(args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(fc.Args()) != 1 {
-		return UsageError
+		return nil, UsageError
 	}
 
 	o.AppName = fc.Args()[0]
+	o.setBuiltinDefaults()
+
+	err = o.parseAppLocation(fc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.applyConfigDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	o.applyEnvironment()
 
 	if fc.IsSet("i") {
-		instance := fc.Int("i")
-		if instance < 0 {
-			return errors.New("Value for flag 'i' must not be negative")
+		o.Instance, o.InstanceSelector, err = parseInstance(fc.String("i"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fc.IsSet("process") {
+		o.Process = fc.String("process")
+		if o.Process == "" {
+			return nil, errors.New("Value for flag 'process' must not be empty")
+		}
+	}
+
+	if fc.IsSet("L") {
+		o.ForwardSpecs, err = parseForwardSpecs(fc.StringSlice("L"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fc.IsSet("c") {
+		o.Command = fc.String("c")
+		if o.Command == "" {
+			return nil, errors.New("Value for flag 'c' must not be empty")
+		}
+	}
+
+	ttyFlags := 0
+	for _, name := range []string{"t", "tt", "T"} {
+		if fc.IsSet(name) && fc.Bool(name) {
+			ttyFlags++
+		}
+	}
+	if ttyFlags > 1 {
+		return nil, errors.New("Only one of -t, -tt, or -T may be provided")
+	}
+
+	switch {
+	case fc.Bool("t"):
+		o.TerminalRequest = RequestTTYYes
+	case fc.Bool("tt"):
+		o.TerminalRequest = RequestTTYForce
+	case fc.Bool("T"):
+		o.TerminalRequest = RequestTTYNo
+	}
+
+	if fc.IsSet("connect-timeout") {
+		o.ConnectTimeout, err = parseTimeout(fc.String("connect-timeout"))
+		if err != nil {
+			return nil, errors.New("Value for flag 'connect-timeout' must be a duration")
+		}
+	}
+
+	if fc.IsSet("handshake-timeout") {
+		o.HandshakeTimeout, err = parseTimeout(fc.String("handshake-timeout"))
+		if err != nil {
+			return nil, errors.New("Value for flag 'handshake-timeout' must be a duration")
 		}
+	}
 
-		o.Instance = fc.Int("i")
+	if fc.IsSet("connect-attempts") {
+		o.ConnectAttempts = fc.Int("connect-attempts")
+		if o.ConnectAttempts < 1 {
+			return nil, errors.New("Value for flag 'connect-attempts' must be positive")
+		}
+	}
+
+	if fc.IsSet("wait") {
+		o.Wait = fc.Bool("wait")
+	}
+
+	if fc.IsSet("keepalive-interval") {
+		o.KeepAliveInterval, err = parseTimeout(fc.String("keepalive-interval"))
+		if err != nil {
+			return nil, errors.New("Value for flag 'keepalive-interval' must be a duration")
+		}
+	}
+
+	if fc.IsSet("keepalive-count") {
+		o.KeepAliveCountMax = fc.Int("keepalive-count")
+		if o.KeepAliveCountMax < 1 {
+			return nil, errors.New("Value for flag 'keepalive-count' must be positive")
+		}
+	}
+
+	if fc.IsSet("idle-timeout") {
+		o.IdleTimeout, err = parseTimeout(fc.String("idle-timeout"))
+		if err != nil {
+			return nil, errors.New("Value for flag 'idle-timeout' must be a duration")
+		}
+	}
+
+	if fc.IsSet("escape-char") {
+		o.EscapeChar, err = parseEscapeChar(fc.String("escape-char"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fc.IsSet("e") {
+		for _, arg := range fc.StringSlice("e") {
+			name, value, err := parseEnvAssignment(arg)
+			if err != nil {
+				return nil, err
+			}
+			if o.Env == nil {
+				o.Env = map[string]string{}
+			}
+			o.Env[name] = value
+		}
+	}
+
+	if fc.IsSet("send-env") {
+		for _, pattern := range fc.StringSlice("send-env") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid send-env pattern: %s", pattern)
+			}
+			o.SendEnv = append(o.SendEnv, pattern)
+		}
+	}
+
+	if fc.IsSet("A") {
+		o.ForwardAgent = fc.Bool("A")
+	}
+
+	if fc.IsSet("ssh-endpoint") {
+		o.SSHEndpoint = fc.String("ssh-endpoint")
+	}
+
+	if fc.IsSet("ssh-fingerprint") {
+		o.SSHFingerprint = fc.String("ssh-fingerprint")
+	}
+
+	if o.SSHEndpoint != "" {
+		if _, _, err := net.SplitHostPort(o.SSHEndpoint); err != nil {
+			return nil, fmt.Errorf("Invalid SSH endpoint: %s", o.SSHEndpoint)
+		}
+	}
+
+	if fc.IsSet("proxy") {
+		o.Proxy = fc.String("proxy")
+	}
+
+	if fc.IsSet("J") {
+		o.JumpHost, err = ParseJumpHost(fc.String("J"))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if fc.IsSet("skip-host-validation") {
 		o.SkipHostValidation = fc.Bool("skip-host-validation")
 	}
 
+	if fc.IsSet("N") {
+		o.SkipRemoteExecution = fc.Bool("N")
+	}
+
+	if o.SkipRemoteExecution && o.Command != "" {
+		return nil, errors.New("Only one of -N or -c may be provided")
+	}
+
+	if o.InstanceSelector == SelectAll {
+		switch {
+		case o.Command == "":
+			return nil, errors.New("Value all for flag 'i' requires -c")
+		case len(o.ForwardSpecs) > 0:
+			return nil, errors.New("Only one of -L or -i all may be provided")
+		case o.TerminalRequest == RequestTTYYes || o.TerminalRequest == RequestTTYForce:
+			return nil, errors.New("A pty cannot be requested with -i all")
+		}
+	}
+
+	if fc.IsSet("reconnect") {
+		o.Reconnect = fc.Bool("reconnect")
+	}
+
+	if fc.IsSet("output") {
+		o.Output, err = ParseOutputFormat(fc.String("output"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case fc.Bool("vv"):
+		o.Verbosity = 2
+	case fc.Bool("v"):
+		o.Verbosity = 1
+	}
+
+	return fc, nil
+}
+
+// unsupportedFlag returns the first flag in flagSet, by name, that is set
+// but not allowed, or "" when there is none.
+func unsupportedFlag(fc flags.FlagContext, flagSet map[string]flags.FlagSet, allowed map[string]bool) string {
+	names := []string{}
+	for name := range flagSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fc.IsSet(name) && !allowed[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseInstance reads the -i flag: an instance index, random or
+// least-loaded to choose one from the running instances, or all.
+func parseInstance(value string) (int, InstanceSelector, error) {
+	switch value {
+	case "random":
+		return 0, SelectRandom, nil
+	case "least-loaded":
+		return 0, SelectLeastLoaded, nil
+	case "all":
+		return 0, SelectAll, nil
+	}
+
+	instance, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, SelectIndex, errors.New("Value for flag 'i' must be an instance index, random, least-loaded, or all")
+	}
+	if instance < 0 {
+		return 0, SelectIndex, errors.New("Value for flag 'i' must not be negative")
+	}
+
+	return instance, SelectIndex, nil
+}
+
+// parseAppLocation reads where to find the app: in another org and space,
+// or by GUID, instead of in the targeted space.
+func (o *Options) parseAppLocation(fc flags.FlagContext) error {
+	for _, name := range []string{"org", "space"} {
+		if fc.IsSet(name) && fc.String(name) == "" {
+			return fmt.Errorf("Value for flag '%s' must not be empty", name)
+		}
+	}
+
+	o.Org = fc.String("org")
+	o.Space = fc.String("space")
+	o.ByGuid = fc.Bool("guid")
+
+	if o.Org != "" && o.Space == "" {
+		return errors.New("Flag 'org' requires flag 'space'")
+	}
+	if o.ByGuid && o.Space != "" {
+		return errors.New("Only one of --guid or --space may be provided")
+	}
+
+	return nil
+}
+
+func (o *Options) setBuiltinDefaults() {
+	o.ConnectTimeout = DefaultConnectTimeout
+	o.HandshakeTimeout = DefaultHandshakeTimeout
+	o.ConnectAttempts = DefaultConnectAttempts
+	o.KeepAliveInterval = DefaultKeepAliveInterval
+	o.KeepAliveCountMax = DefaultKeepAliveCountMax
+	o.EscapeChar = DefaultEscapeChar
+	o.Output = OutputText
+	o.InstanceSelector = SelectPrompt
+}
+
+// applyConfigDefaults applies the configured defaults for the app, matching
+// app sections against the target with any org and space already chosen.
+func (o *Options) applyConfigDefaults() error {
+	if o.Config == nil {
+		return nil
+	}
+
+	t := o.Target
+	if o.Org != "" {
+		t.Org = o.Org
+	}
+	if o.Space != "" {
+		t.Space = o.Space
+	}
+
+	return o.applyDefaults(o.Config.DefaultsFor(t, o.AppName))
+}
+
+func (o *Options) applyDefaults(defaults config.Defaults) error {
+	var err error
+
+	if defaults.Instance != nil {
+		if *defaults.Instance < 0 {
+			return errors.New("Configured instance must not be negative")
+		}
+		o.Instance = *defaults.Instance
+		o.InstanceSelector = SelectIndex
+	}
+
+	if len(defaults.Forwards) > 0 {
+		o.ForwardSpecs, err = parseForwardSpecs(defaults.Forwards)
+		if err != nil {
+			return fmt.Errorf("Invalid configured forward: %s", err)
+		}
+	}
+
+	if defaults.Pty != "" {
+		o.TerminalRequest, err = parseTTYRequest(defaults.Pty)
+		if err != nil {
+			return err
+		}
+	}
+
+	if defaults.ConnectTimeout != "" {
+		o.ConnectTimeout, err = parseTimeout(defaults.ConnectTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid configured connect_timeout: %s", defaults.ConnectTimeout)
+		}
+	}
+
+	if defaults.HandshakeTimeout != "" {
+		o.HandshakeTimeout, err = parseTimeout(defaults.HandshakeTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid configured handshake_timeout: %s", defaults.HandshakeTimeout)
+		}
+	}
+
+	if defaults.ConnectAttempts != nil {
+		if *defaults.ConnectAttempts < 1 {
+			return errors.New("Configured connect_attempts must be positive")
+		}
+		o.ConnectAttempts = *defaults.ConnectAttempts
+	}
+
+	if defaults.Wait != nil {
+		o.Wait = *defaults.Wait
+	}
+
+	if defaults.KeepAliveInterval != "" {
+		o.KeepAliveInterval, err = parseTimeout(defaults.KeepAliveInterval)
+		if err != nil {
+			return fmt.Errorf("Invalid configured keepalive_interval: %s", defaults.KeepAliveInterval)
+		}
+	}
+
+	if defaults.KeepAliveCountMax != nil {
+		if *defaults.KeepAliveCountMax < 1 {
+			return errors.New("Configured keepalive_count must be positive")
+		}
+		o.KeepAliveCountMax = *defaults.KeepAliveCountMax
+	}
+
+	if defaults.IdleTimeout != "" {
+		o.IdleTimeout, err = parseTimeout(defaults.IdleTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid configured idle_timeout: %s", defaults.IdleTimeout)
+		}
+	}
+
+	if defaults.EscapeChar != "" {
+		o.EscapeChar, err = parseEscapeChar(defaults.EscapeChar)
+		if err != nil {
+			return err
+		}
+	}
+
+	if defaults.Reconnect != nil {
+		o.Reconnect = *defaults.Reconnect
+	}
+
+	if defaults.ForwardAgent != nil {
+		o.ForwardAgent = *defaults.ForwardAgent
+	}
+
+	if defaults.SSHEndpoint != "" {
+		o.SSHEndpoint = defaults.SSHEndpoint
+	}
+
+	if defaults.SSHFingerprint != "" {
+		o.SSHFingerprint = defaults.SSHFingerprint
+	}
+
+	if defaults.Proxy != "" {
+		o.Proxy = defaults.Proxy
+	}
+
+	if defaults.JumpHost != "" {
+		o.JumpHost, err = ParseJumpHost(defaults.JumpHost)
+		if err != nil {
+			return fmt.Errorf("Invalid configured jump_host: %s", err)
+		}
+	}
+
+	for k, v := range defaults.Env {
+		if o.Env == nil {
+			o.Env = map[string]string{}
+		}
+		o.Env[k] = v
+	}
+
+	if len(defaults.SendEnv) > 0 {
+		o.SendEnv = append([]string{}, defaults.SendEnv...)
+	}
+
 	return nil
 }
 
+// applyEnvironment applies the SSH endpoint overrides from the environment.
+func (o *Options) applyEnvironment() {
+	if endpoint := os.Getenv("CF_SSH_ENDPOINT"); endpoint != "" {
+		o.SSHEndpoint = endpoint
+	}
+
+	if fingerprint := os.Getenv("CF_SSH_FINGERPRINT"); fingerprint != "" {
+		o.SSHFingerprint = fingerprint
+	}
+}
+
+// Environment returns the variables to send to the remote session. Entries
+// from environ, formatted as KEY=VALUE, are included when their name matches
+// one of the SendEnv patterns. Explicitly set variables take precedence.
+func (o *Options) Environment(environ []string) map[string]string {
+	env := map[string]string{}
+
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			continue
+		}
+		for _, pattern := range o.SendEnv {
+			if matched, _ := path.Match(pattern, parts[0]); matched {
+				env[parts[0]] = parts[1]
+				break
+			}
+		}
+	}
+
+	for k, v := range o.Env {
+		env[k] = v
+	}
+
+	return env
+}
+
+func parseForwardSpecs(specs []string) ([]ForwardSpec, error) {
+	forwardSpecs := []ForwardSpec{}
+	for _, arg := range specs {
+		spec, err := ParseForwardSpec(arg)
+		if err != nil {
+			return nil, err
+		}
+		forwardSpecs = append(forwardSpecs, spec)
+	}
+	return forwardSpecs, nil
+}
+
+func parseTTYRequest(mode string) (TTYRequest, error) {
+	switch mode {
+	case "auto":
+		return RequestTTYAuto, nil
+	case "no", "disable":
+		return RequestTTYNo, nil
+	case "yes", "request":
+		return RequestTTYYes, nil
+	case "force":
+		return RequestTTYForce, nil
+	default:
+		return RequestTTYAuto, fmt.Errorf("Invalid configured pty mode: %s", mode)
+	}
+}
+
+func parseEnvAssignment(arg string) (string, string, error) {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("Invalid environment variable: %s. Expected KEY=VALUE", arg)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseEscapeChar accepts a single character, a control character written
+// as ^X, or "none" to disable escapes.
+func parseEscapeChar(value string) (byte, error) {
+	switch {
+	case value == "none":
+		return 0, nil
+	case len(value) == 1:
+		return value[0], nil
+	case len(value) == 2 && value[0] == '^' && value[1] >= '@' && value[1] <= '_':
+		return value[1] & 0x1f, nil
+	default:
+		return 0, fmt.Errorf("Invalid escape character: %s", value)
+	}
+}
+
+// parseTimeout accepts a Go duration or a bare number of seconds.
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = fmt.Sprintf("%ds", seconds)
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 {
+		return 0, errors.New("timeout must not be negative")
+	}
+
+	return timeout, nil
+}
+
 func setupFlags() map[string]flags.FlagSet {
 	fs := make(map[string]flags.FlagSet)
-	fs["i"] = &cliFlags.IntFlag{Name: "i", Usage: ""}
+	fs["i"] = &cliFlags.StringFlag{Name: "i", Usage: ""}
+	fs["org"] = &cliFlags.StringFlag{Name: "org", Usage: ""}
+	fs["space"] = &cliFlags.StringFlag{Name: "space", Usage: ""}
+	fs["guid"] = &cliFlags.BoolFlag{Name: "guid", Usage: ""}
+	fs["process"] = &cliFlags.StringFlag{Name: "process", Usage: ""}
+	fs["L"] = &cliFlags.StringSliceFlag{Name: "L", Usage: ""}
+	fs["c"] = &cliFlags.StringFlag{Name: "c", Usage: ""}
+	fs["t"] = &cliFlags.BoolFlag{Name: "t", Usage: ""}
+	fs["tt"] = &cliFlags.BoolFlag{Name: "tt", Usage: ""}
+	fs["T"] = &cliFlags.BoolFlag{Name: "T", Usage: ""}
+	fs["connect-timeout"] = &cliFlags.StringFlag{Name: "connect-timeout", Usage: ""}
+	fs["handshake-timeout"] = &cliFlags.StringFlag{Name: "handshake-timeout", Usage: ""}
+	fs["connect-attempts"] = &cliFlags.IntFlag{Name: "connect-attempts", Usage: ""}
+	fs["wait"] = &cliFlags.BoolFlag{Name: "wait", Usage: ""}
+	fs["keepalive-interval"] = &cliFlags.StringFlag{Name: "keepalive-interval", Usage: ""}
+	fs["keepalive-count"] = &cliFlags.IntFlag{Name: "keepalive-count", Usage: ""}
+	fs["idle-timeout"] = &cliFlags.StringFlag{Name: "idle-timeout", Usage: ""}
+	fs["N"] = &cliFlags.BoolFlag{Name: "N", Usage: ""}
+	fs["reconnect"] = &cliFlags.BoolFlag{Name: "reconnect", Usage: ""}
+	fs["e"] = &cliFlags.StringSliceFlag{Name: "e", Usage: ""}
+	fs["send-env"] = &cliFlags.StringSliceFlag{Name: "send-env", Usage: ""}
+	fs["A"] = &cliFlags.BoolFlag{Name: "A", Usage: ""}
+	fs["ssh-endpoint"] = &cliFlags.StringFlag{Name: "ssh-endpoint", Usage: ""}
+	fs["ssh-fingerprint"] = &cliFlags.StringFlag{Name: "ssh-fingerprint", Usage: ""}
+	fs["proxy"] = &cliFlags.StringFlag{Name: "proxy", Usage: ""}
+	fs["J"] = &cliFlags.StringFlag{Name: "J", Usage: ""}
+	fs["escape-char"] = &cliFlags.StringFlag{Name: "escape-char", Usage: ""}
 	fs["skip-host-validation"] = &cliFlags.BoolFlag{Name: "skip-host-validation", Usage: ""}
+	fs["v"] = &cliFlags.BoolFlag{Name: "v", Usage: ""}
+	fs["vv"] = &cliFlags.BoolFlag{Name: "vv", Usage: ""}
+	fs["output"] = &cliFlags.StringFlag{Name: "output", Usage: ""}
 	return fs
 }
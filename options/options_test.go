@@ -1,6 +1,11 @@
 package options_test
 
 import (
+	"os"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
 	"github.com/sykesm/cf-ssh-plugin/options"
 
 	. "github.com/onsi/ginkgo"
@@ -54,6 +59,11 @@ var _ = Describe("Options", func() {
 				Expect(parseError).NotTo(HaveOccurred())
 				Expect(opts.AppName).To(Equal("App-1"))
 			})
+
+			It("leaves the instance to be chosen", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.InstanceSelector).To(Equal(options.SelectPrompt))
+			})
 		})
 
 		Context("as the last argument", func() {
@@ -90,6 +100,281 @@ var _ = Describe("Options", func() {
 		})
 	})
 
+	Context("when -N is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-N"}
+		})
+
+		It("skips remote execution", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.SkipRemoteExecution).To(BeTrue())
+		})
+	})
+
+	Context("when --reconnect is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--reconnect"}
+		})
+
+		It("enables automatic reconnects", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Reconnect).To(BeTrue())
+		})
+	})
+
+	Context("when -v is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-v"}
+		})
+
+		It("sets the verbosity to 1", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Verbosity).To(Equal(1))
+		})
+	})
+
+	Context("when -vv is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-v", "-vv"}
+		})
+
+		It("sets the verbosity to 2", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Verbosity).To(Equal(2))
+		})
+	})
+
+	Context("when --output is not set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name"}
+		})
+
+		It("defaults to text", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Output).To(Equal(options.OutputText))
+		})
+	})
+
+	Context("when --output is json", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--output", "json"}
+		})
+
+		It("selects json output", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Output).To(Equal(options.OutputJSON))
+		})
+	})
+
+	Context("when --output is unknown", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--output", "yaml"}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError("Value for flag 'output' must be text or json"))
+		})
+	})
+
+	Context("when --process is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--process", "worker"}
+		})
+
+		It("selects the process", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Process).To(Equal("worker"))
+		})
+
+		Context("with an empty process type", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "--process", ""}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Value for flag 'process' must not be empty"))
+			})
+		})
+	})
+
+	Context("when --org and --space are set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--org", "org1", "--space", "space1"}
+		})
+
+		It("locates the app in that space", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Org).To(Equal("org1"))
+			Expect(opts.Space).To(Equal("space1"))
+			Expect(opts.ByGuid).To(BeFalse())
+		})
+	})
+
+	Context("when only --space is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--space", "space1"}
+		})
+
+		It("leaves the org to be found", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Org).To(BeEmpty())
+			Expect(opts.Space).To(Equal("space1"))
+		})
+	})
+
+	Context("when only --org is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--org", "org1"}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError("Flag 'org' requires flag 'space'"))
+		})
+	})
+
+	Context("when --space is empty", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--space", ""}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError("Value for flag 'space' must not be empty"))
+		})
+	})
+
+	Context("when --guid is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-guid", "--guid"}
+		})
+
+		It("treats the argument as the app GUID", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.AppName).To(Equal("app-guid"))
+			Expect(opts.ByGuid).To(BeTrue())
+		})
+
+		Context("with --space", func() {
+			BeforeEach(func() {
+				args = []string{"app-guid", "--guid", "--space", "space1"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Only one of --guid or --space may be provided"))
+			})
+		})
+	})
+
+	Context("when -c is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-c", "ps -ef"}
+		})
+
+		It("sets the remote command", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Command).To(Equal("ps -ef"))
+		})
+
+		Context("with an empty command", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "-c", ""}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Value for flag 'c' must not be empty"))
+			})
+		})
+
+		Context("with -N", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "-c", "ps -ef", "-N"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Only one of -N or -c may be provided"))
+			})
+		})
+	})
+
+	Context("when -A is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-A"}
+		})
+
+		It("enables agent forwarding", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.ForwardAgent).To(BeTrue())
+		})
+	})
+
+	Context("when agent forwarding is configured", func() {
+		BeforeEach(func() {
+			forwardAgent := true
+			opts.Config = &config.Config{Defaults: config.Defaults{ForwardAgent: &forwardAgent}}
+			args = []string{"app-name"}
+		})
+
+		It("enables agent forwarding", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.ForwardAgent).To(BeTrue())
+		})
+	})
+
+	Context("when --proxy is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "--proxy", "socks5://localhost:1080"}
+		})
+
+		It("sets the proxy", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Proxy).To(Equal("socks5://localhost:1080"))
+		})
+	})
+
+	Context("when a proxy is configured", func() {
+		BeforeEach(func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{Proxy: "http://proxy.example.com:3128"}}
+			args = []string{"app-name"}
+		})
+
+		It("uses the configured proxy", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.Proxy).To(Equal("http://proxy.example.com:3128"))
+		})
+	})
+
+	Context("when -J is set", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-J", "ops@bastion.example.com"}
+		})
+
+		It("sets the jump host", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.JumpHost).To(Equal(options.JumpHost{User: "ops", Address: "bastion.example.com:22"}))
+		})
+
+		Context("with a malformed jump host", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "-J", "bastion:ssh"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError(`Unable to parse jump host: "bastion:ssh"`))
+			})
+		})
+	})
+
+	Context("when a jump host is configured", func() {
+		BeforeEach(func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{JumpHost: "bastion.example.com:2200"}}
+			args = []string{"app-name"}
+		})
+
+		It("uses the configured jump host", func() {
+			Expect(parseError).ToNot(HaveOccurred())
+			Expect(opts.JumpHost).To(Equal(options.JumpHost{Address: "bastion.example.com:2200"}))
+		})
+	})
+
 	Context("when an -i flag is provided", func() {
 		BeforeEach(func() {
 			args = []string{"app-name"}
@@ -113,6 +398,70 @@ var _ = Describe("Options", func() {
 			It("populates the Instance field", func() {
 				Expect(parseError).NotTo(HaveOccurred())
 				Expect(opts.Instance).To(Equal(3))
+				Expect(opts.InstanceSelector).To(Equal(options.SelectIndex))
+			})
+		})
+
+		Context("with random", func() {
+			BeforeEach(func() {
+				args = append(args, "-i", "random")
+			})
+
+			It("selects a random instance", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.InstanceSelector).To(Equal(options.SelectRandom))
+			})
+		})
+
+		Context("with least-loaded", func() {
+			BeforeEach(func() {
+				args = append(args, "-i", "least-loaded")
+			})
+
+			It("selects the least loaded instance", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.InstanceSelector).To(Equal(options.SelectLeastLoaded))
+			})
+		})
+
+		Context("with all", func() {
+			BeforeEach(func() {
+				args = append(args, "-i", "all", "-c", "uptime")
+			})
+
+			It("selects every instance", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.InstanceSelector).To(Equal(options.SelectAll))
+			})
+
+			Context("without a command", func() {
+				BeforeEach(func() {
+					args = []string{"app-name", "-i", "all"}
+				})
+
+				It("returns an error", func() {
+					Expect(parseError).To(MatchError("Value all for flag 'i' requires -c"))
+				})
+			})
+
+			Context("with -L", func() {
+				BeforeEach(func() {
+					args = append(args, "-L", "9999:localhost:8080")
+				})
+
+				It("returns an error", func() {
+					Expect(parseError).To(MatchError("Only one of -L or -i all may be provided"))
+				})
+			})
+
+			Context("with -t", func() {
+				BeforeEach(func() {
+					args = append(args, "-t")
+				})
+
+				It("returns an error", func() {
+					Expect(parseError).To(MatchError("A pty cannot be requested with -i all"))
+				})
 			})
 		})
 
@@ -132,9 +481,417 @@ var _ = Describe("Options", func() {
 			})
 
 			It("returns an error", func() {
-				Expect(parseError).To(MatchError("Value for flag 'i' must be integer"))
+				Expect(parseError).To(MatchError("Value for flag 'i' must be an instance index, random, least-loaded, or all"))
+			})
+		})
+
+	})
+	Context("when -L flags are provided", func() {
+		BeforeEach(func() {
+			args = []string{"app-name", "-L", "9999:localhost:8080", "-L", "0.0.0.0:5432:db.internal:5432"}
+		})
+
+		It("populates the ForwardSpecs field", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.ForwardSpecs).To(Equal([]options.ForwardSpec{
+				{ListenAddress: "localhost:9999", ConnectAddress: "localhost:8080"},
+				{ListenAddress: "0.0.0.0:5432", ConnectAddress: "db.internal:5432"},
+			}))
+		})
+
+		Context("with an invalid forward spec", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "-L", "9999"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError(`Unable to parse local forwarding argument: "9999"`))
+			})
+		})
+	})
+
+	Describe("terminal requests", func() {
+		It("defaults to auto", func() {
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.TerminalRequest).To(Equal(options.RequestTTYAuto))
+		})
+
+		It("requests a tty with -t", func() {
+			Expect(opts.Parse([]string{"app-name", "-t"})).To(Succeed())
+			Expect(opts.TerminalRequest).To(Equal(options.RequestTTYYes))
+		})
+
+		It("forces a tty with -tt", func() {
+			Expect(opts.Parse([]string{"app-name", "-tt"})).To(Succeed())
+			Expect(opts.TerminalRequest).To(Equal(options.RequestTTYForce))
+		})
+
+		It("disables the tty with -T", func() {
+			Expect(opts.Parse([]string{"app-name", "-T"})).To(Succeed())
+			Expect(opts.TerminalRequest).To(Equal(options.RequestTTYNo))
+		})
+
+		It("rejects conflicting flags", func() {
+			Expect(opts.Parse([]string{"app-name", "-t", "-T"})).To(MatchError("Only one of -t, -tt, or -T may be provided"))
+		})
+	})
+
+	Context("when --connect-timeout is provided", func() {
+		It("accepts a number of seconds", func() {
+			Expect(opts.Parse([]string{"app-name", "--connect-timeout", "15"})).To(Succeed())
+			Expect(opts.ConnectTimeout).To(Equal(15 * time.Second))
+		})
+
+		It("accepts a duration", func() {
+			Expect(opts.Parse([]string{"app-name", "--connect-timeout", "1m30s"})).To(Succeed())
+			Expect(opts.ConnectTimeout).To(Equal(90 * time.Second))
+		})
+
+		It("rejects garbage", func() {
+			Expect(opts.Parse([]string{"app-name", "--connect-timeout", "soon"})).To(MatchError("Value for flag 'connect-timeout' must be a duration"))
+		})
+	})
+
+	Describe("SSH endpoint override", func() {
+		var savedEndpoint, savedFingerprint string
+
+		BeforeEach(func() {
+			savedEndpoint = os.Getenv("CF_SSH_ENDPOINT")
+			savedFingerprint = os.Getenv("CF_SSH_FINGERPRINT")
+			os.Setenv("CF_SSH_ENDPOINT", "")
+			os.Setenv("CF_SSH_FINGERPRINT", "")
+		})
+
+		AfterEach(func() {
+			os.Setenv("CF_SSH_ENDPOINT", savedEndpoint)
+			os.Setenv("CF_SSH_FINGERPRINT", savedFingerprint)
+		})
+
+		It("is not set by default", func() {
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.SSHEndpoint).To(BeEmpty())
+			Expect(opts.SSHFingerprint).To(BeEmpty())
+		})
+
+		It("accepts flags", func() {
+			Expect(opts.Parse([]string{"app-name", "--ssh-endpoint", "10.0.0.5:2222", "--ssh-fingerprint", "a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a"})).To(Succeed())
+			Expect(opts.SSHEndpoint).To(Equal("10.0.0.5:2222"))
+			Expect(opts.SSHFingerprint).To(Equal("a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a"))
+		})
+
+		It("reads the environment", func() {
+			os.Setenv("CF_SSH_ENDPOINT", "localhost:2222")
+			os.Setenv("CF_SSH_FINGERPRINT", "a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a")
+
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.SSHEndpoint).To(Equal("localhost:2222"))
+			Expect(opts.SSHFingerprint).To(Equal("a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a"))
+		})
+
+		It("prefers the environment over configuration and flags over both", func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{SSHEndpoint: "config.example.com:2222", SSHFingerprint: "config"}}
+			os.Setenv("CF_SSH_ENDPOINT", "env.example.com:2222")
+
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.SSHEndpoint).To(Equal("env.example.com:2222"))
+			Expect(opts.SSHFingerprint).To(Equal("config"))
+
+			Expect(opts.Parse([]string{"app-name", "--ssh-endpoint", "flag.example.com:2222"})).To(Succeed())
+			Expect(opts.SSHEndpoint).To(Equal("flag.example.com:2222"))
+		})
+
+		It("rejects endpoints without a port", func() {
+			Expect(opts.Parse([]string{"app-name", "--ssh-endpoint", "ssh.example.com"})).To(MatchError("Invalid SSH endpoint: ssh.example.com"))
+		})
+	})
+
+	Describe("connection establishment", func() {
+		It("times out and retries by default", func() {
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.ConnectTimeout).To(Equal(30 * time.Second))
+			Expect(opts.HandshakeTimeout).To(Equal(30 * time.Second))
+			Expect(opts.ConnectAttempts).To(Equal(3))
+			Expect(opts.Wait).To(BeFalse())
+		})
+
+		It("accepts flags", func() {
+			Expect(opts.Parse([]string{"app-name", "--handshake-timeout", "10", "--connect-attempts", "5", "--wait"})).To(Succeed())
+			Expect(opts.HandshakeTimeout).To(Equal(10 * time.Second))
+			Expect(opts.ConnectAttempts).To(Equal(5))
+			Expect(opts.Wait).To(BeTrue())
+		})
+
+		It("rejects invalid values", func() {
+			Expect(opts.Parse([]string{"app-name", "--handshake-timeout", "soon"})).To(MatchError("Value for flag 'handshake-timeout' must be a duration"))
+			Expect(opts.Parse([]string{"app-name", "--connect-attempts", "0"})).To(MatchError("Value for flag 'connect-attempts' must be positive"))
+		})
+
+		It("can be configured", func() {
+			attempts := 1
+			wait := true
+			opts.Config = &config.Config{Defaults: config.Defaults{HandshakeTimeout: "1m", ConnectAttempts: &attempts, Wait: &wait}}
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.HandshakeTimeout).To(Equal(time.Minute))
+			Expect(opts.ConnectAttempts).To(Equal(1))
+			Expect(opts.Wait).To(BeTrue())
+		})
+	})
+
+	Describe("keepalives", func() {
+		It("sends keepalives by default", func() {
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.KeepAliveInterval).To(Equal(options.DefaultKeepAliveInterval))
+			Expect(opts.KeepAliveCountMax).To(Equal(options.DefaultKeepAliveCountMax))
+			Expect(opts.IdleTimeout).To(BeZero())
+		})
+
+		It("accepts an interval, count, and idle timeout", func() {
+			Expect(opts.Parse([]string{"app-name", "--keepalive-interval", "10", "--keepalive-count", "5", "--idle-timeout", "1h"})).To(Succeed())
+			Expect(opts.KeepAliveInterval).To(Equal(10 * time.Second))
+			Expect(opts.KeepAliveCountMax).To(Equal(5))
+			Expect(opts.IdleTimeout).To(Equal(time.Hour))
+		})
+
+		It("disables keepalives with an interval of zero", func() {
+			Expect(opts.Parse([]string{"app-name", "--keepalive-interval", "0"})).To(Succeed())
+			Expect(opts.KeepAliveInterval).To(BeZero())
+		})
+
+		It("rejects a count that is not positive", func() {
+			Expect(opts.Parse([]string{"app-name", "--keepalive-count", "0"})).To(MatchError("Value for flag 'keepalive-count' must be positive"))
+		})
+
+		It("rejects an invalid idle timeout", func() {
+			Expect(opts.Parse([]string{"app-name", "--idle-timeout", "later"})).To(MatchError("Value for flag 'idle-timeout' must be a duration"))
+		})
+
+		It("uses configured values", func() {
+			count := 6
+			opts.Config = &config.Config{
+				Defaults: config.Defaults{
+					KeepAliveInterval: "15s",
+					KeepAliveCountMax: &count,
+					IdleTimeout:       "30m",
+				},
+			}
+
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.KeepAliveInterval).To(Equal(15 * time.Second))
+			Expect(opts.KeepAliveCountMax).To(Equal(6))
+			Expect(opts.IdleTimeout).To(Equal(30 * time.Minute))
+		})
+	})
+
+	Describe("escape character", func() {
+		It("defaults to a tilde", func() {
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.EscapeChar).To(Equal(byte('~')))
+		})
+
+		It("accepts a single character", func() {
+			Expect(opts.Parse([]string{"app-name", "--escape-char", "%"})).To(Succeed())
+			Expect(opts.EscapeChar).To(Equal(byte('%')))
+		})
+
+		It("accepts a control character", func() {
+			Expect(opts.Parse([]string{"app-name", "--escape-char", "^]"})).To(Succeed())
+			Expect(opts.EscapeChar).To(Equal(byte(0x1d)))
+		})
+
+		It("can be disabled", func() {
+			Expect(opts.Parse([]string{"app-name", "--escape-char", "none"})).To(Succeed())
+			Expect(opts.EscapeChar).To(BeZero())
+		})
+
+		It("rejects anything else", func() {
+			Expect(opts.Parse([]string{"app-name", "--escape-char", "tilde"})).To(MatchError("Invalid escape character: tilde"))
+		})
+
+		It("can be configured", func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{EscapeChar: "none"}}
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.EscapeChar).To(BeZero())
+		})
+	})
+
+	Describe("environment", func() {
+		It("accepts KEY=VALUE assignments", func() {
+			Expect(opts.Parse([]string{"app-name", "-e", "DEBUG=true", "-e", "OPTS=-a=b"})).To(Succeed())
+			Expect(opts.Env).To(Equal(map[string]string{"DEBUG": "true", "OPTS": "-a=b"}))
+		})
+
+		It("rejects assignments without a value", func() {
+			Expect(opts.Parse([]string{"app-name", "-e", "DEBUG"})).To(MatchError("Invalid environment variable: DEBUG. Expected KEY=VALUE"))
+		})
+
+		It("merges assignments over configured variables", func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{Env: map[string]string{"LANG": "C", "DEBUG": "false"}}}
+			Expect(opts.Parse([]string{"app-name", "-e", "DEBUG=true"})).To(Succeed())
+			Expect(opts.Env).To(Equal(map[string]string{"LANG": "C", "DEBUG": "true"}))
+		})
+
+		It("accepts send-env patterns", func() {
+			Expect(opts.Parse([]string{"app-name", "--send-env", "LC_*", "--send-env", "TZ"})).To(Succeed())
+			Expect(opts.SendEnv).To(Equal([]string{"LC_*", "TZ"}))
+		})
+
+		It("rejects malformed send-env patterns", func() {
+			Expect(opts.Parse([]string{"app-name", "--send-env", "LC_["})).To(MatchError("Invalid send-env pattern: LC_["))
+		})
+
+		It("can configure send-env patterns", func() {
+			opts.Config = &config.Config{Defaults: config.Defaults{SendEnv: []string{"LANG"}}}
+			Expect(opts.Parse([]string{"app-name"})).To(Succeed())
+			Expect(opts.SendEnv).To(Equal([]string{"LANG"}))
+		})
+
+		Describe("Environment", func() {
+			It("includes matching local variables and explicit assignments", func() {
+				opts.SendEnv = []string{"LC_*", "TZ"}
+				opts.Env = map[string]string{"DEBUG": "true", "TZ": "UTC"}
+
+				env := opts.Environment([]string{"LC_ALL=en_US.UTF-8", "TZ=America/New_York", "HOME=/home/me", "LC_CTYPE=", "=C:=C:\\"})
+				Expect(env).To(Equal(map[string]string{
+					"LC_ALL":   "en_US.UTF-8",
+					"LC_CTYPE": "",
+					"TZ":       "UTC",
+					"DEBUG":    "true",
+				}))
+			})
+
+			It("is empty when nothing is requested", func() {
+				Expect(opts.Environment([]string{"HOME=/home/me"})).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when a configuration is provided", func() {
+		BeforeEach(func() {
+			instance := 4
+			opts.Target = target.Target{API: "https://api.example.com", Org: "org1", Space: "space1"}
+			reconnect := true
+			opts.Config = &config.Config{
+				Defaults: config.Defaults{
+					Reconnect:      &reconnect,
+					ConnectTimeout: "20s",
+					Env:            map[string]string{"LANG": "C"},
+				},
+				Apps: []config.AppConfig{{
+					Org: "org1",
+					App: "app-name",
+					Defaults: config.Defaults{
+						Instance: &instance,
+						Forwards: []string{"8080:localhost:8080"},
+						Pty:      "force",
+					},
+				}},
+			}
+		})
+
+		Context("and no flags are set", func() {
+			BeforeEach(func() {
+				args = []string{"app-name"}
+			})
+
+			It("uses the configured defaults", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Instance).To(Equal(4))
+				Expect(opts.InstanceSelector).To(Equal(options.SelectIndex))
+				Expect(opts.ForwardSpecs).To(Equal([]options.ForwardSpec{
+					{ListenAddress: "localhost:8080", ConnectAddress: "localhost:8080"},
+				}))
+				Expect(opts.TerminalRequest).To(Equal(options.RequestTTYForce))
+				Expect(opts.ConnectTimeout).To(Equal(20 * time.Second))
+				Expect(opts.Reconnect).To(BeTrue())
+				Expect(opts.Env).To(Equal(map[string]string{"LANG": "C"}))
+			})
+		})
+
+		Context("and flags are set", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "-i", "0", "-L", "9000:localhost:9000", "-T", "--connect-timeout", "5", "--reconnect=false"}
+			})
+
+			It("prefers the flags", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Instance).To(Equal(0))
+				Expect(opts.ForwardSpecs).To(Equal([]options.ForwardSpec{
+					{ListenAddress: "localhost:9000", ConnectAddress: "localhost:9000"},
+				}))
+				Expect(opts.TerminalRequest).To(Equal(options.RequestTTYNo))
+				Expect(opts.ConnectTimeout).To(Equal(5 * time.Second))
+				Expect(opts.Reconnect).To(BeFalse())
+			})
+		})
+
+		Context("and the app is in another org", func() {
+			BeforeEach(func() {
+				args = []string{"app-name", "--org", "org2", "--space", "space1"}
+			})
+
+			It("matches the configuration against that org", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Instance).To(Equal(0))
+				Expect(opts.ForwardSpecs).To(BeEmpty())
+				Expect(opts.ConnectTimeout).To(Equal(20 * time.Second))
+			})
+		})
+
+		Context("and the app is not configured", func() {
+			BeforeEach(func() {
+				args = []string{"other-app"}
+			})
+
+			It("only uses the global defaults", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Instance).To(Equal(0))
+				Expect(opts.ForwardSpecs).To(BeEmpty())
+				Expect(opts.ConnectTimeout).To(Equal(20 * time.Second))
+			})
+		})
+
+		Context("and the configured pty mode is invalid", func() {
+			BeforeEach(func() {
+				opts.Config.Defaults.Pty = "sometimes"
+				args = []string{"other-app"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Invalid configured pty mode: sometimes"))
 			})
 		})
+	})
+})
+
+var _ = Describe("ParseInfo", func() {
+	var opts *options.Options
+
+	BeforeEach(func() {
+		opts = &options.Options{}
+	})
+
+	It("accepts the flags that locate the instance and its endpoint", func() {
+		err := opts.ParseInfo([]string{"app-name", "--space", "space1", "--process", "worker", "-i", "1", "--ssh-endpoint", "ssh.example.com:2222", "--output", "json"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Instance).To(Equal(1))
+		Expect(opts.Output).To(Equal(options.OutputJSON))
+	})
+
+	It("rejects flags that only matter when connecting", func() {
+		err := opts.ParseInfo([]string{"app-name", "-L", "9000:localhost:9000", "--output", "json"})
+		Expect(err).To(MatchError("Flag 'L' is not supported by cf ssh-info"))
+	})
+})
+
+var _ = Describe("RequestedOutput", func() {
+	It("finds the format without parsing the other arguments", func() {
+		Expect(options.RequestedOutput([]string{"app-name", "--bogus", "--output", "json"})).To(Equal(options.OutputJSON))
+		Expect(options.RequestedOutput([]string{"app-name", "--output=json"})).To(Equal(options.OutputJSON))
+	})
 
+	It("defaults to text", func() {
+		Expect(options.RequestedOutput([]string{"app-name"})).To(Equal(options.OutputText))
+		Expect(options.RequestedOutput([]string{"app-name", "--output"})).To(Equal(options.OutputText))
+		Expect(options.RequestedOutput([]string{"app-name", "--output", "yaml"})).To(Equal(options.OutputText))
 	})
 })
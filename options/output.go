@@ -0,0 +1,43 @@
+package options
+
+import (
+	"errors"
+	"strings"
+)
+
+// OutputFormat selects how informational commands print their results.
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+)
+
+func ParseOutputFormat(format string) (OutputFormat, error) {
+	switch OutputFormat(format) {
+	case OutputText, OutputJSON:
+		return OutputFormat(format), nil
+	default:
+		return OutputText, errors.New("Value for flag 'output' must be text or json")
+	}
+}
+
+// RequestedOutput returns the format named with --output in args without
+// parsing the rest, so arguments that fail to parse can still be reported in
+// the format asked for.
+func RequestedOutput(args []string) OutputFormat {
+	for i, arg := range args {
+		value := ""
+		switch {
+		case (arg == "--output" || arg == "-output") && i+1 < len(args):
+			value = args[i+1]
+		case strings.HasPrefix(arg, "--output="):
+			value = strings.TrimPrefix(arg, "--output=")
+		}
+
+		if format, err := ParseOutputFormat(value); err == nil {
+			return format
+		}
+	}
+	return OutputText
+}
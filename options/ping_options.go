@@ -0,0 +1,94 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/flags/flag"
+)
+
+const (
+	DefaultPingCount    = 10
+	DefaultPingInterval = time.Second
+)
+
+// PingOptions holds the arguments to the ssh-ping command: the ssh flags
+// that select and reach an instance, and how to sample it.
+type PingOptions struct {
+	Options
+
+	Count    int
+	Interval time.Duration
+
+	// ThroughputBytes is the amount of data streamed to the instance in
+	// each sample. No data is sent when it is zero.
+	ThroughputBytes int64
+}
+
+func (o *PingOptions) Parse(args []string) error {
+	flagSet := setupFlags()
+	flagSet["count"] = &cliFlags.IntFlag{Name: "count", Usage: ""}
+	flagSet["interval"] = &cliFlags.StringFlag{Name: "interval", Usage: ""}
+	flagSet["throughput"] = &cliFlags.StringFlag{Name: "throughput", Usage: ""}
+
+	fc, err := o.Options.parse(args, flagSet)
+	if err != nil {
+		return err
+	}
+
+	o.Count = DefaultPingCount
+	o.Interval = DefaultPingInterval
+
+	if fc.IsSet("count") {
+		o.Count = fc.Int("count")
+		if o.Count < 1 {
+			return errors.New("Value for flag 'count' must be positive")
+		}
+	}
+
+	if fc.IsSet("interval") {
+		o.Interval, err = parseTimeout(fc.String("interval"))
+		if err != nil {
+			return errors.New("Value for flag 'interval' must be a duration")
+		}
+	}
+
+	if fc.IsSet("throughput") {
+		o.ThroughputBytes, err = ParseSize(fc.String("throughput"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseSize parses a byte count with an optional K, M, or G suffix, each a
+// power of 1024.
+func ParseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	number := strings.ToUpper(strings.TrimSpace(value))
+	number = strings.TrimSuffix(number, "B")
+
+	switch {
+	case strings.HasSuffix(number, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(number, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(number, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		number = number[:len(number)-1]
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("Invalid size: %s", value)
+	}
+
+	return size * multiplier, nil
+}
@@ -0,0 +1,103 @@
+package options_test
+
+import (
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PingOptions", func() {
+	var (
+		opts       *options.PingOptions
+		args       []string
+		parseError error
+	)
+
+	BeforeEach(func() {
+		opts = &options.PingOptions{}
+		args = []string{"app1"}
+	})
+
+	JustBeforeEach(func() {
+		parseError = opts.Parse(args)
+	})
+
+	It("uses the defaults", func() {
+		Expect(parseError).NotTo(HaveOccurred())
+		Expect(opts.AppName).To(Equal("app1"))
+		Expect(opts.Count).To(Equal(options.DefaultPingCount))
+		Expect(opts.Interval).To(Equal(options.DefaultPingInterval))
+		Expect(opts.ThroughputBytes).To(BeZero())
+	})
+
+	Context("with sampling flags", func() {
+		BeforeEach(func() {
+			args = []string{"app1", "-i", "2", "--count", "5", "--interval", "250ms", "--throughput", "4M"}
+		})
+
+		It("parses them alongside the ssh flags", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Instance).To(Equal(2))
+			Expect(opts.Count).To(Equal(5))
+			Expect(opts.Interval).To(Equal(250 * time.Millisecond))
+			Expect(opts.ThroughputBytes).To(Equal(int64(4 << 20)))
+		})
+	})
+
+	Context("with a count that is not positive", func() {
+		BeforeEach(func() {
+			args = []string{"app1", "--count", "0"}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError("Value for flag 'count' must be positive"))
+		})
+	})
+
+	Context("with an invalid interval", func() {
+		BeforeEach(func() {
+			args = []string{"app1", "--interval", "soon"}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError("Value for flag 'interval' must be a duration"))
+		})
+	})
+
+	Context("without an app name", func() {
+		BeforeEach(func() {
+			args = []string{"--count", "3"}
+		})
+
+		It("returns a UsageError", func() {
+			Expect(parseError).To(Equal(options.UsageError))
+		})
+	})
+
+	Describe("ParseSize", func() {
+		It("parses sizes with suffixes", func() {
+			for value, expected := range map[string]int64{
+				"512":  512,
+				"64K":  64 << 10,
+				"10m":  10 << 20,
+				"1G":   1 << 30,
+				"2MB":  2 << 20,
+				" 3k ": 3 << 10,
+			} {
+				size, err := options.ParseSize(value)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(size).To(Equal(expected), value)
+			}
+		})
+
+		It("rejects invalid sizes", func() {
+			for _, value := range []string{"", "M", "ten", "-1", "1T"} {
+				_, err := options.ParseSize(value)
+				Expect(err).To(MatchError("Invalid size: "+value), value)
+			}
+		})
+	})
+})
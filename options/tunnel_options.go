@@ -0,0 +1,190 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudfoundry/cli/flags"
+	"github.com/cloudfoundry/cli/flags/flag"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+	"github.com/sykesm/cf-ssh-plugin/tunnel"
+)
+
+const (
+	TunnelSave   = "save"
+	TunnelUp     = "up"
+	TunnelDelete = "delete"
+	TunnelStart  = "start"
+	TunnelList   = "list"
+	TunnelStop   = "stop"
+)
+
+// profileFlags are the ssh flags save accepts: those a tunnel profile
+// records, and verbosity. Any other flag is rejected rather than dropped.
+var profileFlags = map[string]bool{
+	"i":       true,
+	"org":     true,
+	"space":   true,
+	"guid":    true,
+	"process": true,
+	"L":       true,
+	"v":       true,
+	"vv":      true,
+}
+
+// TunnelOptions holds the arguments to the ssh-tunnel command. Saving a
+// profile reuses the ssh flag parser so forwards are validated the same way;
+// bringing one up converts the stored profile back into Options.
+type TunnelOptions struct {
+	Action  string
+	Name    string
+	Options Options
+
+	// Output is the format of the list action.
+	Output OutputFormat
+
+	// Config holds the saved profiles; it is required for the up and start
+	// actions, which also apply its defaults beneath the profile.
+	Config *config.Config
+
+	// Target keys the per-app defaults; it is only needed for up.
+	Target target.Target
+}
+
+func (o *TunnelOptions) Parse(args []string) error {
+	if len(args) == 0 {
+		return UsageError
+	}
+
+	o.Action = args[0]
+
+	if o.Action == TunnelList {
+		return o.parseList(args[1:])
+	}
+
+	if len(args) < 2 {
+		return UsageError
+	}
+	o.Name = args[1]
+
+	err := tunnel.ValidateName(o.Name)
+	if err != nil {
+		return err
+	}
+
+	switch o.Action {
+	case TunnelSave:
+		flagSet := setupFlags()
+		fc, err := o.Options.parse(args[2:], flagSet)
+		if err != nil {
+			return err
+		}
+
+		if name := unsupportedFlag(fc, flagSet, profileFlags); name != "" {
+			return fmt.Errorf("Flag '%s' is unsupported in a tunnel profile", name)
+		}
+
+		if len(o.Options.ForwardSpecs) == 0 {
+			return errors.New("A tunnel requires at least one -L forward")
+		}
+		if o.Options.InstanceSelector == SelectRandom || o.Options.InstanceSelector == SelectLeastLoaded {
+			return errors.New("A tunnel requires an instance index")
+		}
+	case TunnelUp, TunnelStart:
+		if len(args) != 2 {
+			return UsageError
+		}
+		return o.loadProfile()
+	case TunnelDelete, TunnelStop:
+		if len(args) != 2 {
+			return UsageError
+		}
+	default:
+		return UsageError
+	}
+
+	return nil
+}
+
+func (o *TunnelOptions) parseList(args []string) error {
+	fc := flags.NewFlagContext(map[string]flags.FlagSet{
+		"output": &cliFlags.StringFlag{Name: "output", Usage: ""},
+	})
+	err := fc.Parse(args...)
+	if err != nil {
+		return err
+	}
+
+	if len(fc.Args()) != 0 {
+		return UsageError
+	}
+
+	o.Output = OutputText
+	if fc.IsSet("output") {
+		o.Output, err = ParseOutputFormat(fc.String("output"))
+	}
+	return err
+}
+
+// Profile returns the tunnel described by the parsed save arguments.
+func (o *TunnelOptions) Profile() config.Tunnel {
+	forwards := []string{}
+	for _, spec := range o.Options.ForwardSpecs {
+		forwards = append(forwards, spec.String())
+	}
+
+	return config.Tunnel{
+		App:      o.Options.AppName,
+		Org:      o.Options.Org,
+		Space:    o.Options.Space,
+		Guid:     o.Options.ByGuid,
+		Process:  o.Options.Process,
+		Instance: o.Options.Instance,
+		Forwards: forwards,
+	}
+}
+
+func (o *TunnelOptions) loadProfile() error {
+	if o.Config == nil {
+		return fmt.Errorf("Tunnel %s not found", o.Name)
+	}
+
+	profile, ok := o.Config.Tunnels[o.Name]
+	if !ok {
+		return fmt.Errorf("Tunnel %s not found", o.Name)
+	}
+
+	forwardSpecs, err := parseForwardSpecs(profile.Forwards)
+	if err != nil {
+		return fmt.Errorf("Invalid forward in tunnel %s: %s", o.Name, err)
+	}
+
+	// The profile is applied over the same defaults as cf ssh, so a tunnel
+	// goes through the configured proxy or jump host too.
+	o.Options = Options{
+		AppName: profile.App,
+		Org:     profile.Org,
+		Space:   profile.Space,
+		ByGuid:  profile.Guid,
+		Process: profile.Process,
+		Config:  o.Config,
+		Target:  o.Target,
+	}
+	o.Options.setBuiltinDefaults()
+
+	err = o.Options.applyConfigDefaults()
+	if err != nil {
+		return err
+	}
+	o.Options.applyEnvironment()
+
+	o.Options.Instance = profile.Instance
+	o.Options.InstanceSelector = SelectIndex
+	o.Options.ForwardSpecs = forwardSpecs
+	o.Options.TerminalRequest = RequestTTYNo
+	o.Options.SkipRemoteExecution = true
+	o.Options.Reconnect = true
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package options_test
+
+import (
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
+	"github.com/sykesm/cf-ssh-plugin/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TunnelOptions", func() {
+	var (
+		opts       *options.TunnelOptions
+		args       []string
+		parseError error
+	)
+
+	BeforeEach(func() {
+		opts = &options.TunnelOptions{}
+		args = []string{}
+	})
+
+	JustBeforeEach(func() {
+		parseError = opts.Parse(args)
+	})
+
+	Context("when no arguments are provided", func() {
+		It("returns a UsageError", func() {
+			Expect(parseError).To(Equal(options.UsageError))
+		})
+	})
+
+	Context("when the action is unknown", func() {
+		BeforeEach(func() {
+			args = []string{"dig", "db-debug"}
+		})
+
+		It("returns a UsageError", func() {
+			Expect(parseError).To(Equal(options.UsageError))
+		})
+	})
+
+	Describe("save", func() {
+		BeforeEach(func() {
+			args = []string{"save", "db-debug", "app1", "--org", "org1", "--space", "space1", "--process", "worker", "-i", "2", "-L", "5432:db:5432", "-L", "9000:localhost:9000"}
+		})
+
+		It("builds a tunnel profile from the ssh flags", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Action).To(Equal(options.TunnelSave))
+			Expect(opts.Name).To(Equal("db-debug"))
+			Expect(opts.Profile()).To(Equal(config.Tunnel{
+				App:      "app1",
+				Org:      "org1",
+				Space:    "space1",
+				Process:  "worker",
+				Instance: 2,
+				Forwards: []string{"localhost:5432:db:5432", "localhost:9000:localhost:9000"},
+			}))
+		})
+
+		Context("without any forwards", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug", "app1"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("A tunnel requires at least one -L forward"))
+			})
+		})
+
+		Context("with an instance selector", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug", "app1", "-i", "random", "-L", "5432:db:5432"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("A tunnel requires an instance index"))
+			})
+		})
+
+		Context("with a flag the profile cannot record", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug", "app1", "-L", "5432:db:5432", "-J", "bastion.example.com"}
+			})
+
+			It("rejects it instead of dropping it", func() {
+				Expect(parseError).To(MatchError("Flag 'J' is unsupported in a tunnel profile"))
+			})
+		})
+
+		Context("with -v", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug", "app1", "-L", "5432:db:5432", "-v"}
+			})
+
+			It("accepts it", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("with an invalid forward", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug", "app1", "-L", "5432:db"}
+			})
+
+			It("returns the forward spec error", func() {
+				Expect(parseError).To(MatchError(`Unable to parse local forwarding argument: "5432:db"`))
+			})
+		})
+
+		Context("without an app name", func() {
+			BeforeEach(func() {
+				args = []string{"save", "db-debug"}
+			})
+
+			It("returns a UsageError", func() {
+				Expect(parseError).To(Equal(options.UsageError))
+			})
+		})
+	})
+
+	Describe("up", func() {
+		BeforeEach(func() {
+			args = []string{"up", "db-debug"}
+			opts.Config = &config.Config{
+				Tunnels: map[string]config.Tunnel{
+					"db-debug": {
+						App:      "app1",
+						Space:    "space1",
+						Process:  "worker",
+						Instance: 1,
+						Forwards: []string{"localhost:5432:db:5432"},
+					},
+				},
+			}
+		})
+
+		It("converts the saved profile into forwarding-only options", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Options.AppName).To(Equal("app1"))
+			Expect(opts.Options.Space).To(Equal("space1"))
+			Expect(opts.Options.Process).To(Equal("worker"))
+			Expect(opts.Options.Instance).To(Equal(1))
+			Expect(opts.Options.ForwardSpecs).To(Equal([]options.ForwardSpec{
+				{ListenAddress: "localhost:5432", ConnectAddress: "db:5432"},
+			}))
+			Expect(opts.Options.SkipRemoteExecution).To(BeTrue())
+			Expect(opts.Options.Reconnect).To(BeTrue())
+			Expect(opts.Options.TerminalRequest).To(Equal(options.RequestTTYNo))
+			Expect(opts.Options.ConnectAttempts).To(Equal(options.DefaultConnectAttempts))
+		})
+
+		Context("when the configuration has defaults", func() {
+			BeforeEach(func() {
+				instance := 3
+				opts.Config.Defaults = config.Defaults{
+					Instance:         &instance,
+					Forwards:         []string{"8080:localhost:8080"},
+					Pty:              "force",
+					Proxy:            "socks5://proxy.example.com:1080",
+					JumpHost:         "bastion.example.com",
+					HandshakeTimeout: "10s",
+				}
+				opts.Config.Apps = []config.AppConfig{
+					{App: "app1", Org: "org1", Space: "space1", Defaults: config.Defaults{SSHEndpoint: "ssh.example.com:2222"}},
+				}
+				opts.Target = target.Target{Org: "org1", Space: "other-space"}
+			})
+
+			It("applies them beneath the profile", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Options.Proxy).To(Equal("socks5://proxy.example.com:1080"))
+				Expect(opts.Options.JumpHost.String()).To(Equal("bastion.example.com:22"))
+				Expect(opts.Options.HandshakeTimeout).To(Equal(10 * time.Second))
+				Expect(opts.Options.SSHEndpoint).To(Equal("ssh.example.com:2222"))
+			})
+
+			It("keeps the profile's instance, forwards, and forwarding-only mode", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Options.Instance).To(Equal(1))
+				Expect(opts.Options.InstanceSelector).To(Equal(options.SelectIndex))
+				Expect(opts.Options.ForwardSpecs).To(Equal([]options.ForwardSpec{
+					{ListenAddress: "localhost:5432", ConnectAddress: "db:5432"},
+				}))
+				Expect(opts.Options.TerminalRequest).To(Equal(options.RequestTTYNo))
+				Expect(opts.Options.SkipRemoteExecution).To(BeTrue())
+			})
+		})
+
+		Context("when the tunnel does not exist", func() {
+			BeforeEach(func() {
+				args = []string{"up", "web-debug"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Tunnel web-debug not found"))
+			})
+		})
+
+		Context("when the saved forward is invalid", func() {
+			BeforeEach(func() {
+				opts.Config.Tunnels["db-debug"] = config.Tunnel{App: "app1", Forwards: []string{"garbage"}}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError(`Invalid forward in tunnel db-debug: Unable to parse local forwarding argument: "garbage"`))
+			})
+		})
+	})
+
+	Describe("start", func() {
+		BeforeEach(func() {
+			args = []string{"start", "db-debug"}
+			opts.Config = &config.Config{
+				Tunnels: map[string]config.Tunnel{
+					"db-debug": {App: "app1", Forwards: []string{"localhost:5432:db:5432"}},
+				},
+			}
+		})
+
+		It("loads the saved profile", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Action).To(Equal(options.TunnelStart))
+			Expect(opts.Options.AppName).To(Equal("app1"))
+			Expect(opts.Options.SkipRemoteExecution).To(BeTrue())
+		})
+
+		Context("when the tunnel does not exist", func() {
+			BeforeEach(func() {
+				opts.Config.Tunnels = nil
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Tunnel db-debug not found"))
+			})
+		})
+	})
+
+	Describe("list", func() {
+		BeforeEach(func() {
+			args = []string{"list"}
+		})
+
+		It("does not require a name", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Action).To(Equal(options.TunnelList))
+			Expect(opts.Output).To(Equal(options.OutputText))
+		})
+
+		Context("with --output json", func() {
+			BeforeEach(func() {
+				args = []string{"list", "--output", "json"}
+			})
+
+			It("selects json output", func() {
+				Expect(parseError).NotTo(HaveOccurred())
+				Expect(opts.Output).To(Equal(options.OutputJSON))
+			})
+		})
+
+		Context("with an unknown output format", func() {
+			BeforeEach(func() {
+				args = []string{"list", "--output", "xml"}
+			})
+
+			It("returns an error", func() {
+				Expect(parseError).To(MatchError("Value for flag 'output' must be text or json"))
+			})
+		})
+
+		Context("with extra arguments", func() {
+			BeforeEach(func() {
+				args = []string{"list", "db-debug"}
+			})
+
+			It("returns a UsageError", func() {
+				Expect(parseError).To(Equal(options.UsageError))
+			})
+		})
+	})
+
+	Describe("stop", func() {
+		BeforeEach(func() {
+			args = []string{"stop", "db-debug"}
+		})
+
+		It("parses the tunnel name", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Action).To(Equal(options.TunnelStop))
+			Expect(opts.Name).To(Equal("db-debug"))
+		})
+	})
+
+	Context("when the name is not a plain file name", func() {
+		BeforeEach(func() {
+			args = []string{"stop", "../db-debug"}
+		})
+
+		It("returns an error", func() {
+			Expect(parseError).To(MatchError(`Invalid tunnel name "../db-debug": use letters, digits, '_', '.', and '-'`))
+		})
+	})
+
+	Describe("delete", func() {
+		BeforeEach(func() {
+			args = []string{"delete", "db-debug"}
+		})
+
+		It("parses the tunnel name", func() {
+			Expect(parseError).NotTo(HaveOccurred())
+			Expect(opts.Action).To(Equal(options.TunnelDelete))
+			Expect(opts.Name).To(Equal("db-debug"))
+		})
+	})
+})
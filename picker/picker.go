@@ -0,0 +1,153 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/keyboard"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+)
+
+var ErrNoRunningInstances = errors.New("The app has no running instances")
+
+// Random returns the index of a running instance chosen with intn, which
+// behaves like rand.Intn.
+func Random(all []instances.Instance, intn func(int) int) (int, error) {
+	running := filterRunning(all)
+	if len(running) == 0 {
+		return 0, ErrNoRunningInstances
+	}
+
+	return running[intn(len(running))].Index, nil
+}
+
+// LeastLoaded returns the index of the running instance using the least CPU,
+// breaking ties by the share of its memory quota in use.
+func LeastLoaded(all []instances.Instance) (int, error) {
+	running := filterRunning(all)
+	if len(running) == 0 {
+		return 0, ErrNoRunningInstances
+	}
+
+	best := running[0]
+	for _, instance := range running[1:] {
+		if instance.CPU < best.CPU || instance.CPU == best.CPU && memoryShare(instance) < memoryShare(best) {
+			best = instance
+		}
+	}
+	return best.Index, nil
+}
+
+// Running returns the indexes of the running instances in order.
+func Running(all []instances.Instance) ([]int, error) {
+	running := filterRunning(all)
+	if len(running) == 0 {
+		return nil, ErrNoRunningInstances
+	}
+
+	indexes := []int{}
+	for _, instance := range running {
+		indexes = append(indexes, instance.Index)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// Print writes a table of the instances and their resource usage.
+func Print(w io.Writer, all []instances.Instance) {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "index\tstate\tuptime\tcpu\tmemory\tdisk")
+	for _, instance := range all {
+		if instance.State != "RUNNING" {
+			fmt.Fprintf(table, "%d\t%s\t-\t-\t-\t-\n", instance.Index, instance.State)
+			continue
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%s\t%.1f%%\t%s of %s\t%s of %s\n",
+			instance.Index,
+			instance.State,
+			instance.Uptime.Round(time.Second),
+			instance.CPU*100,
+			formatBytes(instance.Memory), formatBytes(instance.MemoryQuota),
+			formatBytes(instance.Disk), formatBytes(instance.DiskQuota),
+		)
+	}
+	table.Flush()
+}
+
+// Prompt lists the instances and asks for one until a running instance is
+// chosen. An empty answer chooses the first running instance.
+func Prompt(r io.Reader, w io.Writer, all []instances.Instance) (int, error) {
+	running := filterRunning(all)
+	if len(running) == 0 {
+		return 0, ErrNoRunningInstances
+	}
+
+	Print(w, all)
+	fmt.Fprintln(w)
+
+	for {
+		fmt.Fprintf(w, "Instance to connect to [%d]: ", running[0].Index)
+
+		answer, err := keyboard.ReadLine(r)
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			if err != nil {
+				fmt.Fprintln(w)
+				return 0, errors.New("No instance selected")
+			}
+			return running[0].Index, nil
+		}
+
+		index, convErr := strconv.Atoi(answer)
+		if convErr == nil {
+			for _, instance := range running {
+				if instance.Index == index {
+					return index, nil
+				}
+			}
+		}
+
+		if err != nil {
+			fmt.Fprintln(w)
+			return 0, errors.New("No instance selected")
+		}
+		fmt.Fprintf(w, "%s is not a running instance\n", answer)
+	}
+}
+
+func filterRunning(all []instances.Instance) []instances.Instance {
+	running := []instances.Instance{}
+	for _, instance := range all {
+		if instance.State == "RUNNING" {
+			running = append(running, instance)
+		}
+	}
+	return running
+}
+
+func memoryShare(instance instances.Instance) float64 {
+	if instance.MemoryQuota == 0 {
+		return 0
+	}
+	return float64(instance.Memory) / float64(instance.MemoryQuota)
+}
+
+func formatBytes(bytes uint64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", bytes)
+	}
+}
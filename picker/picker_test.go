@@ -0,0 +1,130 @@
+package picker_test
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+	"github.com/sykesm/cf-ssh-plugin/picker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Picker", func() {
+	var all []instances.Instance
+
+	BeforeEach(func() {
+		all = []instances.Instance{
+			{Index: 0, State: "RUNNING", Uptime: 7384 * time.Second, CPU: 0.25, Memory: 128 << 20, MemoryQuota: 256 << 20, Disk: 80 << 20, DiskQuota: 1 << 30},
+			{Index: 1, State: "CRASHED"},
+			{Index: 2, State: "RUNNING", Uptime: 60 * time.Second, CPU: 0.05, Memory: 200 << 20, MemoryQuota: 256 << 20, Disk: 80 << 20, DiskQuota: 1 << 30},
+			{Index: 3, State: "RUNNING", Uptime: 60 * time.Second, CPU: 0.05, Memory: 100 << 20, MemoryQuota: 256 << 20, Disk: 80 << 20, DiskQuota: 1 << 30},
+		}
+	})
+
+	Describe("Random", func() {
+		It("chooses among the running instances", func() {
+			var n int
+			index, err := picker.Random(all, func(max int) int {
+				n = max
+				return 1
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(3))
+			Expect(index).To(Equal(2))
+		})
+
+		It("fails without a running instance", func() {
+			_, err := picker.Random(all[1:2], func(int) int { return 0 })
+			Expect(err).To(Equal(picker.ErrNoRunningInstances))
+		})
+	})
+
+	Describe("LeastLoaded", func() {
+		It("chooses the running instance using the least CPU, then memory", func() {
+			index, err := picker.LeastLoaded(all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index).To(Equal(3))
+		})
+
+		It("fails without a running instance", func() {
+			_, err := picker.LeastLoaded(nil)
+			Expect(err).To(Equal(picker.ErrNoRunningInstances))
+		})
+	})
+
+	Describe("Running", func() {
+		It("lists the running instances in order", func() {
+			indexes, err := picker.Running([]instances.Instance{all[3], all[1], all[0]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(indexes).To(Equal([]int{0, 3}))
+		})
+
+		It("fails without a running instance", func() {
+			_, err := picker.Running(all[1:2])
+			Expect(err).To(Equal(picker.ErrNoRunningInstances))
+		})
+	})
+
+	Describe("Print", func() {
+		It("writes a row per instance", func() {
+			out := &bytes.Buffer{}
+			picker.Print(out, all[:2])
+			Expect(out.String()).To(Equal(
+				"index  state    uptime  cpu    memory            disk\n" +
+					"0      RUNNING  2h3m4s  25.0%  128.0M of 256.0M  80.0M of 1.0G\n" +
+					"1      CRASHED  -       -      -                 -\n",
+			))
+		})
+	})
+
+	Describe("Prompt", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+		})
+
+		It("returns the chosen instance", func() {
+			index, err := picker.Prompt(strings.NewReader("2\n"), out, all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index).To(Equal(2))
+			Expect(out.String()).To(ContainSubstring("CRASHED"))
+			Expect(out.String()).To(HaveSuffix("Instance to connect to [0]: "))
+		})
+
+		It("defaults to the first running instance", func() {
+			index, err := picker.Prompt(strings.NewReader("\n"), out, all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index).To(Equal(0))
+		})
+
+		It("asks again until a running instance is chosen", func() {
+			index, err := picker.Prompt(strings.NewReader("1\nfour\n3\n"), out, all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index).To(Equal(3))
+			Expect(out.String()).To(ContainSubstring("1 is not a running instance\n"))
+			Expect(out.String()).To(ContainSubstring("four is not a running instance\n"))
+		})
+
+		It("does not read past the answer", func() {
+			in := strings.NewReader("2\nls\n")
+			_, err := picker.Prompt(in, out, all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(in.Len()).To(Equal(3))
+		})
+
+		It("fails when the input ends without an answer", func() {
+			_, err := picker.Prompt(strings.NewReader(""), out, all)
+			Expect(err).To(MatchError("No instance selected"))
+		})
+
+		It("accepts a final answer without a newline", func() {
+			index, err := picker.Prompt(strings.NewReader("3"), out, all)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(index).To(Equal(3))
+		})
+	})
+})
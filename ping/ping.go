@@ -0,0 +1,146 @@
+package ping
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Sample is one session opened to an instance. Each phase is timed from
+// the end of the one before it, so they add up to the time taken to open a
+// session.
+type Sample struct {
+	Seq         int
+	Connect     time.Duration
+	Handshake   time.Duration
+	Auth        time.Duration
+	ChannelOpen time.Duration
+
+	// BytesPerSecond is the rate data was streamed to the instance, when
+	// throughput was measured.
+	BytesPerSecond float64
+
+	Err error
+}
+
+func (s Sample) Total() time.Duration {
+	return s.Connect + s.Handshake + s.Auth + s.ChannelOpen
+}
+
+type Stats struct {
+	Min float64
+	P50 float64
+	P90 float64
+	P99 float64
+	Max float64
+}
+
+// Percentile returns the nearest-rank percentile of values. It returns 0 for
+// an empty slice.
+func Percentile(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func Summarize(values []float64) Stats {
+	return Stats{
+		Min: Percentile(values, 0),
+		P50: Percentile(values, 50),
+		P90: Percentile(values, 90),
+		P99: Percentile(values, 99),
+		Max: Percentile(values, 100),
+	}
+}
+
+// PrintSample writes one line for a sample, in the spirit of ping.
+func PrintSample(w io.Writer, sample Sample) {
+	if sample.Err != nil {
+		fmt.Fprintf(w, "seq=%d error: %s\n", sample.Seq, sample.Err)
+		return
+	}
+
+	fmt.Fprintf(w, "seq=%d connect=%s handshake=%s auth=%s channel=%s total=%s",
+		sample.Seq,
+		milliseconds(sample.Connect),
+		milliseconds(sample.Handshake),
+		milliseconds(sample.Auth),
+		milliseconds(sample.ChannelOpen),
+		milliseconds(sample.Total()),
+	)
+	if sample.BytesPerSecond > 0 {
+		fmt.Fprintf(w, " throughput=%s", rate(sample.BytesPerSecond))
+	}
+	fmt.Fprintln(w)
+}
+
+// PrintSummary writes the success rate and the percentiles of each phase
+// across the successful samples.
+func PrintSummary(w io.Writer, title string, samples []Sample) {
+	phases := map[string][]float64{}
+	throughput := []float64{}
+	succeeded := 0
+
+	for _, sample := range samples {
+		if sample.Err != nil {
+			continue
+		}
+		succeeded++
+
+		phases["connect"] = append(phases["connect"], float64(sample.Connect))
+		phases["handshake"] = append(phases["handshake"], float64(sample.Handshake))
+		phases["auth"] = append(phases["auth"], float64(sample.Auth))
+		phases["channel"] = append(phases["channel"], float64(sample.ChannelOpen))
+		phases["total"] = append(phases["total"], float64(sample.Total()))
+		if sample.BytesPerSecond > 0 {
+			throughput = append(throughput, sample.BytesPerSecond)
+		}
+	}
+
+	fmt.Fprintf(w, "--- %s ssh ping statistics ---\n", title)
+	fmt.Fprintf(w, "%d sessions, %d succeeded, %d failed\n", len(samples), succeeded, len(samples)-succeeded)
+	if succeeded == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%-10s  %10s  %10s  %10s  %10s  %10s\n", "", "min", "p50", "p90", "p99", "max")
+	for _, phase := range []string{"connect", "handshake", "auth", "channel", "total"} {
+		stats := Summarize(phases[phase])
+		fmt.Fprintf(w, "%-10s  %10s  %10s  %10s  %10s  %10s\n", phase,
+			milliseconds(time.Duration(stats.Min)),
+			milliseconds(time.Duration(stats.P50)),
+			milliseconds(time.Duration(stats.P90)),
+			milliseconds(time.Duration(stats.P99)),
+			milliseconds(time.Duration(stats.Max)),
+		)
+	}
+
+	if len(throughput) > 0 {
+		stats := Summarize(throughput)
+		fmt.Fprintf(w, "%-10s  %10s  %10s  %10s  %10s  %10s\n", "throughput",
+			rate(stats.Min), rate(stats.P50), rate(stats.P90), rate(stats.P99), rate(stats.Max),
+		)
+	}
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func rate(bytesPerSecond float64) string {
+	return fmt.Sprintf("%.1fMiB/s", bytesPerSecond/(1<<20))
+}
@@ -0,0 +1,13 @@
+package ping_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ping Suite")
+}
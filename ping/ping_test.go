@@ -0,0 +1,101 @@
+package ping_test
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/ping"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ping", func() {
+	Describe("Percentile", func() {
+		values := []float64{15, 20, 35, 40, 50}
+
+		It("uses the nearest rank", func() {
+			Expect(ping.Percentile(values, 0)).To(Equal(15.0))
+			Expect(ping.Percentile(values, 30)).To(Equal(20.0))
+			Expect(ping.Percentile(values, 40)).To(Equal(20.0))
+			Expect(ping.Percentile(values, 50)).To(Equal(35.0))
+			Expect(ping.Percentile(values, 100)).To(Equal(50.0))
+		})
+
+		It("does not depend on the order of the values", func() {
+			Expect(ping.Percentile([]float64{50, 15, 40, 20, 35}, 50)).To(Equal(35.0))
+		})
+
+		It("does not reorder the values", func() {
+			unsorted := []float64{3, 1, 2}
+			ping.Percentile(unsorted, 50)
+			Expect(unsorted).To(Equal([]float64{3, 1, 2}))
+		})
+
+		It("returns 0 without values", func() {
+			Expect(ping.Percentile(nil, 50)).To(BeZero())
+		})
+	})
+
+	Describe("Summarize", func() {
+		It("returns the min, max, and common percentiles", func() {
+			values := []float64{}
+			for i := 1; i <= 100; i++ {
+				values = append(values, float64(i))
+			}
+
+			Expect(ping.Summarize(values)).To(Equal(ping.Stats{Min: 1, P50: 50, P90: 90, P99: 99, Max: 100}))
+		})
+	})
+
+	Describe("PrintSample", func() {
+		It("prints each phase", func() {
+			out := &bytes.Buffer{}
+			ping.PrintSample(out, ping.Sample{
+				Seq:            1,
+				Connect:        2 * time.Millisecond,
+				Handshake:      10 * time.Millisecond,
+				Auth:           25 * time.Millisecond,
+				ChannelOpen:    1500 * time.Microsecond,
+				BytesPerSecond: 8 << 20,
+			})
+
+			Expect(out.String()).To(Equal("seq=1 connect=2.0ms handshake=10.0ms auth=25.0ms channel=1.5ms total=38.5ms throughput=8.0MiB/s\n"))
+		})
+
+		It("prints errors", func() {
+			out := &bytes.Buffer{}
+			ping.PrintSample(out, ping.Sample{Seq: 2, Err: errors.New("connection refused")})
+			Expect(out.String()).To(Equal("seq=2 error: connection refused\n"))
+		})
+	})
+
+	Describe("PrintSummary", func() {
+		It("summarizes the successful samples", func() {
+			out := &bytes.Buffer{}
+			ping.PrintSummary(out, "app1/0", []ping.Sample{
+				{Seq: 1, Connect: time.Millisecond, Handshake: time.Millisecond, Auth: time.Millisecond, ChannelOpen: time.Millisecond},
+				{Seq: 2, Err: errors.New("connection refused")},
+				{Seq: 3, Connect: 3 * time.Millisecond, Handshake: time.Millisecond, Auth: time.Millisecond, ChannelOpen: time.Millisecond},
+			})
+
+			Expect(out.String()).To(Equal(
+				"--- app1/0 ssh ping statistics ---\n" +
+					"3 sessions, 2 succeeded, 1 failed\n" +
+					"                   min         p50         p90         p99         max\n" +
+					"connect          1.0ms       1.0ms       3.0ms       3.0ms       3.0ms\n" +
+					"handshake        1.0ms       1.0ms       1.0ms       1.0ms       1.0ms\n" +
+					"auth             1.0ms       1.0ms       1.0ms       1.0ms       1.0ms\n" +
+					"channel          1.0ms       1.0ms       1.0ms       1.0ms       1.0ms\n" +
+					"total            4.0ms       4.0ms       6.0ms       6.0ms       6.0ms\n",
+			))
+		})
+
+		It("stops after the counts when every sample failed", func() {
+			out := &bytes.Buffer{}
+			ping.PrintSummary(out, "app1/0", []ping.Sample{{Seq: 1, Err: errors.New("woops")}})
+			Expect(out.String()).To(Equal("--- app1/0 ssh ping statistics ---\n1 sessions, 0 succeeded, 1 failed\n"))
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	netproxy "golang.org/x/net/proxy"
+)
+
+type DialFunc func(network, address string) (net.Conn, error)
+
+// Direct dials without a proxy.
+func Direct(timeout time.Duration) DialFunc {
+	return func(network, address string) (net.Conn, error) {
+		return net.DialTimeout(network, address, timeout)
+	}
+}
+
+// ForAddress returns a dialer for address. An explicit proxy URL takes
+// precedence; otherwise all_proxy and https_proxy from the environment are
+// used unless no_proxy excludes the address.
+func ForAddress(explicit string, address string, timeout time.Duration) (DialFunc, error) {
+	proxyURL := explicit
+	if proxyURL == "" {
+		if excluded(getenv("no_proxy"), address) {
+			return Direct(timeout), nil
+		}
+		proxyURL = getenv("all_proxy")
+		if proxyURL == "" {
+			proxyURL = getenv("https_proxy")
+		}
+	}
+
+	if proxyURL == "" {
+		return Direct(timeout), nil
+	}
+
+	u, err := parseURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(u, timeout)
+}
+
+// New returns a dialer that connects through the proxy at u. The http and
+// https schemes use CONNECT; socks5 and socks5h use SOCKS5.
+func New(u *url.URL, timeout time.Duration) (DialFunc, error) {
+	switch u.Scheme {
+	case "http", "https":
+		return connectDialer(u, timeout), nil
+	case "socks5", "socks5h":
+		var auth *netproxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &netproxy.Auth{User: u.User.Username(), Password: password}
+		}
+		dialer, err := netproxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{Timeout: timeout})
+		if err != nil {
+			return nil, err
+		}
+		return dialer.Dial, nil
+	default:
+		return nil, fmt.Errorf("Unsupported proxy scheme: %s", u.Scheme)
+	}
+}
+
+func connectDialer(u *url.URL, timeout time.Duration) DialFunc {
+	return func(network, address string) (net.Conn, error) {
+		conn, err := net.DialTimeout("tcp", u.Host, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "https" {
+			host, _, _ := net.SplitHostPort(u.Host)
+			conn = tls.Client(conn, &tls.Config{ServerName: host})
+		}
+
+		if timeout > 0 {
+			conn.SetDeadline(time.Now().Add(timeout))
+		}
+
+		req := &http.Request{
+			Method: "CONNECT",
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: http.Header{},
+		}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+			req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+		}
+
+		err = req.Write(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("Proxy refused connection to %s: %s", address, resp.Status)
+		}
+
+		conn.SetDeadline(time.Time{})
+
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+}
+
+// bufferedConn returns data read past the proxy response before reading
+// from the connection again.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func parseURL(proxyURL string) (*url.URL, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Invalid proxy URL: %s", proxyURL)
+	}
+
+	if u.Port() == "" {
+		port := "80"
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u, nil
+}
+
+// excluded reports whether address matches an entry of a no_proxy list.
+// Entries are host names, domain suffixes, IP addresses, CIDR ranges, or
+// "*", optionally followed by a port.
+func excluded(noProxy string, address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+
+		if host == entry || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getenv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToUpper(name))
+}
@@ -0,0 +1,226 @@
+package proxy_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Proxy", func() {
+	var listener net.Listener
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	Describe("HTTP CONNECT", func() {
+		var (
+			requests chan *http.Request
+			status   string
+		)
+
+		BeforeEach(func() {
+			requests = make(chan *http.Request, 1)
+			status = "200 Connection established"
+
+			go func() {
+				defer GinkgoRecover()
+
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				Expect(err).NotTo(HaveOccurred())
+				requests <- req
+
+				io.WriteString(conn, "HTTP/1.1 "+status+"\r\n\r\nSSH-2.0-diego-ssh-proxy\r\n")
+			}()
+		})
+
+		It("tunnels through the proxy with basic auth", func() {
+			u, err := url.Parse("http://user:secret@" + listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+
+			dial, err := proxy.New(u, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := dial("tcp", "ssh.example.com:2222")
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			var req *http.Request
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("CONNECT"))
+			Expect(req.Host).To(Equal("ssh.example.com:2222"))
+			Expect(req.Header.Get("Proxy-Authorization")).To(Equal("Basic dXNlcjpzZWNyZXQ="))
+
+			banner, err := ioutil.ReadAll(conn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(banner)).To(Equal("SSH-2.0-diego-ssh-proxy\r\n"))
+		})
+
+		Context("when the proxy refuses the connection", func() {
+			BeforeEach(func() {
+				status = "407 Proxy Authentication Required"
+			})
+
+			It("returns an error", func() {
+				dial, err := proxy.New(&url.URL{Scheme: "http", Host: listener.Addr().String()}, time.Second)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = dial("tcp", "ssh.example.com:2222")
+				Expect(err).To(MatchError("Proxy refused connection to ssh.example.com:2222: 407 Proxy Authentication Required"))
+			})
+		})
+	})
+
+	Describe("SOCKS5", func() {
+		var requested chan []byte
+
+		BeforeEach(func() {
+			requested = make(chan []byte, 1)
+
+			go func() {
+				defer GinkgoRecover()
+
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				greeting := make([]byte, 3)
+				_, err = io.ReadFull(conn, greeting)
+				Expect(err).NotTo(HaveOccurred())
+				conn.Write([]byte{5, 0})
+
+				header := make([]byte, 5)
+				_, err = io.ReadFull(conn, header)
+				Expect(err).NotTo(HaveOccurred())
+				rest := make([]byte, int(header[4])+2)
+				_, err = io.ReadFull(conn, rest)
+				Expect(err).NotTo(HaveOccurred())
+				requested <- rest
+
+				conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 22})
+				io.WriteString(conn, "SSH-2.0-diego-ssh-proxy\r\n")
+			}()
+		})
+
+		It("connects through the proxy", func() {
+			dial, err := proxy.New(&url.URL{Scheme: "socks5", Host: listener.Addr().String()}, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := dial("tcp", "ssh.example.com:2222")
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			Eventually(requested).Should(Receive(Equal(append([]byte("ssh.example.com"), 0x08, 0xae))))
+
+			banner, err := ioutil.ReadAll(conn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(banner)).To(Equal("SSH-2.0-diego-ssh-proxy\r\n"))
+		})
+	})
+
+	Describe("ForAddress", func() {
+		var saved map[string]string
+
+		BeforeEach(func() {
+			saved = map[string]string{}
+			for _, name := range []string{"all_proxy", "ALL_PROXY", "https_proxy", "HTTPS_PROXY", "no_proxy", "NO_PROXY"} {
+				saved[name] = os.Getenv(name)
+				os.Setenv(name, "")
+			}
+		})
+
+		AfterEach(func() {
+			for name, value := range saved {
+				os.Setenv(name, value)
+			}
+		})
+
+		It("dials directly without a proxy", func() {
+			dial, err := proxy.ForAddress("", listener.Addr().String(), time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+		})
+
+		It("uses the explicit proxy", func() {
+			_, err := proxy.ForAddress("ftp://proxy.example.com", "ssh.example.com:2222", time.Second)
+			Expect(err).To(MatchError("Unsupported proxy scheme: ftp"))
+		})
+
+		It("rejects malformed proxy URLs", func() {
+			_, err := proxy.ForAddress("http://", "ssh.example.com:2222", time.Second)
+			Expect(err).To(MatchError("Invalid proxy URL: http://"))
+		})
+
+		It("uses https_proxy from the environment", func() {
+			os.Setenv("HTTPS_PROXY", "ftp://proxy.example.com")
+			_, err := proxy.ForAddress("", "ssh.example.com:2222", time.Second)
+			Expect(err).To(MatchError("Unsupported proxy scheme: ftp"))
+		})
+
+		It("prefers all_proxy over https_proxy", func() {
+			os.Setenv("https_proxy", "http://proxy.example.com")
+			os.Setenv("all_proxy", "gopher://proxy.example.com")
+			_, err := proxy.ForAddress("", "ssh.example.com:2222", time.Second)
+			Expect(err).To(MatchError("Unsupported proxy scheme: gopher"))
+		})
+
+		Context("when no_proxy is set", func() {
+			BeforeEach(func() {
+				os.Setenv("https_proxy", "ftp://proxy.example.com")
+			})
+
+			It("skips the proxy for matching hosts", func() {
+				for _, noProxy := range []string{"*", "ssh.example.com", ".example.com", "example.com", "other.com, example.com:2222", "10.0.0.0/8"} {
+					os.Setenv("no_proxy", noProxy)
+					address := "ssh.example.com:2222"
+					if noProxy == "10.0.0.0/8" {
+						address = "10.1.2.3:2222"
+					}
+					_, err := proxy.ForAddress("", address, time.Second)
+					Expect(err).NotTo(HaveOccurred(), noProxy)
+				}
+			})
+
+			It("uses the proxy for other hosts", func() {
+				for _, noProxy := range []string{"other.com", "ample.com", "example.com:22", "192.168.0.0/16"} {
+					os.Setenv("no_proxy", noProxy)
+					_, err := proxy.ForAddress("", "ssh.example.com:2222", time.Second)
+					Expect(err).To(HaveOccurred(), noProxy)
+				}
+			})
+
+			It("does not apply to an explicit proxy", func() {
+				os.Setenv("no_proxy", "*")
+				_, err := proxy.ForAddress("ftp://proxy.example.com", "ssh.example.com:2222", time.Second)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package reconnect
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing delays between attempts. Jitter is
+// the fraction of each delay that is randomized so that many clients
+// dropped at the same time do not reconnect in lockstep.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.5,
+}
+
+// Duration returns the delay before the given zero-based attempt.
+func (b Backoff) Duration(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 0; i < attempt && delay < float64(b.Max); i++ {
+		delay *= b.Multiplier
+	}
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	jitter := delay * b.Jitter
+	return time.Duration(delay - jitter + rand.Float64()*jitter)
+}
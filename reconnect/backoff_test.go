@@ -0,0 +1,48 @@
+package reconnect_test
+
+import (
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff", func() {
+	var backoff reconnect.Backoff
+
+	BeforeEach(func() {
+		backoff = reconnect.Backoff{
+			Initial:    time.Second,
+			Max:        10 * time.Second,
+			Multiplier: 2,
+		}
+	})
+
+	It("grows exponentially", func() {
+		Expect(backoff.Duration(0)).To(Equal(time.Second))
+		Expect(backoff.Duration(1)).To(Equal(2 * time.Second))
+		Expect(backoff.Duration(2)).To(Equal(4 * time.Second))
+		Expect(backoff.Duration(3)).To(Equal(8 * time.Second))
+	})
+
+	It("is capped at the maximum", func() {
+		Expect(backoff.Duration(4)).To(Equal(10 * time.Second))
+		Expect(backoff.Duration(100)).To(Equal(10 * time.Second))
+	})
+
+	Context("with jitter", func() {
+		BeforeEach(func() {
+			backoff.Jitter = 0.5
+		})
+
+		It("randomizes the delay within the jitter fraction", func() {
+			for i := 0; i < 100; i++ {
+				delay := backoff.Duration(2)
+				Expect(delay).To(BeNumerically(">=", 2*time.Second))
+				Expect(delay).To(BeNumerically("<=", 4*time.Second))
+			}
+		})
+	})
+})
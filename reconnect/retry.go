@@ -0,0 +1,48 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Transient reports whether err is likely to clear up on its own, such as a
+// refused connection or a handshake cut short while an instance starts.
+func Transient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Retry calls attempt until it succeeds, fails with an error that is not
+// transient, or has been called attempts times. Each retry is announced on
+// out and delayed according to backoff.
+func Retry(attempts int, backoff Backoff, out io.Writer, attempt func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			delay := backoff.Duration(i - 1)
+			fmt.Fprintf(out, "Connection attempt failed: %s\nRetrying in %s\n", err, delay.Round(time.Millisecond))
+			time.Sleep(delay)
+		}
+
+		err = attempt()
+		if err == nil || !Transient(err) {
+			return err
+		}
+	}
+	return err
+}
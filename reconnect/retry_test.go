@@ -0,0 +1,91 @@
+package reconnect_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ = Describe("Retry", func() {
+	Describe("Transient", func() {
+		It("recognizes refused connections, resets, EOFs, and timeouts", func() {
+			refused := &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+
+			Expect(reconnect.Transient(refused)).To(BeTrue())
+			Expect(reconnect.Transient(syscall.ECONNRESET)).To(BeTrue())
+			Expect(reconnect.Transient(fmt.Errorf("ssh: handshake failed: %w", io.EOF))).To(BeTrue())
+			Expect(reconnect.Transient(&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}})).To(BeTrue())
+		})
+
+		It("rejects everything else", func() {
+			Expect(reconnect.Transient(nil)).To(BeFalse())
+			Expect(reconnect.Transient(errors.New("ssh: handshake failed: ssh: unable to authenticate"))).To(BeFalse())
+			Expect(reconnect.Transient(&net.DNSError{Err: "no such host", Name: "nowhere"})).To(BeFalse())
+		})
+	})
+
+	Describe("Retry", func() {
+		var (
+			backoff reconnect.Backoff
+			out     *bytes.Buffer
+			calls   int
+		)
+
+		BeforeEach(func() {
+			backoff = reconnect.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2}
+			out = &bytes.Buffer{}
+			calls = 0
+		})
+
+		It("retries transient errors until the attempt succeeds", func() {
+			err := reconnect.Retry(3, backoff, out, func() error {
+				calls++
+				if calls < 3 {
+					return io.EOF
+				}
+				return nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls).To(Equal(3))
+			Expect(out.String()).To(Equal("Connection attempt failed: EOF\nRetrying in 1ms\nConnection attempt failed: EOF\nRetrying in 1ms\n"))
+		})
+
+		It("returns the last error after the final attempt", func() {
+			err := reconnect.Retry(2, backoff, out, func() error {
+				calls++
+				return fmt.Errorf("attempt %d: %w", calls, io.EOF)
+			})
+
+			Expect(err).To(MatchError("attempt 2: EOF"))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("does not retry other errors", func() {
+			err := reconnect.Retry(3, backoff, out, func() error {
+				calls++
+				return errors.New("ssh: unable to authenticate")
+			})
+
+			Expect(err).To(MatchError("ssh: unable to authenticate"))
+			Expect(calls).To(Equal(1))
+			Expect(out.Len()).To(BeZero())
+		})
+	})
+})
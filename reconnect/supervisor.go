@@ -0,0 +1,208 @@
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrClosed = errors.New("connection closed")
+
+//go:generate counterfeiter -o reconnect_fakes/fake_client.go . Client
+type Client interface {
+	Dial(network, address string) (net.Conn, error)
+	NewSession() (*ssh.Session, error)
+	Wait() error
+	Close() error
+}
+
+// Connector establishes a new connection. It is expected to resolve
+// everything it needs, including a fresh credential, on every call.
+type Connector func() (Client, error)
+
+// Supervisor owns a connection and replaces it when it is lost. Callers
+// that dial through the supervisor wait while it reconnects instead of
+// failing, so local listeners can stay open across reconnects.
+type Supervisor struct {
+	connect     Connector
+	backoff     Backoff
+	maxAttempts int
+	permanent   func(error) bool
+	out         io.Writer
+
+	lock   sync.Mutex
+	client Client
+	ready  chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a supervisor. A maxAttempts of zero retries forever, except
+// that reconnecting stops at the first error permanent reports, such as an
+// app that has been deleted; permanent may be nil.
+func New(connect Connector, backoff Backoff, maxAttempts int, permanent func(error) bool, out io.Writer) *Supervisor {
+	return &Supervisor{
+		connect:     connect,
+		backoff:     backoff,
+		maxAttempts: maxAttempts,
+		permanent:   permanent,
+		out:         out,
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start makes the initial connection. It does not retry; a failure here is
+// reported to the user as is.
+func (s *Supervisor) Start() error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	s.setClient(client)
+	return nil
+}
+
+// Connected reports whether a connection is currently established.
+func (s *Supervisor) Connected() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.client != nil
+}
+
+// Client returns the current connection, waiting for a reconnect to
+// complete if necessary.
+func (s *Supervisor) Client() (Client, error) {
+	for {
+		s.lock.Lock()
+		client, ready := s.client, s.ready
+		s.lock.Unlock()
+
+		if client != nil {
+			return client, nil
+		}
+
+		select {
+		case <-ready:
+		case <-s.done:
+			return nil, ErrClosed
+		}
+	}
+}
+
+func (s *Supervisor) Dial(network, address string) (net.Conn, error) {
+	client, err := s.Client()
+	if err != nil {
+		return nil, err
+	}
+	return client.Dial(network, address)
+}
+
+// Run watches the connection and reconnects each time it is lost. It
+// returns nil once the supervisor is closed or an error when it gives up.
+func (s *Supervisor) Run() error {
+	for {
+		client, err := s.Client()
+		if err != nil {
+			return nil
+		}
+
+		client.Wait()
+
+		select {
+		case <-s.done:
+			return nil
+		default:
+		}
+
+		s.clearClient(client)
+		fmt.Fprintln(s.out, "Connection lost")
+
+		err = s.reconnect()
+		if err == ErrClosed {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Supervisor) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	s.lock.Lock()
+	client := s.client
+	s.lock.Unlock()
+
+	if client != nil {
+		return client.Close()
+	}
+	return nil
+}
+
+func (s *Supervisor) reconnect() error {
+	var lastErr error
+
+	for attempt := 0; s.maxAttempts == 0 || attempt < s.maxAttempts; attempt++ {
+		delay := s.backoff.Duration(attempt)
+		fmt.Fprintf(s.out, "Reconnecting in %s...\n", delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-s.done:
+			return ErrClosed
+		}
+
+		client, err := s.connect()
+		if err != nil {
+			if s.permanent != nil && s.permanent(err) {
+				return err
+			}
+			lastErr = err
+			fmt.Fprintf(s.out, "Reconnect failed: %s\n", err)
+			continue
+		}
+
+		select {
+		case <-s.done:
+			client.Close()
+			return ErrClosed
+		default:
+		}
+
+		s.setClient(client)
+		fmt.Fprintln(s.out, "Reconnected")
+		return nil
+	}
+
+	return fmt.Errorf("Gave up reconnecting after %d attempts: %s", s.maxAttempts, lastErr)
+}
+
+func (s *Supervisor) setClient(client Client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.client = client
+	close(s.ready)
+}
+
+func (s *Supervisor) clearClient(client Client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.client == client {
+		s.client = nil
+		s.ready = make(chan struct{})
+	}
+	client.Close()
+}
@@ -0,0 +1,176 @@
+package reconnect_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+	"github.com/sykesm/cf-ssh-plugin/reconnect/reconnect_fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Supervisor", func() {
+	var (
+		lock       sync.Mutex
+		clients    []*reconnect_fakes.FakeClient
+		connectErr error
+		connects   int
+		permanent  error
+
+		disconnect chan struct{}
+		output     *gbytes.Buffer
+		supervisor *reconnect.Supervisor
+	)
+
+	newClient := func() *reconnect_fakes.FakeClient {
+		lost := disconnect
+		client := &reconnect_fakes.FakeClient{}
+		client.WaitStub = func() error {
+			<-lost
+			return errors.New("connection reset")
+		}
+		return client
+	}
+
+	BeforeEach(func() {
+		clients = nil
+		connectErr = nil
+		connects = 0
+		permanent = errors.New("app deleted")
+		disconnect = make(chan struct{})
+		output = gbytes.NewBuffer()
+
+		connector := func() (reconnect.Client, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			connects++
+			if connectErr != nil {
+				return nil, connectErr
+			}
+
+			client := newClient()
+			clients = append(clients, client)
+			return client, nil
+		}
+
+		backoff := reconnect.Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+		isPermanent := func(err error) bool { return err == permanent }
+		supervisor = reconnect.New(connector, backoff, 3, isPermanent, output)
+	})
+
+	AfterEach(func() {
+		supervisor.Close()
+	})
+
+	connectCount := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return connects
+	}
+
+	Describe("Start", func() {
+		It("makes the initial connection", func() {
+			Expect(supervisor.Start()).To(Succeed())
+			Expect(supervisor.Connected()).To(BeTrue())
+
+			client, err := supervisor.Client()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).To(Equal(clients[0]))
+		})
+
+		It("does not retry a failed initial connection", func() {
+			connectErr = errors.New("app not found")
+			Expect(supervisor.Start()).To(MatchError("app not found"))
+			Expect(connectCount()).To(Equal(1))
+		})
+	})
+
+	Describe("Dial", func() {
+		It("dials through the current client", func() {
+			Expect(supervisor.Start()).To(Succeed())
+
+			clients[0].DialReturns(&net.TCPConn{}, nil)
+			_, err := supervisor.Dial("tcp", "db:5432")
+			Expect(err).NotTo(HaveOccurred())
+
+			network, address := clients[0].DialArgsForCall(0)
+			Expect(network).To(Equal("tcp"))
+			Expect(address).To(Equal("db:5432"))
+		})
+
+		It("fails while disconnected once the supervisor is closed", func() {
+			supervisor.Close()
+
+			_, err := supervisor.Dial("tcp", "db:5432")
+			Expect(err).To(Equal(reconnect.ErrClosed))
+		})
+	})
+
+	Describe("Run", func() {
+		var runErr chan error
+
+		BeforeEach(func() {
+			Expect(supervisor.Start()).To(Succeed())
+
+			runErr = make(chan error, 1)
+			go func() {
+				runErr <- supervisor.Run()
+			}()
+		})
+
+		It("reconnects when the connection is lost", func() {
+			lock.Lock()
+			lost := disconnect
+			disconnect = make(chan struct{})
+			lock.Unlock()
+
+			close(lost)
+
+			Eventually(connectCount).Should(Equal(2))
+			Eventually(output).Should(gbytes.Say("Connection lost"))
+			Eventually(output).Should(gbytes.Say("Reconnected"))
+			Eventually(supervisor.Connected).Should(BeTrue())
+
+			client, err := supervisor.Client()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).To(Equal(clients[1]))
+			Expect(clients[0].CloseCallCount()).To(BeNumerically(">=", 1))
+		})
+
+		It("gives up after the maximum number of attempts", func() {
+			lock.Lock()
+			connectErr = errors.New("token expired")
+			lock.Unlock()
+
+			close(disconnect)
+
+			Eventually(runErr).Should(Receive(MatchError("Gave up reconnecting after 3 attempts: token expired")))
+			Expect(connectCount()).To(Equal(4))
+		})
+
+		It("stops at an error that reconnecting cannot fix", func() {
+			lock.Lock()
+			connectErr = permanent
+			lock.Unlock()
+
+			close(disconnect)
+
+			Eventually(runErr).Should(Receive(Equal(permanent)))
+			Expect(connectCount()).To(Equal(2))
+			Expect(output).NotTo(gbytes.Say("Reconnect failed"))
+		})
+
+		It("returns when the supervisor is closed", func() {
+			supervisor.Close()
+			close(disconnect)
+
+			Eventually(runErr).Should(Receive(BeNil()))
+		})
+	})
+})
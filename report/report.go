@@ -0,0 +1,375 @@
+// Package report builds the documents printed by informational commands
+// with --output json. Every document carries a version; fields are only
+// ever added within a version, never renamed or removed.
+//
+// cf ssh-info APP-NAME --output json prints an SSHInfo:
+//
+//	{
+//	  "version": 1,
+//	  "app": {
+//	    "name": "app1",
+//	    "guid": "app1-guid",
+//	    "space_guid": "space1-guid",
+//	    "process_type": "web",
+//	    "process_guid": "app1-guid",
+//	    "state": "STARTED",
+//	    "instances": 2,
+//	    "diego": true,
+//	    "enable_ssh": true
+//	  },
+//	  "space": {
+//	    "guid": "space1-guid",
+//	    "name": "development",
+//	    "allow_ssh": true
+//	  },
+//	  "instance": 1,
+//	  "user": "cf:app1-guid/1",
+//	  "ssh_enabled": true,
+//	  "endpoint": {
+//	    "address": "ssh.example.com:2222",
+//	    "host_key_fingerprint": "a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a"
+//	  }
+//	}
+//
+// The instance and user refer to the process selected with --process, the
+// web process by default.
+//
+// ssh_enabled is true only when the app runs on Diego, has SSH enabled, and
+// its space allows SSH.
+//
+// cf ssh-tunnel list --output json prints a TunnelList:
+//
+//	{
+//	  "version": 1,
+//	  "tunnels": [
+//	    {
+//	      "name": "db-debug",
+//	      "health": "healthy",
+//	      "pid": 4242,
+//	      "app": "app1",
+//	      "instance": 0,
+//	      "forwards": ["localhost:5432:db:5432"],
+//	      "started_at": "2016-01-02T03:04:05Z",
+//	      "uptime_seconds": 90,
+//	      "connections": 3,
+//	      "bytes_in": 1024,
+//	      "bytes_out": 512
+//	    }
+//	  ]
+//	}
+//
+// When either command fails, it prints a Failure instead and exits with the
+// same non-zero status as without --output json:
+//
+//	{
+//	  "version": 1,
+//	  "error": {
+//	    "kind": "ssh_disabled",
+//	    "message": "SSH is disabled for app app1",
+//	    "hint": "Enable it with 'cf enable-ssh app1' and restart the app."
+//	  }
+//	}
+//
+// kind is unknown for errors without a more specific kind, and hint is
+// omitted when there is none. Arguments that cannot be parsed are reported
+// the same way, with kind invalid_usage, whenever they include
+// --output json.
+//
+// cf ssh APP-NAME -c COMMAND --output json prints an ExecSummary of the
+// command's session on the selected instance, and with -i all of one
+// session per running instance:
+//
+//	{
+//	  "version": 1,
+//	  "app": {
+//	    "name": "app1",
+//	    "guid": "app1-guid",
+//	    "space_guid": "space1-guid",
+//	    "process_type": "web",
+//	    "process_guid": "app1-guid",
+//	    "state": "STARTED",
+//	    "instances": 3,
+//	    "diego": true,
+//	    "enable_ssh": true
+//	  },
+//	  "command": "uptime",
+//	  "sessions": [
+//	    {
+//	      "instance": 0,
+//	      "exit_status": 0,
+//	      "stdout": " 03:04:05 up 2 days,  1:02,  load average: 0.10, 0.08, 0.05\n",
+//	      "stderr": "",
+//	      "duration_seconds": 0.412
+//	    },
+//	    {
+//	      "instance": 1,
+//	      "signal": "KILL",
+//	      "stdout": "",
+//	      "stderr": "",
+//	      "duration_seconds": 1.5
+//	    },
+//	    {
+//	      "instance": 2,
+//	      "error": {
+//	        "kind": "unknown",
+//	        "message": "Failed to allocate SSH session"
+//	      },
+//	      "stdout": "",
+//	      "stderr": "",
+//	      "duration_seconds": 0
+//	    }
+//	  ],
+//	  "succeeded": 1,
+//	  "failed": 2
+//	}
+//
+// Sessions are in instance order. Each has exactly one of exit_status, when
+// the command exited; signal, when it was killed; or error, when it could
+// not be run or ended without a status. A session succeeds when its exit
+// status is 0. The plugin exits with the status of the first session that
+// did not succeed.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+	"github.com/sykesm/cf-ssh-plugin/tunnel"
+)
+
+const Version = 1
+
+type App struct {
+	Name        string `json:"name"`
+	Guid        string `json:"guid"`
+	SpaceGuid   string `json:"space_guid"`
+	ProcessType string `json:"process_type"`
+	ProcessGuid string `json:"process_guid"`
+	State       string `json:"state"`
+	Instances   int    `json:"instances"`
+	Diego       bool   `json:"diego"`
+	EnableSSH   bool   `json:"enable_ssh"`
+}
+
+type Space struct {
+	Guid     string `json:"guid"`
+	Name     string `json:"name"`
+	AllowSSH bool   `json:"allow_ssh"`
+}
+
+type Endpoint struct {
+	Address            string `json:"address"`
+	HostKeyFingerprint string `json:"host_key_fingerprint"`
+}
+
+type SSHInfo struct {
+	Version    int      `json:"version"`
+	App        App      `json:"app"`
+	Space      Space    `json:"space"`
+	Instance   int      `json:"instance"`
+	User       string   `json:"user"`
+	SSHEnabled bool     `json:"ssh_enabled"`
+	Endpoint   Endpoint `json:"endpoint"`
+}
+
+func newApp(a app.App) App {
+	return App{
+		Name:        a.Name,
+		Guid:        a.Guid,
+		SpaceGuid:   a.SpaceGuid,
+		ProcessType: a.ProcessType,
+		ProcessGuid: a.ProcessGuid,
+		State:       a.State,
+		Instances:   a.Instances,
+		Diego:       a.Diego,
+		EnableSSH:   a.EnableSSH,
+	}
+}
+
+func NewSSHInfo(a app.App, s space.Space, instance int, i info.Info) SSHInfo {
+	return SSHInfo{
+		Version: Version,
+		App:     newApp(a),
+		Space: Space{
+			Guid:     s.Guid,
+			Name:     s.Name,
+			AllowSSH: s.AllowSSH,
+		},
+		Instance:   instance,
+		User:       a.User(instance),
+		SSHEnabled: a.Diego && a.EnableSSH && s.AllowSSH,
+		Endpoint: Endpoint{
+			Address:            i.SSHEndpoint,
+			HostKeyFingerprint: i.SSHEndpointFingerprint,
+		},
+	}
+}
+
+type Tunnel struct {
+	Name          string    `json:"name"`
+	Health        string    `json:"health"`
+	Pid           int       `json:"pid"`
+	App           string    `json:"app"`
+	Instance      int       `json:"instance"`
+	Forwards      []string  `json:"forwards"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Connections   int64     `json:"connections"`
+	BytesIn       int64     `json:"bytes_in"`
+	BytesOut      int64     `json:"bytes_out"`
+}
+
+type TunnelList struct {
+	Version int      `json:"version"`
+	Tunnels []Tunnel `json:"tunnels"`
+}
+
+// NewTunnelList describes the running tunnels as of now. Health is passed
+// in rather than computed so the document does not depend on the processes
+// being inspected.
+func NewTunnelList(states []tunnel.State, health func(tunnel.State) string, now time.Time) TunnelList {
+	tunnels := []Tunnel{}
+	for _, state := range states {
+		forwards := state.Forwards
+		if forwards == nil {
+			forwards = []string{}
+		}
+
+		tunnels = append(tunnels, Tunnel{
+			Name:          state.Name,
+			Health:        health(state),
+			Pid:           state.Pid,
+			App:           state.App,
+			Instance:      state.Instance,
+			Forwards:      forwards,
+			StartedAt:     state.StartedAt.UTC(),
+			UptimeSeconds: int64(now.Sub(state.StartedAt) / time.Second),
+			Connections:   state.Connections,
+			BytesIn:       state.BytesIn,
+			BytesOut:      state.BytesOut,
+		})
+	}
+
+	return TunnelList{Version: Version, Tunnels: tunnels}
+}
+
+type ErrorDetail struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type Failure struct {
+	Version int         `json:"version"`
+	Error   ErrorDetail `json:"error"`
+}
+
+// NewErrorDetail describes err, taking the kind and hint from the first
+// failure it wraps.
+func NewErrorDetail(err error) ErrorDetail {
+	detail := ErrorDetail{Kind: failures.Kind(0).String(), Message: err.Error()}
+
+	var failure *failures.Error
+	if errors.As(err, &failure) {
+		detail.Kind = failure.Kind.String()
+		detail.Hint = failure.Hint
+	}
+
+	return detail
+}
+
+func NewFailure(err error) Failure {
+	return Failure{Version: Version, Error: NewErrorDetail(err)}
+}
+
+type Session struct {
+	Instance        int          `json:"instance"`
+	ExitStatus      *int         `json:"exit_status,omitempty"`
+	Signal          string       `json:"signal,omitempty"`
+	Error           *ErrorDetail `json:"error,omitempty"`
+	Stdout          string       `json:"stdout"`
+	Stderr          string       `json:"stderr"`
+	DurationSeconds float64      `json:"duration_seconds"`
+}
+
+// Succeeded reports whether the command exited with status 0.
+func (s Session) Succeeded() bool {
+	return s.ExitStatus != nil && *s.ExitStatus == 0
+}
+
+// NewSession describes the command run on instance for duration. It ended
+// with the exit status when signal and err are empty, was killed when
+// signal is set, and could not be run or finished when err is set.
+func NewSession(instance int, status int, signal string, err error, stdout, stderr []byte, duration time.Duration) Session {
+	session := Session{
+		Instance:        instance,
+		Stdout:          string(stdout),
+		Stderr:          string(stderr),
+		DurationSeconds: duration.Round(time.Millisecond).Seconds(),
+	}
+
+	switch {
+	case err != nil:
+		detail := NewErrorDetail(err)
+		session.Error = &detail
+	case signal != "":
+		session.Signal = signal
+	default:
+		session.ExitStatus = &status
+	}
+
+	return session
+}
+
+type ExecSummary struct {
+	Version   int       `json:"version"`
+	App       App       `json:"app"`
+	Command   string    `json:"command"`
+	Sessions  []Session `json:"sessions"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+}
+
+// NewExecSummary describes command run on instances of a, ordering the
+// sessions by instance.
+func NewExecSummary(a app.App, command string, sessions []Session) ExecSummary {
+	ordered := append([]Session{}, sessions...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Instance < ordered[j].Instance
+	})
+
+	summary := ExecSummary{
+		Version:  Version,
+		App:      newApp(a),
+		Command:  command,
+		Sessions: ordered,
+	}
+	for _, session := range ordered {
+		if session.Succeeded() {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+
+	return summary
+}
+
+// Write prints document as indented JSON followed by a newline.
+func Write(w io.Writer, document interface{}) error {
+	encoded, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
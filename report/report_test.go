@@ -0,0 +1,279 @@
+package report_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+	"github.com/sykesm/cf-ssh-plugin/report"
+	"github.com/sykesm/cf-ssh-plugin/tunnel"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	Describe("SSHInfo", func() {
+		var (
+			model       app.App
+			spaceModel  space.Space
+			sshEndpoint info.Info
+		)
+
+		BeforeEach(func() {
+			model = app.App{
+				Name:        "app1",
+				Guid:        "app1-guid",
+				SpaceGuid:   "space1-guid",
+				ProcessType: "web",
+				ProcessGuid: "app1-guid",
+				Instances:   2,
+				EnableSSH:   true,
+				Diego:       true,
+				State:       "STARTED",
+			}
+			spaceModel = space.Space{Guid: "space1-guid", Name: "development", AllowSSH: true}
+			sshEndpoint = info.Info{
+				SSHEndpoint:            "ssh.example.com:2222",
+				SSHEndpointFingerprint: "a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a",
+				TokenEndpoint:          "https://uaa.example.com",
+			}
+		})
+
+		It("matches the documented schema", func() {
+			err := report.Write(out, report.NewSSHInfo(model, spaceModel, 1, sshEndpoint))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.String()).To(MatchJSON(`{
+				"version": 1,
+				"app": {
+					"name": "app1",
+					"guid": "app1-guid",
+					"space_guid": "space1-guid",
+					"process_type": "web",
+					"process_guid": "app1-guid",
+					"state": "STARTED",
+					"instances": 2,
+					"diego": true,
+					"enable_ssh": true
+				},
+				"space": {
+					"guid": "space1-guid",
+					"name": "development",
+					"allow_ssh": true
+				},
+				"instance": 1,
+				"user": "cf:app1-guid/1",
+				"ssh_enabled": true,
+				"endpoint": {
+					"address": "ssh.example.com:2222",
+					"host_key_fingerprint": "a6:d1:08:0b:b0:cb:9b:5f:c4:ba:44:2a:97:26:19:8a"
+				}
+			}`))
+		})
+
+		It("is disabled when the app is not on Diego", func() {
+			model.Diego = false
+			Expect(report.NewSSHInfo(model, spaceModel, 0, sshEndpoint).SSHEnabled).To(BeFalse())
+		})
+
+		It("is disabled when the app has SSH disabled", func() {
+			model.EnableSSH = false
+			Expect(report.NewSSHInfo(model, spaceModel, 0, sshEndpoint).SSHEnabled).To(BeFalse())
+		})
+
+		It("is disabled when the space disallows SSH", func() {
+			spaceModel.AllowSSH = false
+			Expect(report.NewSSHInfo(model, spaceModel, 0, sshEndpoint).SSHEnabled).To(BeFalse())
+		})
+	})
+
+	Describe("TunnelList", func() {
+		var (
+			now    time.Time
+			health func(tunnel.State) string
+		)
+
+		BeforeEach(func() {
+			now = time.Date(2016, 1, 2, 3, 5, 35, 0, time.UTC)
+			health = func(tunnel.State) string { return "healthy" }
+		})
+
+		It("matches the documented schema", func() {
+			states := []tunnel.State{{
+				Name:        "db-debug",
+				Pid:         4242,
+				App:         "app1",
+				Instance:    0,
+				Forwards:    []string{"localhost:5432:db:5432"},
+				Status:      tunnel.StatusConnected,
+				StartedAt:   time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+				UpdatedAt:   now,
+				Connections: 3,
+				BytesIn:     1024,
+				BytesOut:    512,
+			}}
+
+			err := report.Write(out, report.NewTunnelList(states, health, now))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.String()).To(MatchJSON(`{
+				"version": 1,
+				"tunnels": [
+					{
+						"name": "db-debug",
+						"health": "healthy",
+						"pid": 4242,
+						"app": "app1",
+						"instance": 0,
+						"forwards": ["localhost:5432:db:5432"],
+						"started_at": "2016-01-02T03:04:05Z",
+						"uptime_seconds": 90,
+						"connections": 3,
+						"bytes_in": 1024,
+						"bytes_out": 512
+					}
+				]
+			}`))
+		})
+
+		It("prints empty lists rather than null", func() {
+			err := report.Write(out, report.NewTunnelList(nil, health, now))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(MatchJSON(`{"version": 1, "tunnels": []}`))
+		})
+
+		It("prints tunnels without forwards with an empty list", func() {
+			list := report.NewTunnelList([]tunnel.State{{Name: "db-debug", StartedAt: now}}, health, now)
+			Expect(list.Tunnels[0].Forwards).To(Equal([]string{}))
+		})
+	})
+
+	Describe("ExecSummary", func() {
+		var model app.App
+
+		BeforeEach(func() {
+			model = app.App{
+				Name:        "app1",
+				Guid:        "app1-guid",
+				SpaceGuid:   "space1-guid",
+				ProcessType: "web",
+				ProcessGuid: "app1-guid",
+				Instances:   3,
+				EnableSSH:   true,
+				Diego:       true,
+				State:       "STARTED",
+			}
+		})
+
+		It("matches the documented schema", func() {
+			sessions := []report.Session{
+				report.NewSession(2, 0, "", errors.New("Failed to allocate SSH session"), nil, nil, 0),
+				report.NewSession(0, 0, "", nil, []byte(" 03:04:05 up 2 days\n"), nil, 412345*time.Microsecond),
+				report.NewSession(1, 143, "KILL", nil, nil, nil, 1500*time.Millisecond),
+			}
+
+			err := report.Write(out, report.NewExecSummary(model, "uptime", sessions))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.String()).To(MatchJSON(`{
+				"version": 1,
+				"app": {
+					"name": "app1",
+					"guid": "app1-guid",
+					"space_guid": "space1-guid",
+					"process_type": "web",
+					"process_guid": "app1-guid",
+					"state": "STARTED",
+					"instances": 3,
+					"diego": true,
+					"enable_ssh": true
+				},
+				"command": "uptime",
+				"sessions": [
+					{
+						"instance": 0,
+						"exit_status": 0,
+						"stdout": " 03:04:05 up 2 days\n",
+						"stderr": "",
+						"duration_seconds": 0.412
+					},
+					{
+						"instance": 1,
+						"signal": "KILL",
+						"stdout": "",
+						"stderr": "",
+						"duration_seconds": 1.5
+					},
+					{
+						"instance": 2,
+						"error": {
+							"kind": "unknown",
+							"message": "Failed to allocate SSH session"
+						},
+						"stdout": "",
+						"stderr": "",
+						"duration_seconds": 0
+					}
+				],
+				"succeeded": 1,
+				"failed": 2
+			}`))
+		})
+
+		It("counts nonzero exit statuses as failures", func() {
+			summary := report.NewExecSummary(model, "false", []report.Session{
+				report.NewSession(0, 1, "", nil, nil, []byte("oops"), time.Second),
+			})
+
+			Expect(summary.Sessions[0].Succeeded()).To(BeFalse())
+			Expect(*summary.Sessions[0].ExitStatus).To(Equal(1))
+			Expect(summary.Sessions[0].Stderr).To(Equal("oops"))
+			Expect(summary.Failed).To(Equal(1))
+		})
+
+		It("takes the kind and hint of failures", func() {
+			session := report.NewSession(0, 0, "", failures.SSHDisabled("app1"), nil, nil, 0)
+			Expect(session.Error.Kind).To(Equal("ssh_disabled"))
+			Expect(session.Error.Hint).NotTo(BeEmpty())
+		})
+
+		It("prints empty lists rather than null", func() {
+			err := report.Write(out, report.NewExecSummary(model, "uptime", nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(ContainSubstring(`"sessions": []`))
+		})
+	})
+
+	Describe("Failure", func() {
+		It("matches the documented schema", func() {
+			err := report.Write(out, report.NewFailure(fmt.Errorf("connect: %w", failures.SSHDisabled("app1"))))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(MatchJSON(`{
+				"version": 1,
+				"error": {
+					"kind": "ssh_disabled",
+					"message": "connect: SSH is disabled for app app1",
+					"hint": "Enable it with 'cf enable-ssh app1' and restart the app."
+				}
+			}`))
+		})
+
+		It("describes other errors without a hint", func() {
+			err := report.Write(out, report.NewFailure(errors.New("woops")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(MatchJSON(`{"version": 1, "error": {"kind": "unknown", "message": "woops"}}`))
+		})
+	})
+})
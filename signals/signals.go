@@ -0,0 +1,53 @@
+package signals
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Forwarded are the local signals that are relayed to remote commands.
+var Forwarded = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+//go:generate counterfeiter -o signals_fakes/fake_signaler.go . Signaler
+type Signaler interface {
+	Signal(sig ssh.Signal) error
+}
+
+// Relay delivers each signal received on signals to the signaler until the
+// channel is closed. The first interrupt is relayed like any other signal;
+// a second one calls force instead, for remote commands that ignore it.
+func Relay(signaler Signaler, signals <-chan os.Signal, force func()) {
+	interrupted := false
+
+	for sig := range signals {
+		if sig == syscall.SIGINT {
+			if interrupted {
+				force()
+				continue
+			}
+			interrupted = true
+		}
+
+		if remote, ok := SSHSignal(sig); ok {
+			signaler.Signal(remote)
+		}
+	}
+}
+
+// SSHSignal returns the SSH signal name for a local signal.
+func SSHSignal(sig os.Signal) (ssh.Signal, bool) {
+	switch sig {
+	case syscall.SIGINT:
+		return ssh.SIGINT, true
+	case syscall.SIGTERM:
+		return ssh.SIGTERM, true
+	case syscall.SIGHUP:
+		return ssh.SIGHUP, true
+	case syscall.SIGQUIT:
+		return ssh.SIGQUIT, true
+	default:
+		return "", false
+	}
+}
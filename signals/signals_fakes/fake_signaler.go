@@ -0,0 +1,54 @@
+// This file was generated by counterfeiter
+package signals_fakes
+
+import (
+	"sync"
+
+	"github.com/sykesm/cf-ssh-plugin/signals"
+	"golang.org/x/crypto/ssh"
+)
+
+type FakeSignaler struct {
+	SignalStub        func(sig ssh.Signal) error
+	signalMutex       sync.RWMutex
+	signalArgsForCall []struct {
+		sig ssh.Signal
+	}
+	signalReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeSignaler) Signal(sig ssh.Signal) error {
+	fake.signalMutex.Lock()
+	fake.signalArgsForCall = append(fake.signalArgsForCall, struct {
+		sig ssh.Signal
+	}{sig})
+	fake.signalMutex.Unlock()
+	if fake.SignalStub != nil {
+		return fake.SignalStub(sig)
+	} else {
+		return fake.signalReturns.result1
+	}
+}
+
+func (fake *FakeSignaler) SignalCallCount() int {
+	fake.signalMutex.RLock()
+	defer fake.signalMutex.RUnlock()
+	return len(fake.signalArgsForCall)
+}
+
+func (fake *FakeSignaler) SignalArgsForCall(i int) ssh.Signal {
+	fake.signalMutex.RLock()
+	defer fake.signalMutex.RUnlock()
+	return fake.signalArgsForCall[i].sig
+}
+
+func (fake *FakeSignaler) SignalReturns(result1 error) {
+	fake.SignalStub = nil
+	fake.signalReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ signals.Signaler = new(FakeSignaler)
@@ -0,0 +1,70 @@
+package signals_test
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/sykesm/cf-ssh-plugin/signals"
+	"github.com/sykesm/cf-ssh-plugin/signals/signals_fakes"
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signals", func() {
+	Describe("Relay", func() {
+		var (
+			fakeSignaler *signals_fakes.FakeSignaler
+			sigs         chan os.Signal
+			forced       int
+		)
+
+		BeforeEach(func() {
+			fakeSignaler = &signals_fakes.FakeSignaler{}
+			sigs = make(chan os.Signal, 8)
+			forced = 0
+		})
+
+		relay := func(received ...os.Signal) {
+			for _, sig := range received {
+				sigs <- sig
+			}
+			close(sigs)
+			signals.Relay(fakeSignaler, sigs, func() { forced++ })
+		}
+
+		It("delivers each signal to the remote session", func() {
+			relay(syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+			Expect(fakeSignaler.SignalCallCount()).To(Equal(3))
+			Expect(fakeSignaler.SignalArgsForCall(0)).To(Equal(ssh.SIGTERM))
+			Expect(fakeSignaler.SignalArgsForCall(1)).To(Equal(ssh.SIGHUP))
+			Expect(fakeSignaler.SignalArgsForCall(2)).To(Equal(ssh.SIGQUIT))
+			Expect(forced).To(BeZero())
+		})
+
+		It("forces termination on a second interrupt", func() {
+			relay(syscall.SIGINT, syscall.SIGTERM, syscall.SIGINT)
+
+			Expect(fakeSignaler.SignalCallCount()).To(Equal(2))
+			Expect(fakeSignaler.SignalArgsForCall(0)).To(Equal(ssh.SIGINT))
+			Expect(fakeSignaler.SignalArgsForCall(1)).To(Equal(ssh.SIGTERM))
+			Expect(forced).To(Equal(1))
+		})
+	})
+
+	Describe("SSHSignal", func() {
+		It("maps the forwarded signals", func() {
+			for _, sig := range signals.Forwarded {
+				_, ok := signals.SSHSignal(sig)
+				Expect(ok).To(BeTrue())
+			}
+		})
+
+		It("ignores other signals", func() {
+			_, ok := signals.SSHSignal(os.Kill)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
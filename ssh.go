@@ -4,21 +4,55 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/cloudfoundry-incubator/diego-ssh/helpers"
 	"github.com/cloudfoundry/cli/plugin"
+	"github.com/pivotal-golang/lager"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/escape"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/forwarder"
+	"github.com/sykesm/cf-ssh-plugin/handshake"
+	"github.com/sykesm/cf-ssh-plugin/jump"
+	"github.com/sykesm/cf-ssh-plugin/keepalive"
+	"github.com/sykesm/cf-ssh-plugin/keyboard"
+	"github.com/sykesm/cf-ssh-plugin/logging"
 	"github.com/sykesm/cf-ssh-plugin/models/app"
 	"github.com/sykesm/cf-ssh-plugin/models/credential"
 	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/models/instances"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+	"github.com/sykesm/cf-ssh-plugin/models/target"
 	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/proxy"
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+	"github.com/sykesm/cf-ssh-plugin/report"
+	"github.com/sykesm/cf-ssh-plugin/signals"
+	"github.com/sykesm/cf-ssh-plugin/sigwinch"
+	"github.com/sykesm/cf-ssh-plugin/termmodes"
 )
 
 type SshPlugin struct {
-	AppFactory  app.AppFactory
-	InfoFactory info.InfoFactory
-	CredFactory credential.CredentialFactory
+	AppFactory          app.AppFactory
+	InfoFactory         info.InfoFactory
+	CredFactory         credential.CredentialFactory
+	TargetFactory       target.TargetFactory
+	ConfigTargetFactory target.TargetFactory
+	InstancesFactory    instances.InstancesFactory
+	PasscodeFactory     credential.PasscodeFactory
+	SpaceFactory        space.SpaceFactory
+	Logger              lager.Logger
+
+	exitCode int
 }
 
 func (c *SshPlugin) GetMetadata() plugin.PluginMetadata {
@@ -34,7 +68,35 @@ func (c *SshPlugin) GetMetadata() plugin.PluginMetadata {
 				Name:     "ssh",
 				HelpText: "ssh to an application container instance",
 				UsageDetails: plugin.Usage{
-					Usage: "cf ssh APP-NAME [-i instance]",
+					Usage: "cf ssh APP-NAME [--org org] [--space space] [--guid] [--process type] [-i index|random|least-loaded|all] [-L [bind_address:]port:host:hostport] [-N [--reconnect]] [-c command [--output text|json]] [-t | -tt | -T] [--connect-timeout seconds] [--handshake-timeout seconds] [--connect-attempts count] [--wait] [--keepalive-interval seconds] [--keepalive-count count] [--idle-timeout seconds] [--escape-char char] [-e KEY=VALUE] [--send-env PATTERN] [-A] [--ssh-endpoint host:port] [--ssh-fingerprint fingerprint] [--proxy url] [-J [user@]host[:port]] [-v | -vv]",
+				},
+			},
+			{
+				Name:     "ssh-tunnel",
+				HelpText: "manage named sets of port forwards to an application container instance, in the foreground or the background",
+				UsageDetails: plugin.Usage{
+					Usage: "cf ssh-tunnel save NAME APP-NAME [--org org] [--space space] [--guid] [--process type] [-i instance] -L [bind_address:]port:host:hostport...\n   cf ssh-tunnel up NAME\n   cf ssh-tunnel delete NAME\n   cf ssh-tunnel start NAME\n   cf ssh-tunnel list [--output text|json]\n   cf ssh-tunnel stop NAME",
+				},
+			},
+			{
+				Name:     "ssh-info",
+				HelpText: "show the SSH endpoint and whether SSH is enabled for an application container instance",
+				UsageDetails: plugin.Usage{
+					Usage: "cf ssh-info APP-NAME [--org org] [--space space] [--guid] [--process type] [-i index|random|least-loaded] [--ssh-endpoint host:port] [--ssh-fingerprint fingerprint] [--output text|json]",
+				},
+			},
+			{
+				Name:     "ssh-doctor",
+				HelpText: "check each step of connecting to an application container instance and suggest fixes",
+				UsageDetails: plugin.Usage{
+					Usage: "cf ssh-doctor APP-NAME [--org org] [--space space] [--guid] [--process type] [-i index|random|least-loaded] [--ssh-endpoint host:port] [--ssh-fingerprint fingerprint] [--proxy url] [-J [user@]host[:port]] [-v | -vv]",
+				},
+			},
+			{
+				Name:     "ssh-ping",
+				HelpText: "measure how long it takes to connect to an application container instance, and optionally the throughput",
+				UsageDetails: plugin.Usage{
+					Usage: "cf ssh-ping APP-NAME [--org org] [--space space] [--guid] [--process type] [-i index|random|least-loaded] [--count count] [--interval seconds] [--throughput size] [--ssh-endpoint host:port] [--ssh-fingerprint fingerprint] [--proxy url] [-J [user@]host[:port]] [-v | -vv]",
 				},
 			},
 		},
@@ -46,99 +108,731 @@ func main() {
 }
 
 func (c *SshPlugin) Run(cli plugin.CliConnection, args []string) {
+	c.Logger = lager.NewLogger("ssh")
+	cli = logging.NewCliConnection(cli, c.Logger)
+
 	c.AppFactory = app.NewAppFactory(cli)
 	c.InfoFactory = info.NewInfoFactory(cli)
 	c.CredFactory = credential.NewCredentialFactory(cli)
+	c.TargetFactory = target.NewTargetFactory(cli)
+	c.ConfigTargetFactory = target.NewConfigTargetFactory(target.ConfigPath())
+	c.InstancesFactory = instances.NewInstancesFactory(cli)
+	c.PasscodeFactory = credential.NewPasscodeFactory(cli)
+	c.SpaceFactory = space.NewSpaceFactory(cli)
+
+	switch args[0] {
+	case "ssh":
+		c.runSsh(cli, args[1:])
+	case "ssh-tunnel":
+		c.runTunnel(cli, args[1:])
+	case "ssh-info":
+		c.runInfo(cli, args[1:])
+	case "ssh-doctor":
+		c.runDoctor(cli, args[1:])
+	case "ssh-ping":
+		c.runPing(cli, args[1:])
+	}
+
+	if c.exitCode != 0 {
+		os.Exit(c.exitCode)
+	}
+}
+
+func (c *SshPlugin) runSsh(cli plugin.CliConnection, args []string) {
+	opts, err := c.parseOptions(args)
+	if err != nil {
+		c.usageFailed(args, "ssh", err, c.showUsage)
+		return
+	}
+
+	exec := opts.InstanceSelector == options.SelectAll || opts.Output == options.OutputJSON
+	if exec {
+		switch {
+		case opts.Command == "":
+			c.usageFailed(args, "ssh", errors.New("Flag 'output' requires -c"), c.showUsage)
+			return
+		case opts.TerminalRequest == options.RequestTTYYes || opts.TerminalRequest == options.RequestTTYForce:
+			c.usageFailed(args, "ssh", errors.New("A pty cannot be requested with --output json"), c.showUsage)
+			return
+		case len(opts.ForwardSpecs) > 0:
+			c.usageFailed(args, "ssh", errors.New("Only one of -L or --output json may be provided"), c.showUsage)
+			return
+		}
+	}
+
+	closeLog, err := logging.Configure(c.logger(), opts.Verbosity, os.Getenv("CF_SSH_TRACE"), os.Stderr)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+	defer closeLog()
+
+	err = c.useCredentials(opts.Config.Credentials, opts)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	if exec {
+		c.runExec(opts)
+		return
+	}
+
+	prompt := terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stdout.Fd()))
+	err = c.chooseInstance(opts, prompt)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	c.RunWithOptions(cli, opts)
+}
+
+// fail reports err and records the status the plugin exits with.
+func (c *SshPlugin) fail(err error) {
+	failures.Render(os.Stdout, err)
+	c.exitCode = failures.ExitCode(err)
+}
+
+// failWith reports err as a JSON document when output is json so stdout
+// stays parseable, and like fail otherwise.
+func (c *SshPlugin) failWith(output options.OutputFormat, err error) {
+	if output != options.OutputJSON {
+		c.fail(err)
+		return
+	}
+
+	report.Write(os.Stdout, report.NewFailure(err))
+	c.exitCode = failures.ExitCode(err)
+}
+
+// usageFailed reports arguments to command that could not be parsed: as a
+// JSON document when args ask for json output, and with the usage otherwise.
+func (c *SshPlugin) usageFailed(args []string, command string, err error, showUsage func()) {
+	if options.RequestedOutput(args) == options.OutputJSON {
+		c.failWith(options.OutputJSON, failures.InvalidUsage(err, command))
+		return
+	}
+
+	fmt.Println("Invalid usage:", err)
+	showUsage()
+}
+
+func (c *SshPlugin) parseOptions(args []string) (*options.Options, error) {
+	opts := &options.Options{}
+
+	err := c.loadDefaults(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = opts.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
 
-	if args[0] == "ssh" {
-		opts := &options.Options{}
-		err := opts.Parse(args[1:])
+// loadDefaults gives opts the configuration file and, when it has per-app
+// settings, the current target they are keyed by.
+func (c *SshPlugin) loadDefaults(opts *options.Options) error {
+	cfg, err := config.Load(config.DefaultPath())
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Apps) > 0 {
+		opts.Target, err = c.targetFactory(cfg.Credentials).Get()
 		if err != nil {
-			fmt.Println("Invalid usage:", err)
-			c.showUsage()
+			return err
 		}
 	}
+	opts.Config = cfg
+
+	return nil
 }
 
 func (c *SshPlugin) RunWithOptions(cli plugin.CliConnection, opts *options.Options) {
-	app, err := c.AppFactory.Get(opts.AppName)
+	keyring, closeKeyring, err := localKeyring(opts.ForwardAgent)
 	if err != nil {
-		fmt.Println(err)
+		c.fail(err)
 		return
 	}
+	defer closeKeyring()
 
-	info, err := c.InfoFactory.Get()
+	supervisor := c.newSupervisor(opts, keyring)
+
+	err = supervisor.Start()
 	if err != nil {
-		fmt.Println(err)
+		c.fail(err)
 		return
 	}
+	defer supervisor.Close()
 
-	cred, err := c.CredFactory.Get()
+	fwd, err := startForwarding(&loggingDialer{dialer: supervisor, logger: c.logger()}, opts.ForwardSpecs)
 	if err != nil {
-		fmt.Println(err)
+		c.fail(err)
 		return
 	}
+	defer fwd.Close()
 
-	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-		switch len(info.SSHEndpointFingerprint) {
-		case 0:
-			return nil
-		case helpers.SHA1_FINGERPRINT_LENGTH:
-			if helpers.SHA1Fingerprint(key) != info.SSHEndpointFingerprint {
-				return errors.New("Host fingerprint does not match")
-			}
-		case helpers.MD5_FINGERPRINT_LENGTH:
-			if helpers.MD5Fingerprint(key) != info.SSHEndpointFingerprint {
-				return errors.New("Host fingerprint does not match")
+	client, err := supervisor.Client()
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	// Only forwarding survives a lost connection. A shell or command cannot
+	// be resumed on a new connection, so those sessions end instead.
+	if opts.SkipRemoteExecution {
+		wait := client.Wait
+		if opts.Reconnect {
+			wait = supervisor.Run
+		}
+
+		err = waitForTermination(wait)
+		if err != nil && opts.Reconnect {
+			c.fail(err)
+		}
+		return
+	}
+
+	c.exitCode = exitStatus(c.interactiveSession(client, fwd, opts), opts.Verbosity)
+}
+
+// newSupervisor returns a supervisor that resolves the app, endpoint, and a
+// fresh credential each time it connects. When keyring is not nil, agent
+// channels opened by the remote end are served from it.
+func (c *SshPlugin) newSupervisor(opts *options.Options, keyring agent.Agent) *reconnect.Supervisor {
+	connector := func() (reconnect.Client, error) {
+		client, err := c.connect(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyring != nil {
+			err = agent.ForwardToAgent(client, keyring)
+			if err != nil {
+				client.Close()
+				return nil, err
 			}
-		default:
-			return errors.New("invalid fingerprint format")
 		}
-		return nil
+
+		stopKeepAlive := keepalive.Start(client, opts.KeepAliveInterval, opts.KeepAliveCountMax, func() {
+			fmt.Fprintf(os.Stderr, "\r\nNo reply to %d keepalives; disconnecting\r\n", opts.KeepAliveCountMax)
+			client.Close()
+		})
+		go func() {
+			client.Wait()
+			stopKeepAlive()
+		}()
+
+		return client, nil
+	}
+
+	return reconnect.New(connector, reconnect.DefaultBackoff, 0, failures.Permanent, os.Stdout)
+}
+
+// appRef identifies the app named on the command line.
+func appRef(opts *options.Options) app.Ref {
+	if opts.ByGuid {
+		return app.Ref{Guid: opts.AppName}
+	}
+	return app.Ref{Name: opts.AppName, Org: opts.Org, Space: opts.Space}
+}
+
+// connect resolves the app, endpoint, and credential for opts and returns an
+// authenticated client connection to the target instance.
+func (c *SshPlugin) connect(opts *options.Options) (*ssh.Client, error) {
+	app, err := c.AppFactory.Get(appRef(opts), opts.Process)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.Instances > 0 && opts.Instance >= app.Instances {
+		return nil, failures.InstanceOutOfRange(app.Name, opts.Instance, app.Instances)
+	}
+
+	info, err := c.endpointInfo(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := c.CredFactory.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	logger := c.logger().Session("connect", lager.Data{"app": opts.AppName, "instance": opts.Instance})
+
+	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		logger.Info("host-key", lager.Data{
+			"host":    hostname,
+			"address": remote.String(),
+			"type":    key.Type(),
+			"md5":     helpers.MD5Fingerprint(key),
+			"sha1":    helpers.SHA1Fingerprint(key),
+		})
+
+		return verifyHostKey(info.SSHEndpointFingerprint, key)
 	}
 	if opts.SkipHostValidation {
 		hostKeyCallback = nil
 	}
 
 	clientConfig := &ssh.ClientConfig{
-		User: fmt.Sprintf("cf:%s/%d", app.Guid, opts.Instance),
-		Auth: []ssh.AuthMethod{
-			ssh.Password(cred.Token),
-		},
+		User:            app.User(opts.Instance),
+		Auth:            c.authMethods(cred, logger),
 		HostKeyCallback: hostKeyCallback,
 	}
 
-	client, err := ssh.Dial("tcp", info.SSHEndpoint, clientConfig)
+	if opts.Wait {
+		err = c.waitForInstance(app, opts.Instance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var client *ssh.Client
+	attempts := opts.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	dialWithRetries := func() error {
+		return reconnect.Retry(attempts, reconnect.DefaultBackoff, os.Stderr, func() error {
+			client, err = dialEndpoint(opts, info.SSHEndpoint, clientConfig, logger)
+			return err
+		})
+	}
+
+	err = dialWithRetries()
+	if handshake.AuthRejected(err) {
+		// The token may have expired since it was fetched. The CLI refreshes
+		// it when asked again and cached tokens are replaced, so retry once
+		// with the new one.
+		logger.Info("retrying-with-new-credential")
+		cred, err = credential.Refresh(c.CredFactory)
+		if err != nil {
+			logger.Error("refreshing-credential-failed", err)
+			return nil, err
+		}
+		clientConfig.Auth = c.authMethods(cred, logger)
+
+		err = dialWithRetries()
+		if handshake.AuthRejected(err) {
+			err = c.diagnoseAuthFailure(app, err)
+		}
+	}
 	if err != nil {
-		fmt.Printf("FAILED\n%s\n", err.Error())
-		return
+		logger.Error("failed", err)
+		return nil, err
 	}
-	c.interactiveSession(client)
+
+	logger.Info("connected", lager.Data{"server-version": string(client.ServerVersion())})
+	return client, nil
 }
 
-func (c *SshPlugin) interactiveSession(client *ssh.Client) {
-	fmt.Println("1")
+// authMethods offers the token as a password and answers keyboard-interactive
+// prompts with the token, a one-time passcode, or the user's input. The
+// methods tried and the prompts received are logged; the answers never are.
+func (c *SshPlugin) authMethods(cred credential.Credential, logger lager.Logger) []ssh.AuthMethod {
+	responder := &keyboard.Responder{
+		Token: func() (string, error) {
+			return cred.Token, nil
+		},
+		Prompt: keyboard.TerminalPrompt(os.Stdin, os.Stderr),
+		Out:    os.Stderr,
+	}
+	if c.PasscodeFactory != nil {
+		responder.Passcode = c.PasscodeFactory.Get
+	}
+
+	password := func() (string, error) {
+		logger.Info("auth-method", lager.Data{"method": "password"})
+		return cred.Token, nil
+	}
+
+	challenge := func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		logger.Info("auth-method", lager.Data{
+			"method":      "keyboard-interactive",
+			"instruction": instruction,
+			"questions":   questions,
+		})
+		return responder.Challenge(user, instruction, questions, echos)
+	}
+
+	return []ssh.AuthMethod{
+		ssh.PasswordCallback(password),
+		ssh.KeyboardInteractive(challenge),
+	}
+}
+
+// verifyHostKey checks key against an MD5 or SHA1 fingerprint. An empty
+// fingerprint accepts any key.
+func verifyHostKey(fingerprint string, key ssh.PublicKey) error {
+	switch len(fingerprint) {
+	case 0:
+		return nil
+	case helpers.SHA1_FINGERPRINT_LENGTH:
+		if actual := helpers.SHA1Fingerprint(key); actual != fingerprint {
+			return failures.HostKeyMismatch(fingerprint, actual)
+		}
+	case helpers.MD5_FINGERPRINT_LENGTH:
+		if actual := helpers.MD5Fingerprint(key); actual != fingerprint {
+			return failures.HostKeyMismatch(fingerprint, actual)
+		}
+	default:
+		return errors.New("invalid fingerprint format")
+	}
+	return nil
+}
+
+// diagnoseAuthFailure explains why the endpoint rejected a fresh token. The
+// daemon refuses apps that are not on Diego or that have SSH disabled at the
+// app or space level the same way it refuses a bad token.
+func (c *SshPlugin) diagnoseAuthFailure(app app.App, cause error) error {
+	if !app.Diego {
+		return failures.NotDiego(app.Name)
+	}
+	if !app.EnableSSH {
+		return failures.SSHDisabled(app.Name)
+	}
+
+	if c.SpaceFactory != nil && app.SpaceGuid != "" {
+		space, err := c.SpaceFactory.Get(app.SpaceGuid)
+		if err == nil && !space.AllowSSH {
+			return failures.SpaceDisallowed(space.Name)
+		}
+	}
+
+	return failures.AuthRejected(cause)
+}
+
+// endpointInfo returns the SSH endpoint and fingerprint advertised by the
+// API, replaced by any override. /v2/info is skipped when both are set.
+func (c *SshPlugin) endpointInfo(opts *options.Options) (info.Info, error) {
+	if opts.SSHEndpoint != "" && opts.SSHFingerprint != "" {
+		return info.Info{
+			SSHEndpoint:            opts.SSHEndpoint,
+			SSHEndpointFingerprint: opts.SSHFingerprint,
+		}, nil
+	}
+
+	endpointInfo, err := c.InfoFactory.Get()
+	if err != nil {
+		return info.Info{}, err
+	}
+
+	if opts.SSHEndpoint != "" {
+		endpointInfo.SSHEndpoint = opts.SSHEndpoint
+	}
+	if opts.SSHFingerprint != "" {
+		endpointInfo.SSHEndpointFingerprint = opts.SSHFingerprint
+	}
+
+	return endpointInfo, nil
+}
+
+// dialEndpoint connects to the SSH endpoint through the configured proxy
+// and jump host, if any.
+func dialEndpoint(opts *options.Options, endpoint string, clientConfig *ssh.ClientConfig, logger lager.Logger) (*ssh.Client, error) {
+	dialer, closeBastion, err := endpointDialer(opts, endpoint, clientConfig.User, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dial(dialer, endpoint, clientConfig, opts.HandshakeTimeout)
+	if err != nil {
+		closeBastion()
+		return nil, err
+	}
+
+	go func() {
+		client.Wait()
+		closeBastion()
+	}()
+
+	return client, nil
+}
+
+// endpointDialer returns a dialer that reaches endpoint through the
+// configured proxy and jump host. The returned function closes the
+// connection to the jump host, if there is one.
+func endpointDialer(opts *options.Options, endpoint string, user string, logger lager.Logger) (proxy.DialFunc, func(), error) {
+	firstHop := firstHop(opts, endpoint)
+
+	logger.Info("dial", lager.Data{
+		"endpoint":  endpoint,
+		"proxy":     logging.Redact(opts.Proxy),
+		"jump-host": opts.JumpHost.Address,
+		"user":      user,
+	})
+
+	dialer, err := proxy.ForAddress(opts.Proxy, firstHop, opts.ConnectTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.JumpHost.Address == "" {
+		return dialer, func() {}, nil
+	}
+
+	bastion, err := jump.Connect(opts.JumpHost, dialer, opts.HandshakeTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bastion.Dial, func() { bastion.Close() }, nil
+}
+
+func startForwarding(dialer forwarder.Dialer, specs []options.ForwardSpec) (*forwarder.Forwarder, error) {
+	fwd := forwarder.New(dialer)
+
+	for _, spec := range specs {
+		_, err := fwd.Forward(spec)
+		if err != nil {
+			fwd.Close()
+			return nil, fmt.Errorf("Failed to forward %s: %s", spec, err)
+		}
+	}
+
+	return fwd, nil
+}
+
+// dial establishes an SSH connection to address. The handshake, including
+// authentication, must complete within handshakeTimeout when it is set.
+func dial(dialer proxy.DialFunc, address string, clientConfig *ssh.ClientConfig, handshakeTimeout time.Duration) (*ssh.Client, error) {
+	conn, err := dialer("tcp", address)
+	if err != nil {
+		return nil, failures.EndpointUnreachable(address, err)
+	}
+
+	if handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	}
+
+	sshConn, chans, reqs, err := handshake.NewClientConn(conn, address, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// interactiveSession runs the remote shell or command and returns the error
+// from waiting on it.
+func (c *SshPlugin) interactiveSession(client reconnect.Client, fwd *forwarder.Forwarder, opts *options.Options) error {
+	logger := c.logger().Session("session")
+
 	session, err := client.NewSession()
-	fmt.Println("1.5")
 	if err != nil {
-		fmt.Printf("Failed to allocate SSH session\n")
-		return
+		logger.Error("open-failed", err)
+		return errors.New("Failed to allocate SSH session")
 	}
 	defer session.Close()
+	logger.Debug("opened")
+
+	sendEnvironment(session, opts.Environment(os.Environ()), logger)
 
-	fmt.Println("2")
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          1,
-		ssh.TTY_OP_ISPEED: 115200,
-		ssh.TTY_OP_OSPEED: 115200,
+	if opts.ForwardAgent {
+		err = agent.RequestAgentForwarding(session)
+		logger.Debug("agent-forwarding-reply", lager.Data{"accepted": err == nil})
+		if err != nil {
+			return errors.New("Failed to request agent forwarding")
+		}
 	}
-	fmt.Println("3")
-	err = session.RequestPty("xterm", 80, 40, modes)
-	if err != nil {
-		fmt.Printf("Failed to request pty\n")
+
+	stdinFd := int(os.Stdin.Fd())
+	interactive := terminal.IsTerminal(stdinFd)
+	pty := false
+
+	if requestPty(opts, interactive) {
+		width, height := 80, 24
+		if interactive {
+			width, height, _ = terminal.GetSize(stdinFd)
+		}
+
+		modes := termmodes.DefaultModes()
+		if interactive {
+			modes = termmodes.Modes(stdinFd)
+		}
+		err = session.RequestPty(termmodes.Term(), height, width, modes)
+		logger.Debug("pty-reply", lager.Data{"term": termmodes.Term(), "width": width, "height": height, "accepted": err == nil})
+		if err != nil {
+			return errors.New("Failed to request pty")
+		}
+		pty = true
+
+		if interactive {
+			state, err := terminal.MakeRaw(stdinFd)
+			if err == nil {
+				defer terminal.Restore(stdinFd, state)
+			}
+			done := make(chan struct{})
+			defer close(done)
+			go resizeOnSigwinch(session, stdinFd, done)
+		}
+	}
+
+	activity := keepalive.NewActivity()
+	session.Stdin = activity.Reader(os.Stdin)
+	if pty && interactive && opts.EscapeChar != 0 {
+		handler := &escapeHandler{client: client, forwarder: fwd}
+		session.Stdin = escape.NewReader(session.Stdin, os.Stderr, opts.EscapeChar, handler)
+	}
+	session.Stdout = activity.Writer(os.Stdout)
+	session.Stderr = activity.Writer(os.Stderr)
+
+	// A command without a pty may be quiet for a long time on purpose, as a
+	// backup or a long query is, so only shells and pty sessions are
+	// disconnected when idle.
+	if pty || opts.Command == "" {
+		stopIdleWatch := keepalive.WatchIdle(activity, opts.IdleTimeout, func() {
+			fmt.Fprintf(os.Stderr, "\r\nDisconnecting after %s of inactivity\r\n", opts.IdleTimeout)
+			session.Close()
+		})
+		defer stopIdleWatch()
+	}
+
+	if !pty {
+		sigs := make(chan os.Signal, 4)
+		signal.Notify(sigs, signals.Forwarded...)
+		defer func() {
+			signal.Stop(sigs)
+			close(sigs)
+		}()
+
+		go signals.Relay(session, sigs, func() {
+			fmt.Fprintln(os.Stderr, "Interrupted twice; closing the connection")
+			client.Close()
+		})
+	}
+
+	if opts.Command != "" {
+		err = session.Start(opts.Command)
+		logger.Debug("exec-reply", lager.Data{"accepted": err == nil})
+		if err != nil {
+			return errors.New("Failed to run command")
+		}
+	} else {
+		err = session.Shell()
+		logger.Debug("shell-reply", lager.Data{"accepted": err == nil})
+		if err != nil {
+			return errors.New("Failed to start shell")
+		}
+	}
+
+	return session.Wait()
+}
+
+// requestPty follows ssh: a pty is requested for interactive shells, for
+// commands only when asked with -t, and always with -tt.
+func requestPty(opts *options.Options, interactive bool) bool {
+	switch opts.TerminalRequest {
+	case options.RequestTTYForce:
+		return true
+	case options.RequestTTYYes:
+		return interactive
+	case options.RequestTTYAuto:
+		return interactive && opts.Command == ""
+	default:
+		return false
+	}
+}
+
+// exitStatus returns the status the plugin should exit with. Like ssh, the
+// remote command's own status is passed through silently unless verbose.
+func exitStatus(err error, verbosity int) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *ssh.ExitError:
+		if verbosity > 0 {
+			if err.Signal() != "" {
+				fmt.Fprintf(os.Stderr, "Remote process terminated by signal %s\n", err.Signal())
+			} else {
+				fmt.Fprintf(os.Stderr, "Remote process exited with status %d\n", err.ExitStatus())
+			}
+		}
+		return err.ExitStatus()
+	case *ssh.ExitMissingError:
+		fmt.Fprintln(os.Stderr, "Connection closed without an exit status")
+		return 255
+	default:
+		failures.Render(os.Stdout, err)
+		return failures.ExitConnection
+	}
+}
+
+// sendEnvironment issues an env request for each variable. Daemons are free
+// to refuse them, so a refusal is reported but is not fatal.
+func sendEnvironment(session *ssh.Session, env map[string]string, logger lager.Logger) {
+	names := []string{}
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := session.Setenv(name, env[name])
+		logger.Debug("env-reply", lager.Data{"name": name, "accepted": err == nil})
+		if err != nil {
+			logger.Error("setenv-failed", err, lager.Data{"name": name})
+			fmt.Fprintf(os.Stderr, "Warning: remote refused environment variable %s: %s\n", name, err)
+		}
+	}
+}
+
+func resizeOnSigwinch(session *ssh.Session, fd int, done <-chan struct{}) {
+	if runtime.GOOS == "windows" {
 		return
 	}
-	fmt.Println("4")
+
+	resized := make(chan os.Signal, 16)
+	signal.Notify(resized, sigwinch.SIGWINCH())
+	defer signal.Stop(resized)
+
+	for {
+		select {
+		case <-resized:
+			width, height, err := terminal.GetSize(fd)
+			if err != nil {
+				continue
+			}
+			session.WindowChange(height, width)
+		case <-done:
+			return
+		}
+	}
+}
+
+// logger returns the plugin's logger, or one without sinks when the plugin
+// was constructed directly rather than through Run.
+func (c *SshPlugin) logger() lager.Logger {
+	if c.Logger == nil {
+		c.Logger = lager.NewLogger("ssh")
+	}
+	return c.Logger
+}
+
+// loggingDialer logs each channel opened for a forwarded connection.
+type loggingDialer struct {
+	dialer forwarder.Dialer
+	logger lager.Logger
+}
+
+func (d *loggingDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.dialer.Dial(network, address)
+	if err != nil {
+		d.logger.Error("forward-dial-failed", err, lager.Data{"address": address})
+		return nil, err
+	}
+	d.logger.Debug("forward-dial", lager.Data{"address": address})
+	return conn, nil
 }
 
 func (c *SshPlugin) showUsage() {
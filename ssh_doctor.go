@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/cloudfoundry-incubator/diego-ssh/helpers"
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/doctor"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/proxy"
+)
+
+// errHostKeyObserved aborts the handshake used to collect the host key
+// before any credentials are offered.
+var errHostKeyObserved = errors.New("host key observed")
+
+// doctorSetup holds what went wrong preparing to connect. The doctor reports
+// these as failed checks rather than stopping before its report.
+type doctorSetup struct {
+	defaultsErr    error
+	credentialsErr error
+	instanceErr    error
+}
+
+func (c *SshPlugin) runDoctor(cli plugin.CliConnection, args []string) {
+	var setup doctorSetup
+
+	opts := &options.Options{}
+	setup.defaultsErr = c.loadDefaults(opts)
+	if opts.Config == nil {
+		opts.Config = &config.Config{}
+	}
+
+	err := opts.Parse(args)
+	if err != nil {
+		fmt.Println("Invalid usage:", err)
+		c.showDoctorUsage()
+		return
+	}
+
+	closeLog, err := logging.Configure(c.logger(), opts.Verbosity, os.Getenv("CF_SSH_TRACE"), os.Stderr)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer closeLog()
+
+	setup.credentialsErr = c.useCredentials(opts.Config.Credentials, opts)
+	setup.instanceErr = c.chooseInstance(opts, false)
+
+	if setup.instanceErr != nil {
+		fmt.Printf("Checking SSH access to %s\n\n", opts.AppName)
+	} else {
+		fmt.Printf("Checking SSH access to %s instance %d\n\n", opts.AppName, opts.Instance)
+	}
+
+	results := doctor.Run(c.doctorChecks(opts, setup))
+	doctor.Print(os.Stdout, results)
+
+	fmt.Println()
+	if !doctor.Passed(results) {
+		fmt.Println("FAILED")
+		c.exitCode = failures.ExitFailure
+		return
+	}
+	fmt.Println("OK")
+}
+
+// doctorChecks follows a connection from the CLI to the instance. Checks
+// that need something an earlier check failed to provide are skipped.
+func (c *SshPlugin) doctorChecks(opts *options.Options, setup doctorSetup) []doctor.Check {
+	var (
+		endpointInfo *info.Info
+		appModel     *app.App
+	)
+
+	return []doctor.Check{
+		{
+			Name: "Configuration",
+			Hint: "Fix or remove the plugin configuration file.",
+			Run: func() (string, error) {
+				if setup.defaultsErr != nil {
+					return "", setup.defaultsErr
+				}
+				return config.DefaultPath(), nil
+			},
+		},
+		{
+			Name: "CLI target",
+			Hint: "Log in with 'cf login' and target an org and space with 'cf target -o ORG -s SPACE', or locate the app with --space or --guid.",
+			Run: func() (string, error) {
+				target, err := c.targetFactory(opts.Config.Credentials).Get()
+				if err != nil {
+					return "", err
+				}
+				if target.API == "" {
+					return "", errors.New("No API endpoint is targeted")
+				}
+
+				// The targeted org and space only matter when they are
+				// where the app is looked up.
+				switch {
+				case opts.ByGuid:
+					return fmt.Sprintf("%s, app located by GUID", target.API), nil
+				case opts.Space != "" && opts.Org != "":
+					return fmt.Sprintf("%s, app located in org %s, space %s", target.API, opts.Org, opts.Space), nil
+				case opts.Space != "":
+					return fmt.Sprintf("%s, app located in space %s", target.API, opts.Space), nil
+				case target.Org == "" || target.Space == "":
+					return "", fmt.Errorf("No org and space are targeted at %s", target.API)
+				}
+				return fmt.Sprintf("%s, org %s, space %s", target.API, target.Org, target.Space), nil
+			},
+		},
+		{
+			Name: "Access token",
+			Hint: "Log in again with 'cf login'.",
+			Run: func() (string, error) {
+				if setup.credentialsErr != nil {
+					return "", setup.credentialsErr
+				}
+
+				cred, err := c.CredFactory.Get()
+				if err != nil {
+					return "", err
+				}
+
+				expiresAt, ok := cred.ExpiresAt()
+				if !ok {
+					return "obtained", nil
+				}
+				if time.Now().After(expiresAt) {
+					return "", fmt.Errorf("The token expired at %s", expiresAt.Format(time.RFC3339))
+				}
+				return "valid until " + expiresAt.Format(time.RFC3339), nil
+			},
+		},
+		{
+			Name: "SSH endpoint info",
+			Hint: "SSH may not be enabled on this foundation; ask an operator, or pass --ssh-endpoint.",
+			Run: func() (string, error) {
+				i, err := c.endpointInfo(opts)
+				if err != nil {
+					return "", err
+				}
+				if i.SSHEndpoint == "" {
+					return "", errors.New("/v2/info does not advertise app_ssh_endpoint")
+				}
+				if _, _, err := net.SplitHostPort(i.SSHEndpoint); err != nil {
+					return "", fmt.Errorf("Invalid SSH endpoint %s", i.SSHEndpoint)
+				}
+				endpointInfo = &i
+
+				if i.SSHEndpointFingerprint == "" {
+					return i.SSHEndpoint + ", no host key fingerprint advertised", nil
+				}
+				return fmt.Sprintf("%s, fingerprint %s", i.SSHEndpoint, i.SSHEndpointFingerprint), nil
+			},
+		},
+		{
+			Name: "DNS",
+			Hint: "Check the resolver configuration, or reach the endpoint through --proxy or -J.",
+			Run: func() (string, error) {
+				if endpointInfo == nil {
+					return "", doctor.Skip("requires the SSH endpoint")
+				}
+				if opts.Proxy != "" {
+					return "", doctor.Skip("names are resolved by the proxy")
+				}
+
+				host, _, _ := net.SplitHostPort(firstHop(opts, endpointInfo.SSHEndpoint))
+				if net.ParseIP(host) != nil {
+					return host + " is an IP address", nil
+				}
+
+				addresses, err := net.LookupHost(host)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s resolves to %s", host, strings.Join(addresses, ", ")), nil
+			},
+		},
+		{
+			Name: "TCP connect",
+			Hint: "Check firewalls between here and the endpoint, or use --proxy or -J.",
+			Run: func() (string, error) {
+				if endpointInfo == nil {
+					return "", doctor.Skip("requires the SSH endpoint")
+				}
+
+				address := firstHop(opts, endpointInfo.SSHEndpoint)
+				dialer, err := proxy.ForAddress(opts.Proxy, address, opts.ConnectTimeout)
+				if err != nil {
+					return "", err
+				}
+
+				start := time.Now()
+				conn, err := dialer("tcp", address)
+				if err != nil {
+					return "", failures.EndpointUnreachable(address, err)
+				}
+				conn.Close()
+
+				return fmt.Sprintf("connected to %s in %s", address, time.Since(start).Round(time.Millisecond)), nil
+			},
+		},
+		{
+			Name: "Host key",
+			Run: func() (string, error) {
+				if endpointInfo == nil {
+					return "", doctor.Skip("requires the SSH endpoint")
+				}
+
+				var observed ssh.PublicKey
+				clientConfig := &ssh.ClientConfig{
+					User: "cf-ssh-doctor",
+					HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+						observed = key
+						return errHostKeyObserved
+					},
+				}
+
+				_, err := dialEndpoint(opts, endpointInfo.SSHEndpoint, clientConfig, c.logger())
+				if observed == nil {
+					return "", err
+				}
+
+				err = verifyHostKey(endpointInfo.SSHEndpointFingerprint, observed)
+				if err != nil {
+					return "", err
+				}
+
+				if endpointInfo.SSHEndpointFingerprint == "" {
+					return fmt.Sprintf("%s %s, not verified", observed.Type(), helpers.SHA1Fingerprint(observed)), nil
+				}
+				return fmt.Sprintf("%s matches", observed.Type()), nil
+			},
+		},
+		{
+			Name: "App",
+			Run: func() (string, error) {
+				a, err := c.AppFactory.Get(appRef(opts), opts.Process)
+				if err != nil {
+					return "", err
+				}
+				appModel = &a
+
+				switch {
+				case !a.Diego:
+					return "", failures.NotDiego(a.Name)
+				case !a.EnableSSH:
+					return "", failures.SSHDisabled(a.Name)
+				case a.State != "STARTED":
+					return "", failures.AppNotStarted(a.Name, a.State)
+				}
+				return fmt.Sprintf("%s, %s process %s, %s, running on Diego, SSH enabled", a.Guid, a.ProcessType, a.ProcessGuid, a.State), nil
+			},
+		},
+		{
+			Name: "Space",
+			Run: func() (string, error) {
+				if appModel == nil {
+					return "", doctor.Skip("requires the app")
+				}
+
+				space, err := c.SpaceFactory.Get(appModel.SpaceGuid)
+				if err != nil {
+					return "", err
+				}
+				if !space.AllowSSH {
+					return "", failures.SpaceDisallowed(space.Name)
+				}
+				return fmt.Sprintf("%s allows SSH", space.Name), nil
+			},
+		},
+		{
+			Name: "Instance",
+			Hint: "Check 'cf app' and 'cf logs' for why the instance is not running.",
+			Run: func() (string, error) {
+				if setup.instanceErr != nil {
+					return "", setup.instanceErr
+				}
+				if appModel == nil {
+					return "", doctor.Skip("requires the app")
+				}
+				if appModel.ProcessType != app.WebProcess {
+					if opts.Instance >= appModel.Instances {
+						return "", failures.InstanceOutOfRange(appModel.Name, opts.Instance, appModel.Instances)
+					}
+					return fmt.Sprintf("instance %d of %d; states are only reported for the web process", opts.Instance, appModel.Instances), nil
+				}
+
+				instances, err := c.InstancesFactory.Get(appModel.Guid)
+				if err != nil {
+					return "", err
+				}
+
+				for _, instance := range instances {
+					if instance.Index != opts.Instance {
+						continue
+					}
+					if instance.State != "RUNNING" {
+						return "", fmt.Errorf("Instance %d is %s", opts.Instance, instance.State)
+					}
+					return fmt.Sprintf("instance %d of %d is RUNNING", opts.Instance, len(instances)), nil
+				}
+
+				return "", failures.InstanceOutOfRange(appModel.Name, opts.Instance, len(instances))
+			},
+		},
+		{
+			Name: "SSH handshake",
+			Run: func() (string, error) {
+				if endpointInfo == nil || appModel == nil || setup.instanceErr != nil {
+					return "", doctor.Skip("requires the SSH endpoint, the app, and an instance")
+				}
+
+				handshakeOpts := *opts
+				handshakeOpts.ConnectAttempts = 1
+				handshakeOpts.Wait = false
+
+				start := time.Now()
+				client, err := c.connect(&handshakeOpts)
+				if err != nil {
+					return "", err
+				}
+				defer client.Close()
+
+				session, err := client.NewSession()
+				if err != nil {
+					return "", errors.New("Failed to allocate SSH session")
+				}
+				session.Close()
+
+				return fmt.Sprintf("authenticated as %s and opened a session in %s", appModel.User(opts.Instance), time.Since(start).Round(time.Millisecond)), nil
+			},
+		},
+	}
+}
+
+// firstHop is the address the plugin dials directly: the jump host when
+// there is one, otherwise the endpoint.
+func firstHop(opts *options.Options, endpoint string) string {
+	if opts.JumpHost.Address != "" {
+		return opts.JumpHost.Address
+	}
+	return endpoint
+}
+
+func (c *SshPlugin) showDoctorUsage() {
+	fmt.Println("NAME:")
+	fmt.Println("   ssh-doctor")
+	fmt.Println("USAGE:")
+	fmt.Println("   " + c.GetMetadata().Commands[3].UsageDetails.Usage)
+}
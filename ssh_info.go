@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/report"
+)
+
+// runInfo describes what cf ssh would connect to without connecting: the
+// resolved app and instance, whether SSH is enabled, and the endpoint.
+func (c *SshPlugin) runInfo(cli plugin.CliConnection, args []string) {
+	opts := &options.Options{}
+	err := c.loadDefaults(opts)
+	if err != nil {
+		c.failWith(options.RequestedOutput(args), err)
+		return
+	}
+
+	err = opts.ParseInfo(args)
+	if err != nil {
+		c.usageFailed(args, "ssh-info", err, c.showInfoUsage)
+		return
+	}
+
+	closeLog, err := logging.Configure(c.logger(), opts.Verbosity, os.Getenv("CF_SSH_TRACE"), os.Stderr)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+	defer closeLog()
+
+	err = c.useCredentials(opts.Config.Credentials, opts)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	err = c.chooseInstance(opts, false)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	sshInfo, err := c.sshInfo(opts)
+	if err != nil {
+		c.failWith(opts.Output, err)
+		return
+	}
+
+	if opts.Output == options.OutputJSON {
+		err = report.Write(os.Stdout, sshInfo)
+		if err != nil {
+			c.failWith(opts.Output, err)
+		}
+		return
+	}
+
+	enabled := "enabled"
+	switch {
+	case !sshInfo.App.Diego:
+		enabled = "disabled (app is not running on Diego)"
+	case !sshInfo.App.EnableSSH:
+		enabled = "disabled for the app"
+	case !sshInfo.Space.AllowSSH:
+		enabled = "disabled in space " + sshInfo.Space.Name
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	fmt.Fprintf(table, "app:\t%s (%s)\n", sshInfo.App.Name, sshInfo.App.Guid)
+	fmt.Fprintf(table, "process:\t%s (%s)\n", sshInfo.App.ProcessType, sshInfo.App.ProcessGuid)
+	fmt.Fprintf(table, "state:\t%s\n", sshInfo.App.State)
+	fmt.Fprintf(table, "instance:\t%d of %d\n", sshInfo.Instance, sshInfo.App.Instances)
+	fmt.Fprintf(table, "ssh:\t%s\n", enabled)
+	fmt.Fprintf(table, "user:\t%s\n", sshInfo.User)
+	fmt.Fprintf(table, "endpoint:\t%s\n", sshInfo.Endpoint.Address)
+	fmt.Fprintf(table, "fingerprint:\t%s\n", sshInfo.Endpoint.HostKeyFingerprint)
+	table.Flush()
+}
+
+func (c *SshPlugin) sshInfo(opts *options.Options) (report.SSHInfo, error) {
+	app, err := c.AppFactory.Get(appRef(opts), opts.Process)
+	if err != nil {
+		return report.SSHInfo{}, err
+	}
+
+	if app.Instances > 0 && opts.Instance >= app.Instances {
+		return report.SSHInfo{}, failures.InstanceOutOfRange(app.Name, opts.Instance, app.Instances)
+	}
+
+	space, err := c.SpaceFactory.Get(app.SpaceGuid)
+	if err != nil {
+		return report.SSHInfo{}, err
+	}
+
+	endpointInfo, err := c.endpointInfo(opts)
+	if err != nil {
+		return report.SSHInfo{}, err
+	}
+
+	return report.NewSSHInfo(app, space, opts.Instance, endpointInfo), nil
+}
+
+func (c *SshPlugin) showInfoUsage() {
+	fmt.Println("NAME:")
+	fmt.Println("   ssh-info")
+	fmt.Println("USAGE:")
+	fmt.Println("   " + c.GetMetadata().Commands[2].UsageDetails.Usage)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/failures"
+	"github.com/sykesm/cf-ssh-plugin/handshake"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+	"github.com/sykesm/cf-ssh-plugin/models/info"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/ping"
+)
+
+func (c *SshPlugin) runPing(cli plugin.CliConnection, args []string) {
+	opts := &options.PingOptions{}
+
+	err := c.loadDefaults(&opts.Options)
+	if err == nil {
+		err = opts.Parse(args)
+	}
+	if err != nil {
+		fmt.Println("Invalid usage:", err)
+		c.showPingUsage()
+		return
+	}
+
+	closeLog, err := logging.Configure(c.logger(), opts.Verbosity, os.Getenv("CF_SSH_TRACE"), os.Stderr)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer closeLog()
+
+	err = c.useCredentials(opts.Config.Credentials, &opts.Options)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	err = c.chooseInstance(&opts.Options, false)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	samples, err := c.ping(opts)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	// Like ping, fail only when nothing got through.
+	for _, sample := range samples {
+		if sample.Err == nil {
+			return
+		}
+	}
+	c.exitCode = failures.ExitCode(samples[len(samples)-1].Err)
+}
+
+// ping opens opts.Count sessions to the instance, one every opts.Interval,
+// printing each sample as it is taken and a summary at the end. An
+// interrupt stops sampling early.
+func (c *SshPlugin) ping(opts *options.PingOptions) ([]ping.Sample, error) {
+	app, err := c.AppFactory.Get(appRef(&opts.Options), opts.Process)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.Instances > 0 && opts.Instance >= app.Instances {
+		return nil, failures.InstanceOutOfRange(app.Name, opts.Instance, app.Instances)
+	}
+
+	endpointInfo, err := c.endpointInfo(&opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := c.CredFactory.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	user := app.User(opts.Instance)
+	auth := c.authMethods(cred, c.logger().Session("ping"))
+	title := fmt.Sprintf("%s/%d", app.Name, opts.Instance)
+
+	fmt.Printf("SSH PING %s via %s\n", title, endpointInfo.SSHEndpoint)
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	samples := []ping.Sample{}
+sampling:
+	for seq := 1; seq <= opts.Count; seq++ {
+		if seq > 1 {
+			select {
+			case <-interrupted:
+				break sampling
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		sample := c.pingOnce(opts, endpointInfo, user, auth)
+		sample.Seq = seq
+		samples = append(samples, sample)
+		ping.PrintSample(os.Stdout, sample)
+	}
+
+	fmt.Println()
+	ping.PrintSummary(os.Stdout, title, samples)
+
+	return samples, nil
+}
+
+// pingOnce opens and closes a session, timing the TCP connect, the key
+// exchange up to host key verification, authentication, and opening the
+// session channel. With opts.ThroughputBytes set, it also streams that much
+// data to the instance.
+func (c *SshPlugin) pingOnce(opts *options.PingOptions, endpointInfo info.Info, user string, auth []ssh.AuthMethod) ping.Sample {
+	var sample ping.Sample
+	endpoint := endpointInfo.SSHEndpoint
+
+	dialer, closeBastion, err := endpointDialer(&opts.Options, endpoint, user, c.logger())
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	defer closeBastion()
+
+	start := time.Now()
+	conn, err := dialer("tcp", endpoint)
+	if err != nil {
+		sample.Err = failures.EndpointUnreachable(endpoint, err)
+		return sample
+	}
+	connected := time.Now()
+	sample.Connect = connected.Sub(start)
+
+	if opts.HandshakeTimeout > 0 {
+		conn.SetDeadline(connected.Add(opts.HandshakeTimeout))
+	}
+
+	var keyVerified time.Time
+	clientConfig := &ssh.ClientConfig{
+		User: user,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			keyVerified = time.Now()
+			if opts.SkipHostValidation {
+				return nil
+			}
+			return verifyHostKey(endpointInfo.SSHEndpointFingerprint, key)
+		},
+	}
+
+	sshConn, chans, reqs, err := handshake.NewClientConn(conn, endpoint, clientConfig)
+	if err != nil {
+		conn.Close()
+		if handshake.AuthRejected(err) {
+			err = failures.AuthRejected(err)
+		}
+		sample.Err = err
+		return sample
+	}
+	authenticated := time.Now()
+	conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	sample.Handshake = keyVerified.Sub(connected)
+	sample.Auth = authenticated.Sub(keyVerified)
+
+	session, err := client.NewSession()
+	if err != nil {
+		sample.Err = errors.New("Failed to allocate SSH session")
+		return sample
+	}
+	defer session.Close()
+	sample.ChannelOpen = time.Since(authenticated)
+
+	if opts.ThroughputBytes > 0 {
+		sample.BytesPerSecond, sample.Err = measureThroughput(session, opts.ThroughputBytes)
+	}
+
+	return sample
+}
+
+// measureThroughput streams size bytes into `cat > /dev/null` and returns
+// the rate at which the remote end consumed them.
+func measureThroughput(session *ssh.Session, size int64) (float64, error) {
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	err = session.Start("cat > /dev/null")
+	if err != nil {
+		return 0, errors.New("Failed to run command")
+	}
+
+	start := time.Now()
+
+	chunk := make([]byte, 32*1024)
+	for remaining := size; remaining > 0; {
+		n := int64(len(chunk))
+		if remaining < n {
+			n = remaining
+		}
+		_, err = stdin.Write(chunk[:n])
+		if err != nil {
+			return 0, err
+		}
+		remaining -= n
+	}
+	stdin.Close()
+
+	err = session.Wait()
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return float64(size) / time.Since(start).Seconds(), nil
+}
+
+func (c *SshPlugin) showPingUsage() {
+	fmt.Println("NAME:")
+	fmt.Println("   ssh-ping")
+	fmt.Println("USAGE:")
+	fmt.Println("   " + c.GetMetadata().Commands[4].UsageDetails.Usage)
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/sykesm/cf-ssh-plugin/models/credential/credential_fakes"
 	"github.com/sykesm/cf-ssh-plugin/models/info"
 	"github.com/sykesm/cf-ssh-plugin/models/info/info_fakes"
+	"github.com/sykesm/cf-ssh-plugin/models/space"
+	"github.com/sykesm/cf-ssh-plugin/models/space/space_fakes"
 	"github.com/sykesm/cf-ssh-plugin/options"
 	"golang.org/x/crypto/ssh"
 
@@ -27,6 +29,7 @@ import (
 	. "github.com/cloudfoundry/cli/testhelpers/matchers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("DiegoSsh", func() {
@@ -177,8 +180,20 @@ var _ = Describe("DiegoSsh", func() {
 
 				fakeChannelHandler = &fake_handlers.FakeNewChannelHandler{}
 				fakeChannelHandler.HandleNewChannelStub = func(logger lager.Logger, newChannel ssh.NewChannel) {
-					// newChannel.Reject(ssh.Prohibited, "not now")
-					newChannel.Accept()
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						return
+					}
+
+					go func() {
+						for req := range requests {
+							req.Reply(true, nil)
+							if req.Type == "shell" {
+								channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+								channel.Close()
+							}
+						}
+					}()
 				}
 				fakeChannelHandlers = map[string]handlers.NewChannelHandler{
 					"session": fakeChannelHandler,
@@ -317,6 +332,168 @@ var _ = Describe("DiegoSsh", func() {
 				})
 			})
 
+			Context("when the endpoint rejects the credential", func() {
+				var (
+					testLogger       *lagertest.TestLogger
+					fakeSpaceFactory *space_fakes.FakeSpaceFactory
+					refreshErr       error
+				)
+
+				BeforeEach(func() {
+					sshInfo.SSHEndpointFingerprint = ""
+
+					testLogger = lagertest.NewTestLogger("ssh")
+					callCliCommandPlugin.Logger = testLogger
+
+					fakeSpaceFactory = &space_fakes.FakeSpaceFactory{}
+					fakeSpaceFactory.GetReturns(space.Space{Guid: "space-guid", Name: "development", AllowSSH: true}, nil)
+					callCliCommandPlugin.SpaceFactory = fakeSpaceFactory
+
+					fakeAppFactory.GetReturns(app.App{
+						Name:        "app1",
+						Guid:        "app-guid",
+						SpaceGuid:   "space-guid",
+						ProcessType: app.WebProcess,
+						ProcessGuid: "app-guid",
+						EnableSSH:   true,
+						Diego:       true,
+						State:       "STARTED",
+					}, nil)
+
+					refreshErr = nil
+					fakeCredFactory.GetStub = func() (credential.Credential, error) {
+						if fakeCredFactory.GetCallCount() == 1 {
+							return credential.Credential{Token: "bearer expired"}, nil
+						}
+						if refreshErr != nil {
+							return credential.Credential{}, refreshErr
+						}
+						return credential.Credential{Token: "bearer refreshed"}, nil
+					}
+
+					daemonAuthenticator.AuthenticateStub = func(metadata ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+						if string(password) == "bearer refreshed" {
+							return &ssh.Permissions{}, nil
+						}
+						return nil, errors.New("token rejected")
+					}
+				})
+
+				Context("when the refreshed token is accepted", func() {
+					It("retries once with a new credential", func() {
+						Expect(fakeCredFactory.GetCallCount()).To(Equal(2))
+						Expect(daemonAuthenticator.AuthenticateCallCount()).To(Equal(2))
+
+						_, password := daemonAuthenticator.AuthenticateArgsForCall(0)
+						Expect(password).To(BeEquivalentTo("bearer expired"))
+						_, password = daemonAuthenticator.AuthenticateArgsForCall(1)
+						Expect(password).To(BeEquivalentTo("bearer refreshed"))
+
+						Expect(testLogger).To(gbytes.Say("retrying-with-new-credential"))
+						Expect(fakeChannelHandler.HandleNewChannelCallCount()).To(Equal(1))
+					})
+				})
+
+				Context("when the refreshed token is rejected as well", func() {
+					BeforeEach(func() {
+						daemonAuthenticator.AuthenticateReturns(nil, errors.New("token rejected"))
+						daemonAuthenticator.AuthenticateStub = nil
+					})
+
+					It("does not retry again", func() {
+						Expect(fakeCredFactory.GetCallCount()).To(Equal(2))
+						Expect(daemonAuthenticator.AuthenticateCallCount()).To(Equal(2))
+						Expect(fakeChannelHandler.HandleNewChannelCallCount()).To(Equal(0))
+					})
+
+					It("reports the authentication failure", func() {
+						Expect(testLogger).To(gbytes.Say("Authentication with the SSH endpoint failed"))
+					})
+				})
+
+				Context("when the refresh requires a new login", func() {
+					BeforeEach(func() {
+						refreshErr = credential.ErrLoginRequired
+					})
+
+					It("reports that the session has expired", func() {
+						Expect(fakeCredFactory.GetCallCount()).To(Equal(2))
+						Expect(daemonAuthenticator.AuthenticateCallCount()).To(Equal(1))
+						Expect(testLogger).To(gbytes.Say("Your session has expired"))
+					})
+				})
+			})
+
+			Describe("diagnosing a rejected credential", func() {
+				var (
+					testLogger       *lagertest.TestLogger
+					fakeSpaceFactory *space_fakes.FakeSpaceFactory
+					rejectedApp      app.App
+				)
+
+				BeforeEach(func() {
+					sshInfo.SSHEndpointFingerprint = ""
+
+					testLogger = lagertest.NewTestLogger("ssh")
+					callCliCommandPlugin.Logger = testLogger
+
+					fakeSpaceFactory = &space_fakes.FakeSpaceFactory{}
+					fakeSpaceFactory.GetReturns(space.Space{Guid: "space-guid", Name: "development", AllowSSH: true}, nil)
+					callCliCommandPlugin.SpaceFactory = fakeSpaceFactory
+
+					rejectedApp = app.App{
+						Name:        "app1",
+						Guid:        "app-guid",
+						SpaceGuid:   "space-guid",
+						ProcessGuid: "app-guid",
+						Diego:       true,
+						EnableSSH:   true,
+					}
+					fakeAppFactory.GetStub = func(app.Ref, string) (app.App, error) {
+						return rejectedApp, nil
+					}
+
+					daemonAuthenticator.AuthenticateReturns(nil, errors.New("rejected"))
+				})
+
+				Context("when the app is not running on Diego", func() {
+					BeforeEach(func() {
+						rejectedApp.Diego = false
+					})
+
+					It("reports it", func() {
+						Expect(testLogger).To(gbytes.Say("App app1 is not running on Diego"))
+					})
+				})
+
+				Context("when SSH is disabled for the app", func() {
+					BeforeEach(func() {
+						rejectedApp.EnableSSH = false
+					})
+
+					It("reports it", func() {
+						Expect(testLogger).To(gbytes.Say("SSH is disabled for app app1"))
+					})
+				})
+
+				Context("when SSH is disabled in the space", func() {
+					BeforeEach(func() {
+						fakeSpaceFactory.GetReturns(space.Space{Guid: "space-guid", Name: "development"}, nil)
+					})
+
+					It("reports it", func() {
+						Expect(fakeSpaceFactory.GetArgsForCall(0)).To(Equal("space-guid"))
+						Expect(testLogger).To(gbytes.Say("SSH is disabled in space development"))
+					})
+				})
+
+				Context("when SSH is allowed everywhere", func() {
+					It("reports that authentication failed", func() {
+						Expect(testLogger).To(gbytes.Say("Authentication with the SSH endpoint failed"))
+					})
+				})
+			})
+
 			FContext("when authentication is successful", func() {
 				BeforeEach(func() {
 					fmt.Println("down beforeeach")
@@ -0,0 +1,123 @@
+// +build linux darwin
+
+package termmodes
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type flagMode struct {
+	opcode uint8
+	mask   uint64
+}
+
+var controlChars = map[uint8]int{
+	ssh.VINTR:    syscall.VINTR,
+	ssh.VQUIT:    syscall.VQUIT,
+	ssh.VERASE:   syscall.VERASE,
+	ssh.VKILL:    syscall.VKILL,
+	ssh.VEOF:     syscall.VEOF,
+	ssh.VEOL:     syscall.VEOL,
+	ssh.VEOL2:    syscall.VEOL2,
+	ssh.VSTART:   syscall.VSTART,
+	ssh.VSTOP:    syscall.VSTOP,
+	ssh.VSUSP:    syscall.VSUSP,
+	ssh.VREPRINT: syscall.VREPRINT,
+	ssh.VWERASE:  syscall.VWERASE,
+	ssh.VLNEXT:   syscall.VLNEXT,
+	ssh.VDISCARD: syscall.VDISCARD,
+}
+
+var inputFlags = []flagMode{
+	{ssh.IGNPAR, syscall.IGNPAR},
+	{ssh.PARMRK, syscall.PARMRK},
+	{ssh.INPCK, syscall.INPCK},
+	{ssh.ISTRIP, syscall.ISTRIP},
+	{ssh.INLCR, syscall.INLCR},
+	{ssh.IGNCR, syscall.IGNCR},
+	{ssh.ICRNL, syscall.ICRNL},
+	{ssh.IXON, syscall.IXON},
+	{ssh.IXANY, syscall.IXANY},
+	{ssh.IXOFF, syscall.IXOFF},
+	{ssh.IMAXBEL, syscall.IMAXBEL},
+	{ssh.IUTF8, syscall.IUTF8},
+}
+
+var localFlags = []flagMode{
+	{ssh.ISIG, syscall.ISIG},
+	{ssh.ICANON, syscall.ICANON},
+	{ssh.ECHO, syscall.ECHO},
+	{ssh.ECHOE, syscall.ECHOE},
+	{ssh.ECHOK, syscall.ECHOK},
+	{ssh.ECHONL, syscall.ECHONL},
+	{ssh.NOFLSH, syscall.NOFLSH},
+	{ssh.TOSTOP, syscall.TOSTOP},
+	{ssh.IEXTEN, syscall.IEXTEN},
+	{ssh.ECHOCTL, syscall.ECHOCTL},
+	{ssh.ECHOKE, syscall.ECHOKE},
+	{ssh.PENDIN, syscall.PENDIN},
+}
+
+var outputFlags = []flagMode{
+	{ssh.OPOST, syscall.OPOST},
+	{ssh.ONLCR, syscall.ONLCR},
+	{ssh.OCRNL, syscall.OCRNL},
+	{ssh.ONOCR, syscall.ONOCR},
+	{ssh.ONLRET, syscall.ONLRET},
+}
+
+var controlFlags = []flagMode{
+	{ssh.CS7, syscall.CS7},
+	{ssh.CS8, syscall.CS8},
+	{ssh.PARENB, syscall.PARENB},
+	{ssh.PARODD, syscall.PARODD},
+}
+
+// Modes describes the settings of the terminal open on fd so the remote
+// pty can match them. DefaultModes is returned if fd is not a terminal.
+func Modes(fd int) ssh.TerminalModes {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), ioctlReadTermios, uintptr(unsafe.Pointer(&termios)), 0, 0, 0)
+	if errno != 0 {
+		return DefaultModes()
+	}
+
+	modes := ssh.TerminalModes{}
+	for opcode, index := range controlChars {
+		modes[opcode] = uint32(termios.Cc[index])
+	}
+	for opcode, index := range extraControlChars {
+		modes[opcode] = uint32(termios.Cc[index])
+	}
+
+	setFlags(modes, uint64(termios.Iflag), inputFlags)
+	setFlags(modes, uint64(termios.Iflag), extraInputFlags)
+	setFlags(modes, uint64(termios.Lflag), localFlags)
+	setFlags(modes, uint64(termios.Lflag), extraLocalFlags)
+	setFlags(modes, uint64(termios.Oflag), outputFlags)
+	setFlags(modes, uint64(termios.Oflag), extraOutputFlags)
+	setFlags(modes, uint64(termios.Cflag), controlFlags)
+
+	ispeed, ospeed := speeds(&termios)
+	if ispeed != 0 {
+		modes[ssh.TTY_OP_ISPEED] = ispeed
+	}
+	if ospeed != 0 {
+		modes[ssh.TTY_OP_OSPEED] = ospeed
+	}
+
+	return modes
+}
+
+func setFlags(modes ssh.TerminalModes, value uint64, flags []flagMode) {
+	for _, flag := range flags {
+		if value&flag.mask == flag.mask {
+			modes[flag.opcode] = 1
+		} else {
+			modes[flag.opcode] = 0
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package termmodes
+
+import (
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const ioctlReadTermios = syscall.TIOCGETA
+
+var extraControlChars = map[uint8]int{
+	ssh.VDSUSP:  syscall.VDSUSP,
+	ssh.VSTATUS: syscall.VSTATUS,
+}
+
+var extraInputFlags = []flagMode{}
+
+var extraLocalFlags = []flagMode{}
+
+var extraOutputFlags = []flagMode{}
+
+func speeds(termios *syscall.Termios) (uint32, uint32) {
+	return uint32(termios.Ispeed), uint32(termios.Ospeed)
+}
@@ -0,0 +1,66 @@
+package termmodes
+
+import (
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const ioctlReadTermios = syscall.TCGETS
+
+// cbaud masks the speed bits of c_cflag.
+const cbaud = 0010017
+
+var extraControlChars = map[uint8]int{}
+
+var extraInputFlags = []flagMode{
+	{ssh.IUCLC, syscall.IUCLC},
+}
+
+var extraLocalFlags = []flagMode{
+	{ssh.XCASE, syscall.XCASE},
+}
+
+var extraOutputFlags = []flagMode{
+	{ssh.OLCUC, syscall.OLCUC},
+}
+
+var baudRates = map[uint32]uint32{
+	syscall.B50:      50,
+	syscall.B75:      75,
+	syscall.B110:     110,
+	syscall.B134:     134,
+	syscall.B150:     150,
+	syscall.B200:     200,
+	syscall.B300:     300,
+	syscall.B600:     600,
+	syscall.B1200:    1200,
+	syscall.B1800:    1800,
+	syscall.B2400:    2400,
+	syscall.B4800:    4800,
+	syscall.B9600:    9600,
+	syscall.B19200:   19200,
+	syscall.B38400:   38400,
+	syscall.B57600:   57600,
+	syscall.B115200:  115200,
+	syscall.B230400:  230400,
+	syscall.B460800:  460800,
+	syscall.B500000:  500000,
+	syscall.B576000:  576000,
+	syscall.B921600:  921600,
+	syscall.B1000000: 1000000,
+	syscall.B1152000: 1152000,
+	syscall.B1500000: 1500000,
+	syscall.B2000000: 2000000,
+	syscall.B2500000: 2500000,
+	syscall.B3000000: 3000000,
+	syscall.B3500000: 3500000,
+	syscall.B4000000: 4000000,
+}
+
+// speeds decodes the line speed from c_cflag. Linux does not fill in the
+// separate speed fields for TCGETS, and input and output always match.
+func speeds(termios *syscall.Termios) (uint32, uint32) {
+	speed := baudRates[termios.Cflag&cbaud]
+	return speed, speed
+}
@@ -0,0 +1,10 @@
+// +build !linux,!darwin
+
+package termmodes
+
+import "golang.org/x/crypto/ssh"
+
+// Modes returns DefaultModes on platforms without termios support.
+func Modes(fd int) ssh.TerminalModes {
+	return DefaultModes()
+}
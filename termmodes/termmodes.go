@@ -0,0 +1,26 @@
+package termmodes
+
+import (
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const DefaultTerm = "xterm"
+
+// Term returns the terminal type to request for a remote pty.
+func Term() string {
+	if term := os.Getenv("TERM"); term != "" {
+		return term
+	}
+	return DefaultTerm
+}
+
+// DefaultModes are used when the local terminal settings can't be read.
+func DefaultModes() ssh.TerminalModes {
+	return ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 115200,
+		ssh.TTY_OP_OSPEED: 115200,
+	}
+}
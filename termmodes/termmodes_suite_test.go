@@ -0,0 +1,13 @@
+package termmodes_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTermmodes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Termmodes Suite")
+}
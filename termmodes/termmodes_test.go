@@ -0,0 +1,57 @@
+package termmodes_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/sykesm/cf-ssh-plugin/termmodes"
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Termmodes", func() {
+	Describe("Term", func() {
+		var savedTerm string
+
+		BeforeEach(func() {
+			savedTerm = os.Getenv("TERM")
+		})
+
+		AfterEach(func() {
+			os.Setenv("TERM", savedTerm)
+		})
+
+		It("uses the local terminal type", func() {
+			os.Setenv("TERM", "screen-256color")
+			Expect(termmodes.Term()).To(Equal("screen-256color"))
+		})
+
+		It("defaults to xterm", func() {
+			os.Setenv("TERM", "")
+			Expect(termmodes.Term()).To(Equal("xterm"))
+		})
+	})
+
+	Describe("Modes", func() {
+		It("returns the defaults when the descriptor is not a terminal", func() {
+			file, err := ioutil.TempFile("", "termmodes")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(file.Name())
+			defer file.Close()
+
+			Expect(termmodes.Modes(int(file.Fd()))).To(Equal(termmodes.DefaultModes()))
+		})
+	})
+
+	Describe("DefaultModes", func() {
+		It("enables echo at 115200 baud", func() {
+			Expect(termmodes.DefaultModes()).To(Equal(ssh.TerminalModes{
+				ssh.ECHO:          1,
+				ssh.TTY_OP_ISPEED: 115200,
+				ssh.TTY_OP_OSPEED: 115200,
+			}))
+		})
+	})
+})
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/sykesm/cf-ssh-plugin/config"
+	"github.com/sykesm/cf-ssh-plugin/forwarder"
+	"github.com/sykesm/cf-ssh-plugin/logging"
+	"github.com/sykesm/cf-ssh-plugin/options"
+	"github.com/sykesm/cf-ssh-plugin/reconnect"
+	"github.com/sykesm/cf-ssh-plugin/report"
+	"github.com/sykesm/cf-ssh-plugin/tunnel"
+)
+
+// managedTunnelEnv is set in the environment of the detached `cf ssh-tunnel
+// up` started by `cf ssh-tunnel start` so it maintains a state file.
+const managedTunnelEnv = "CF_SSH_TUNNEL_MANAGED"
+
+const (
+	tunnelStartTimeout = 60 * time.Second
+	tunnelStopTimeout  = 10 * time.Second
+)
+
+func (c *SshPlugin) runTunnel(cli plugin.CliConnection, args []string) {
+	configPath := config.DefaultPath()
+
+	closeLog, err := logging.Configure(c.logger(), 0, os.Getenv("CF_SSH_TRACE"), os.Stderr)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer closeLog()
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		c.failWith(options.RequestedOutput(args), err)
+		return
+	}
+
+	opts := &options.TunnelOptions{Config: cfg}
+	if len(args) > 0 && args[0] == options.TunnelUp && len(cfg.Apps) > 0 {
+		opts.Target, err = c.targetFactory(cfg.Credentials).Get()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+
+	err = opts.Parse(args)
+	if err != nil {
+		c.usageFailed(args, "ssh-tunnel", err, c.showTunnelUsage)
+		return
+	}
+
+	store := tunnel.NewStore(filepath.Join(config.PluginDir(), "ssh-tunnels"))
+
+	switch opts.Action {
+	case options.TunnelSave:
+		if cfg.Tunnels == nil {
+			cfg.Tunnels = map[string]config.Tunnel{}
+		}
+		cfg.Tunnels[opts.Name] = opts.Profile()
+
+		err = cfg.SaveTunnels(configPath)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		fmt.Printf("Saved tunnel %s\n", opts.Name)
+
+	case options.TunnelDelete:
+		if _, ok := cfg.Tunnels[opts.Name]; !ok {
+			c.fail(fmt.Errorf("Tunnel %s not found", opts.Name))
+			return
+		}
+		delete(cfg.Tunnels, opts.Name)
+
+		err = cfg.SaveTunnels(configPath)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		fmt.Printf("Deleted tunnel %s\n", opts.Name)
+
+	case options.TunnelUp:
+		err = c.useCredentials(cfg.Credentials, &opts.Options)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		if os.Getenv(managedTunnelEnv) != "" {
+			c.runManagedTunnel(store, opts.Name, &opts.Options)
+			return
+		}
+
+		for _, spec := range opts.Options.ForwardSpecs {
+			fmt.Printf("Forwarding %s to %s on %s/%d\n", spec.ListenAddress, spec.ConnectAddress, opts.Options.AppName, opts.Options.Instance)
+		}
+		c.RunWithOptions(cli, &opts.Options)
+
+	case options.TunnelStart:
+		err = startTunnel(store, opts.Name)
+
+	case options.TunnelList:
+		err = listTunnels(store, opts.Output)
+
+	case options.TunnelStop:
+		err = stopTunnel(store, opts.Name)
+	}
+
+	if err != nil {
+		c.failWith(opts.Output, err)
+	}
+}
+
+// runManagedTunnel is the detached side of `cf ssh-tunnel start`. It records
+// its progress and traffic in the tunnel's state file until it is stopped.
+func (c *SshPlugin) runManagedTunnel(store *tunnel.Store, name string, opts *options.Options) {
+	forwards := []string{}
+	for _, spec := range opts.ForwardSpecs {
+		forwards = append(forwards, spec.String())
+	}
+
+	now := time.Now()
+	state := tunnel.State{
+		Name:      name,
+		Pid:       os.Getpid(),
+		App:       opts.AppName,
+		Instance:  opts.Instance,
+		Forwards:  forwards,
+		Status:    tunnel.StatusConnecting,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := store.Save(state)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer store.Remove(name)
+
+	supervisor := c.newSupervisor(opts, nil)
+
+	// The heartbeat starts before connecting, which may take a while, so
+	// that stop can always tell the tunnel's pid from a reused one.
+	var (
+		lock sync.Mutex
+		fwd  *forwarder.Forwarder
+	)
+	heartbeat := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		updateTunnelState(store, &state, supervisor, fwd)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(tunnel.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				heartbeat()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = supervisor.Start()
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer supervisor.Close()
+
+	forwarding, err := startForwarding(&loggingDialer{dialer: supervisor, logger: c.logger()}, opts.ForwardSpecs)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	defer forwarding.Close()
+
+	lock.Lock()
+	fwd = forwarding
+	lock.Unlock()
+	heartbeat()
+
+	fmt.Printf("Tunnel %s connected\n", name)
+
+	err = waitForTermination(supervisor.Run)
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// updateTunnelState refreshes the state file. The tunnel is still connecting
+// until fwd is set.
+func updateTunnelState(store *tunnel.Store, state *tunnel.State, supervisor *reconnect.Supervisor, fwd *forwarder.Forwarder) {
+	state.UpdatedAt = time.Now()
+	if fwd != nil {
+		stats := fwd.Stats()
+		state.Status = tunnel.StatusDisconnected
+		if supervisor.Connected() {
+			state.Status = tunnel.StatusConnected
+		}
+		state.Connections = stats.Connections
+		state.BytesIn = stats.BytesIn
+		state.BytesOut = stats.BytesOut
+	}
+
+	err := store.Save(*state)
+	if err != nil {
+		fmt.Printf("Failed to update tunnel state: %s\n", err)
+	}
+}
+
+func startTunnel(store *tunnel.Store, name string) error {
+	if state, err := store.Load(name); err == nil {
+		if tunnel.ProcessAlive(state.Pid) && state.Current(time.Now()) {
+			fmt.Printf("Tunnel %s is already running (pid %d)\n", name, state.Pid)
+			return nil
+		}
+		store.Remove(name)
+	}
+
+	cfPath, err := exec.LookPath("cf")
+	if err != nil {
+		return fmt.Errorf("Unable to find the cf executable: %s", err)
+	}
+
+	logFile, err := store.OpenLog(name)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(cfPath, "ssh-tunnel", "up", name)
+	cmd.Env = append(os.Environ(), managedTunnelEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	tunnel.Detach(cmd)
+
+	launched := time.Now()
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(tunnelStartTimeout)
+
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("Tunnel %s exited before connecting; see %s", name, store.LogPath(name))
+		case <-timeout:
+			return fmt.Errorf("Tunnel %s has not connected after %s; see %s", name, tunnelStartTimeout, store.LogPath(name))
+		case <-ticker.C:
+			state, err := store.Load(name)
+			if err != nil || state.StartedAt.Before(launched) || state.Status != tunnel.StatusConnected {
+				continue
+			}
+
+			fmt.Printf("Started tunnel %s (pid %d)\n", name, state.Pid)
+			for _, forward := range state.Forwards {
+				fmt.Printf("   %s\n", forward)
+			}
+			return nil
+		}
+	}
+}
+
+func listTunnels(store *tunnel.Store, output options.OutputFormat) error {
+	running, stale, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	// Keep stdout parseable when printing JSON.
+	notices := os.Stdout
+	if output == options.OutputJSON {
+		notices = os.Stderr
+	}
+	for _, state := range stale {
+		fmt.Fprintf(notices, "Removed stale tunnel %s (pid %d is no longer running)\n", state.Name, state.Pid)
+	}
+
+	now := time.Now()
+
+	if output == options.OutputJSON {
+		health := func(state tunnel.State) string { return state.Health(now) }
+		return report.Write(os.Stdout, report.NewTunnelList(running, health, now))
+	}
+
+	if len(running) == 0 {
+		fmt.Println("No tunnels are running")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(table, "name\thealth\tpid\tapp\tinstance\tuptime\tconnections\tin\tout\tforwards")
+	for _, state := range running {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%d\t%s\t%d\t%d\t%d\t%s\n",
+			state.Name,
+			state.Health(now),
+			state.Pid,
+			state.App,
+			state.Instance,
+			now.Sub(state.StartedAt)/time.Second*time.Second,
+			state.Connections,
+			state.BytesIn,
+			state.BytesOut,
+			strings.Join(state.Forwards, ", "),
+		)
+	}
+	return table.Flush()
+}
+
+func stopTunnel(store *tunnel.Store, name string) error {
+	state, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+
+	if !tunnel.ProcessAlive(state.Pid) {
+		store.Remove(name)
+		fmt.Printf("Tunnel %s was not running; removed stale state\n", name)
+		return nil
+	}
+
+	if !state.Current(time.Now()) {
+		return fmt.Errorf("Tunnel %s has not updated its state since %s, so process %d may no longer be the tunnel; not signalling it. Stop the process yourself if it is the tunnel, then remove %s",
+			name, state.UpdatedAt.Format(time.RFC3339), state.Pid, store.StatePath(name))
+	}
+
+	err = tunnel.Terminate(state.Pid)
+	if err != nil {
+		return fmt.Errorf("Unable to stop tunnel %s: %s", name, err)
+	}
+
+	deadline := time.Now().Add(tunnelStopTimeout)
+	for tunnel.ProcessAlive(state.Pid) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if tunnel.ProcessAlive(state.Pid) {
+		if process, err := os.FindProcess(state.Pid); err == nil {
+			process.Kill()
+		}
+	}
+
+	store.Remove(name)
+	fmt.Printf("Stopped tunnel %s\n", name)
+	return nil
+}
+
+// waitForTermination blocks until wait returns, typically because the
+// connection was closed, or the user interrupts the plugin.
+func waitForTermination(wait func() error) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- wait()
+	}()
+
+	select {
+	case <-interrupted:
+		return nil
+	case err := <-closed:
+		return err
+	}
+}
+
+func (c *SshPlugin) showTunnelUsage() {
+	fmt.Println("NAME:")
+	fmt.Println("   ssh-tunnel")
+	fmt.Println("USAGE:")
+	fmt.Println("   " + c.GetMetadata().Commands[1].UsageDetails.Usage)
+}
@@ -0,0 +1,34 @@
+// +build !windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with the given pid exists.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Terminate asks the process to shut down gracefully.
+func Terminate(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// Detach starts the command in its own session so it outlives the plugin
+// and the terminal that started it.
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,47 @@
+// +build windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const processQueryLimitedInformation = 0x1000
+const stillActive = 259
+
+// ProcessAlive reports whether a process with the given pid exists.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	err = syscall.GetExitCodeProcess(handle, &exitCode)
+	return err == nil && exitCode == stillActive
+}
+
+// Terminate stops the process. Windows has no equivalent of SIGTERM for a
+// detached console process so this does not give it a chance to clean up.
+func Terminate(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// Detach starts the command in a new process group so console interrupts
+// sent to the plugin do not reach it.
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
@@ -0,0 +1,192 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StatusConnecting   = "connecting"
+	StatusConnected    = "connected"
+	StatusDisconnected = "disconnected"
+)
+
+// HeartbeatInterval is how often a running tunnel refreshes its state file.
+// A tunnel that has not done so for several intervals is unresponsive.
+const HeartbeatInterval = 5 * time.Second
+
+// staleAfter is how long a tunnel may go without refreshing its state file
+// before it is considered unresponsive.
+const staleAfter = 3 * HeartbeatInterval
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateName rejects tunnel names that could not be used as the base of a
+// file name in the state directory.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("Invalid tunnel name %q: use letters, digits, '_', '.', and '-'", name)
+	}
+	return nil
+}
+
+// State is the content of the state file a background tunnel maintains
+// while it runs.
+type State struct {
+	Name        string    `json:"name"`
+	Pid         int       `json:"pid"`
+	App         string    `json:"app"`
+	Instance    int       `json:"instance"`
+	Forwards    []string  `json:"forwards"`
+	Status      string    `json:"status"`
+	StartedAt   time.Time `json:"started_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Connections int64     `json:"connections"`
+	BytesIn     int64     `json:"bytes_in"`
+	BytesOut    int64     `json:"bytes_out"`
+}
+
+// Health describes a tunnel from the outside: whether its process is alive
+// and whether it has kept its state file current.
+func (s State) Health(now time.Time) string {
+	if !ProcessAlive(s.Pid) {
+		return "stale"
+	}
+	if !s.Current(now) {
+		return "unresponsive"
+	}
+	if s.Status == StatusConnected {
+		return "healthy"
+	}
+	return s.Status
+}
+
+// Current reports whether the tunnel has refreshed its state file recently.
+// Only then can its pid be trusted to still be the tunnel's; once the tunnel
+// has exited, the pid may be reused by an unrelated process.
+func (s State) Current(now time.Time) bool {
+	return now.Sub(s.UpdatedAt) <= staleAfter
+}
+
+// Store keeps one state file per tunnel in a directory.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) StatePath(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+func (s *Store) LogPath(name string) string {
+	return filepath.Join(s.dir, name+".log")
+}
+
+// OpenLog truncates and opens the log file of a tunnel for writing.
+func (s *Store) OpenLog(name string) (*os.File, error) {
+	err := os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(s.LogPath(name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+}
+
+func (s *Store) Save(state State) error {
+	err := ValidateName(state.Name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial state.
+	tmpPath := s.StatePath(state.Name) + ".tmp"
+	err = ioutil.WriteFile(tmpPath, contents, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.StatePath(state.Name))
+}
+
+func (s *Store) Load(name string) (State, error) {
+	var state State
+
+	err := ValidateName(name)
+	if err != nil {
+		return state, err
+	}
+
+	contents, err := ioutil.ReadFile(s.StatePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, fmt.Errorf("Tunnel %s is not running", name)
+		}
+		return state, err
+	}
+
+	err = json.Unmarshal(contents, &state)
+	if err != nil {
+		return state, fmt.Errorf("Failed to read state of tunnel %s: %s", name, err)
+	}
+
+	return state, nil
+}
+
+func (s *Store) Remove(name string) error {
+	err := os.Remove(s.StatePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the state of every tunnel in name order. Entries whose
+// process has exited are removed and returned separately.
+func (s *Store) List() ([]State, []State, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(matches)
+
+	running := []State{}
+	stale := []State{}
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		state, err := s.Load(name)
+		if err != nil {
+			continue
+		}
+
+		if !ProcessAlive(state.Pid) {
+			s.Remove(name)
+			stale = append(stale, state)
+			continue
+		}
+
+		running = append(running, state)
+	}
+
+	return running, stale, nil
+}
@@ -0,0 +1,187 @@
+package tunnel_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/tunnel"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("State", func() {
+	var (
+		tempDir string
+		store   *tunnel.Store
+		deadPid int
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "ssh-tunnels")
+		Expect(err).NotTo(HaveOccurred())
+
+		store = tunnel.NewStore(filepath.Join(tempDir, "ssh-tunnels"))
+
+		cmd := exec.Command("go", "version")
+		Expect(cmd.Run()).To(Succeed())
+		deadPid = cmd.Process.Pid
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	Describe("Store", func() {
+		var state tunnel.State
+
+		BeforeEach(func() {
+			state = tunnel.State{
+				Name:        "db-debug",
+				Pid:         os.Getpid(),
+				App:         "app1",
+				Instance:    1,
+				Forwards:    []string{"localhost:5432:db:5432"},
+				Status:      tunnel.StatusConnected,
+				StartedAt:   time.Now().Add(-time.Minute).UTC().Truncate(time.Second),
+				UpdatedAt:   time.Now().UTC().Truncate(time.Second),
+				Connections: 2,
+				BytesIn:     1024,
+				BytesOut:    512,
+			}
+		})
+
+		It("saves and loads tunnel state", func() {
+			Expect(store.Save(state)).To(Succeed())
+
+			loaded, err := store.Load("db-debug")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal(state))
+		})
+
+		It("reports tunnels without state as not running", func() {
+			_, err := store.Load("web-debug")
+			Expect(err).To(MatchError("Tunnel web-debug is not running"))
+		})
+
+		It("removes tunnel state", func() {
+			Expect(store.Save(state)).To(Succeed())
+			Expect(store.Remove("db-debug")).To(Succeed())
+
+			_, err := store.Load("db-debug")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects names that are not plain file names", func() {
+			for _, name := range []string{"../escape", "a/b", ".", "..", "", "db debug"} {
+				state.Name = name
+				Expect(store.Save(state)).To(HaveOccurred(), name)
+
+				_, err := store.Load(name)
+				Expect(err).To(MatchError(ContainSubstring("Invalid tunnel name")), name)
+			}
+
+			_, err := os.Stat(tempDir + "/escape.json")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("does not fail when removing state that does not exist", func() {
+			Expect(store.Remove("web-debug")).To(Succeed())
+		})
+
+		Describe("List", func() {
+			BeforeEach(func() {
+				Expect(store.Save(state)).To(Succeed())
+
+				staleState := state
+				staleState.Name = "web-debug"
+				staleState.Pid = deadPid
+				Expect(store.Save(staleState)).To(Succeed())
+			})
+
+			It("returns the running tunnels", func() {
+				running, _, err := store.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(running).To(Equal([]tunnel.State{state}))
+			})
+
+			It("separates and cleans up tunnels whose process has exited", func() {
+				_, stale, err := store.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stale).To(HaveLen(1))
+				Expect(stale[0].Name).To(Equal("web-debug"))
+
+				_, err = store.Load("web-debug")
+				Expect(err).To(MatchError("Tunnel web-debug is not running"))
+			})
+		})
+
+		Context("when the directory does not exist", func() {
+			It("lists no tunnels", func() {
+				running, stale, err := store.List()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(running).To(BeEmpty())
+				Expect(stale).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Health", func() {
+		var (
+			state tunnel.State
+			now   time.Time
+		)
+
+		BeforeEach(func() {
+			now = time.Now()
+			state = tunnel.State{
+				Pid:       os.Getpid(),
+				Status:    tunnel.StatusConnected,
+				UpdatedAt: now,
+			}
+		})
+
+		It("is healthy when connected and recently updated", func() {
+			Expect(state.Health(now)).To(Equal("healthy"))
+		})
+
+		It("is unresponsive when the state has not been updated", func() {
+			Expect(state.Health(now.Add(time.Minute))).To(Equal("unresponsive"))
+		})
+
+		It("reports the status while not yet connected", func() {
+			state.Status = tunnel.StatusConnecting
+			Expect(state.Health(now)).To(Equal("connecting"))
+		})
+
+		It("is stale when the process has exited", func() {
+			state.Pid = deadPid
+			Expect(state.Health(now)).To(Equal("stale"))
+		})
+	})
+
+	Describe("Current", func() {
+		It("holds while the heartbeat is recent", func() {
+			now := time.Now()
+			state := tunnel.State{UpdatedAt: now.Add(-tunnel.HeartbeatInterval)}
+			Expect(state.Current(now)).To(BeTrue())
+			Expect(state.Current(now.Add(time.Minute))).To(BeFalse())
+		})
+	})
+
+	Describe("ValidateName", func() {
+		It("accepts letters, digits, underscores, dots, and dashes", func() {
+			Expect(tunnel.ValidateName("db_debug-1.2")).To(Succeed())
+		})
+
+		It("rejects anything else", func() {
+			for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "db debug", "db:1"} {
+				Expect(tunnel.ValidateName(name)).To(HaveOccurred(), name)
+			}
+		})
+	})
+})
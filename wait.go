@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sykesm/cf-ssh-plugin/models/app"
+)
+
+const (
+	waitPollInterval = 2 * time.Second
+	waitTimeout      = 5 * time.Minute
+)
+
+// waitForInstance polls the app's instances until the requested one is
+// RUNNING, reporting each state it passes through. Instance states are only
+// available for the web process.
+func (c *SshPlugin) waitForInstance(a app.App, index int) error {
+	if a.ProcessType != "" && a.ProcessType != app.WebProcess {
+		return errors.New("--wait is only supported for the web process")
+	}
+
+	deadline := time.Now().Add(waitTimeout)
+	lastState := ""
+
+	for {
+		instances, err := c.InstancesFactory.Get(a.Guid)
+		if err != nil {
+			return err
+		}
+
+		state := "DOWN"
+		for _, instance := range instances {
+			if instance.Index == index {
+				state = instance.State
+			}
+		}
+
+		if state == "RUNNING" {
+			return nil
+		}
+
+		if state != lastState {
+			fmt.Fprintf(os.Stderr, "Waiting for instance %d to start (%s)\n", index, state)
+			lastState = state
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for instance %d to start", index)
+		}
+
+		time.Sleep(waitPollInterval)
+	}
+}